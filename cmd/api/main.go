@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
@@ -13,6 +14,8 @@ func main() {
 		log.Fatal("Failed to bootstrap application:", err)
 	}
 
+	boot.StartJobs(context.Background())
+
 	err = http.ListenAndServe(boot.Config.AppHost+":"+boot.Config.AppPort, boot.Router)
 	log.Println("Server running on " + boot.Config.AppHost + ":" + boot.Config.AppPort)
 	if err != nil {
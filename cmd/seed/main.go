@@ -18,8 +18,14 @@ func main() {
 		seeds.SeedRoles,
 		seeds.SeedUsers,
 		seeds.SeedCategories,
+		seeds.SeedSubcategories,
 		seeds.SeedProducts,
+		seeds.SeedProductOptions,
+		seeds.SeedProductVariants,
 		seeds.SeedProductCategory,
+		seeds.SeedTaxRates,
+		seeds.SeedShippingZones,
+		seeds.SeedShippingMethods,
 		seeds.SeedCarts,
 		seeds.SeedCartItems,
 		seeds.SeedOrders,
@@ -0,0 +1,183 @@
+package addresses
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		Create(ctx context.Context, userID int64, a *CreateAddressRequest) (*Address, error)
+		FindByID(ctx context.Context, userID, id int64) (*Address, error)
+		FindAll(ctx context.Context, userID int64) ([]Address, error)
+		Update(ctx context.Context, userID, id int64, a UpdateAddressRequest) error
+		Delete(ctx context.Context, userID, id int64) error
+	}
+
+	AddressHandler struct {
+		addressService Service
+		validate       *validator.Validate
+	}
+)
+
+func NewAddressHandler(addressService Service, validate *validator.Validate) *AddressHandler {
+	return &AddressHandler{addressService: addressService, validate: validate}
+}
+
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	req := CreateAddressRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	address, err := h.addressService.Create(ctx, userID, &req)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			httpx.HTTPErrors(w, http.StatusBadRequest, validationErr.Fields)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, address)
+}
+
+func (h *AddressHandler) FindAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	addresses, err := h.addressService.FindAll(ctx, userID)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if addresses == nil {
+		addresses = []Address{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	address, err := h.addressService.FindByID(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, address)
+}
+
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateAddressRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdateAddressRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.addressService.Update(ctx, userID, id, req); err != nil {
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			httpx.HTTPErrors(w, http.StatusBadRequest, validationErr.Fields)
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.UpdatedResponse})
+}
+
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.addressService.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
@@ -0,0 +1,66 @@
+// Package addresses manages the address book of each user and the address snapshots stored on orders.
+package addresses
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// PostalAddress is the structured address shared by the address book and order snapshots.
+type PostalAddress struct {
+	Name       string `json:"name" validate:"required,max=255"`
+	Line1      string `json:"line1" validate:"required,max=255"`
+	Line2      string `json:"line2,omitempty" validate:"max=255"`
+	City       string `json:"city" validate:"required,max=100"`
+	Region     string `json:"region,omitempty" validate:"max=100"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"required,len=2,uppercase"` // ISO 3166-1 alpha-2
+	Phone      string `json:"phone,omitempty" validate:"max=30"`
+}
+
+// Value stores the address as JSONB.
+func (a PostalAddress) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan reads the address from a JSONB column.
+func (a *PostalAddress) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case nil:
+		*a = PostalAddress{}
+		return nil
+	}
+	return errors.New("unsupported type for PostalAddress")
+}
+
+type Address struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	PostalAddress
+	IsDefault bool       `json:"is_default"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type CreateAddressRequest struct {
+	PostalAddress
+	IsDefault bool `json:"is_default"`
+}
+
+type UpdateAddressRequest struct {
+	Name       *string `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Line1      *string `json:"line1,omitempty" validate:"omitempty,min=1,max=255"`
+	Line2      *string `json:"line2,omitempty" validate:"omitempty,max=255"`
+	City       *string `json:"city,omitempty" validate:"omitempty,min=1,max=100"`
+	Region     *string `json:"region,omitempty" validate:"omitempty,max=100"`
+	PostalCode *string `json:"postal_code,omitempty" validate:"omitempty,min=1,max=20"`
+	Country    *string `json:"country,omitempty" validate:"omitempty,len=2,uppercase"`
+	Phone      *string `json:"phone,omitempty" validate:"omitempty,max=30"`
+	IsDefault  *bool   `json:"is_default,omitempty"`
+}
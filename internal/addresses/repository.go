@@ -0,0 +1,207 @@
+package addresses
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const addressColumns = "id, user_id, name, line1, line2, city, region, postal_code, country, phone, is_default, created_at, updated_at"
+
+type AddressRepository struct {
+	db *sql.DB
+}
+
+func NewAddressRepository(db *sql.DB) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAddress(s scanner) (*Address, error) {
+	var a Address
+	err := s.Scan(&a.ID, &a.UserID, &a.Name, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode, &a.Country, &a.Phone, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create inserts the address. The first address of a user always becomes the default one.
+func (r *AddressRepository) Create(ctx context.Context, userID int64, data CreateAddressRequest) (*Address, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	isDefault := data.IsDefault
+	if !isDefault {
+		var count int
+		if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM addresses WHERE user_id = $1", userID).Scan(&count); err != nil {
+			return nil, err
+		}
+		isDefault = count == 0
+	}
+
+	if isDefault {
+		if _, err = tx.ExecContext(ctx, "UPDATE addresses SET is_default = FALSE WHERE user_id = $1 AND is_default", userID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "INSERT INTO addresses (user_id, name, line1, line2, city, region, postal_code, country, phone, is_default) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING " + addressColumns
+	a, err := scanAddress(tx.QueryRowContext(ctx, query, userID, data.Name, data.Line1, data.Line2, data.City, data.Region, data.PostalCode, data.Country, data.Phone, isDefault))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return a, nil
+}
+
+func (r *AddressRepository) FindByID(ctx context.Context, userID, id int64) (*Address, error) {
+	query := "SELECT " + addressColumns + " FROM addresses WHERE id = $1 AND user_id = $2"
+	return scanAddress(r.db.QueryRowContext(ctx, query, id, userID))
+}
+
+func (r *AddressRepository) FindDefault(ctx context.Context, userID int64) (*Address, error) {
+	query := "SELECT " + addressColumns + " FROM addresses WHERE user_id = $1 AND is_default"
+	return scanAddress(r.db.QueryRowContext(ctx, query, userID))
+}
+
+func (r *AddressRepository) FindAllByUserID(ctx context.Context, userID int64) ([]Address, error) {
+	query := "SELECT " + addressColumns + " FROM addresses WHERE user_id = $1 ORDER BY is_default DESC, id"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var addresses []Address
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *a)
+	}
+
+	return addresses, rows.Err()
+}
+
+func (r *AddressRepository) Update(ctx context.Context, userID, id int64, a UpdateAddressRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	columns := []struct {
+		name  string
+		value *string
+	}{
+		{"name", a.Name},
+		{"line1", a.Line1},
+		{"line2", a.Line2},
+		{"city", a.City},
+		{"region", a.Region},
+		{"postal_code", a.PostalCode},
+		{"country", a.Country},
+		{"phone", a.Phone},
+	}
+	for _, c := range columns {
+		if c.value != nil {
+			fields = append(fields, fmt.Sprintf("%s = $%d", c.name, i))
+			args = append(args, *c.value)
+			i++
+		}
+	}
+	if a.IsDefault != nil {
+		fields = append(fields, fmt.Sprintf("is_default = $%d", i))
+		args = append(args, *a.IsDefault)
+		i++
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if a.IsDefault != nil && *a.IsDefault {
+		if _, err = tx.ExecContext(ctx, "UPDATE addresses SET is_default = FALSE WHERE user_id = $1 AND is_default AND id <> $2", userID, id); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE addresses SET %s WHERE id = $%d AND user_id = $%d", strings.Join(fields, ", "), i, i+1)
+	args = append(args, id, userID)
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the address. When it was the default one, the most recent remaining address takes its place.
+func (r *AddressRepository) Delete(ctx context.Context, userID, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wasDefault bool
+	err = tx.QueryRowContext(ctx, "DELETE FROM addresses WHERE id = $1 AND user_id = $2 RETURNING is_default", id, userID).Scan(&wasDefault)
+	if err != nil {
+		return err
+	}
+
+	if wasDefault {
+		query := "UPDATE addresses SET is_default = TRUE WHERE id = (SELECT id FROM addresses WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1)"
+		if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
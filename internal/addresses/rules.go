@@ -0,0 +1,56 @@
+package addresses
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// countryRule holds the country specific constraints of an address.
+type countryRule struct {
+	postalCode     *regexp.Regexp
+	regionRequired bool
+}
+
+var countryRules = map[string]countryRule{
+	"ES": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"PT": {postalCode: regexp.MustCompile(`^\d{4}-\d{3}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"IT": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), regionRequired: true},
+	"MX": {postalCode: regexp.MustCompile(`^\d{5}$`), regionRequired: true},
+}
+
+// ValidationError lists the fields of an address that break the rules of its country.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid address"
+}
+
+// ValidateForCountry applies the postal code and region rules of the address country.
+// Countries without rules only get the generic struct validation.
+func ValidateForCountry(a PostalAddress) error {
+	rule, ok := countryRules[strings.ToUpper(a.Country)]
+	if !ok {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	if rule.postalCode != nil && !rule.postalCode.MatchString(strings.ToUpper(a.PostalCode)) {
+		fields["postal_code"] = fmt.Sprintf("the postal_code field is not a valid postal code for %s", a.Country)
+	}
+	if rule.regionRequired && strings.TrimSpace(a.Region) == "" {
+		fields["region"] = fmt.Sprintf("the region field is required for %s", a.Country)
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
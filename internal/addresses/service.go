@@ -0,0 +1,78 @@
+package addresses
+
+import "context"
+
+type (
+	Repository interface {
+		Create(ctx context.Context, userID int64, data CreateAddressRequest) (*Address, error)
+		FindByID(ctx context.Context, userID, id int64) (*Address, error)
+		FindDefault(ctx context.Context, userID int64) (*Address, error)
+		FindAllByUserID(ctx context.Context, userID int64) ([]Address, error)
+		Update(ctx context.Context, userID, id int64, data UpdateAddressRequest) error
+		Delete(ctx context.Context, userID, id int64) error
+	}
+
+	AddressService struct {
+		addressRepo Repository
+	}
+)
+
+func NewAddressService(addressRepo Repository) *AddressService {
+	return &AddressService{addressRepo: addressRepo}
+}
+
+func (s *AddressService) Create(ctx context.Context, userID int64, a *CreateAddressRequest) (*Address, error) {
+	if err := ValidateForCountry(a.PostalAddress); err != nil {
+		return nil, err
+	}
+	return s.addressRepo.Create(ctx, userID, *a)
+}
+
+func (s *AddressService) FindByID(ctx context.Context, userID, id int64) (*Address, error) {
+	return s.addressRepo.FindByID(ctx, userID, id)
+}
+
+func (s *AddressService) FindDefault(ctx context.Context, userID int64) (*Address, error) {
+	return s.addressRepo.FindDefault(ctx, userID)
+}
+
+func (s *AddressService) FindAll(ctx context.Context, userID int64) ([]Address, error) {
+	return s.addressRepo.FindAllByUserID(ctx, userID)
+}
+
+// Update validates the address that results from applying the changes before persisting them.
+func (s *AddressService) Update(ctx context.Context, userID, id int64, a UpdateAddressRequest) error {
+	current, err := s.addressRepo.FindByID(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	merged := current.PostalAddress
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{
+		{&merged.Name, a.Name},
+		{&merged.Line1, a.Line1},
+		{&merged.Line2, a.Line2},
+		{&merged.City, a.City},
+		{&merged.Region, a.Region},
+		{&merged.PostalCode, a.PostalCode},
+		{&merged.Country, a.Country},
+		{&merged.Phone, a.Phone},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+
+	if err := ValidateForCountry(merged); err != nil {
+		return err
+	}
+
+	return s.addressRepo.Update(ctx, userID, id, a)
+}
+
+func (s *AddressService) Delete(ctx context.Context, userID, id int64) error {
+	return s.addressRepo.Delete(ctx, userID, id)
+}
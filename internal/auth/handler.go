@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"log"
 	"net/http"
 
 	"ecommerce-service/internal/auth/strategies"
@@ -18,14 +19,20 @@ type (
 		GenerateTokens(userID int) (accessToken, refreshToken string, err error)
 	}
 
+	// CartMerger merges the guest cart of the request into the cart of the user signing in.
+	CartMerger interface {
+		MergeGuestCart(w http.ResponseWriter, r *http.Request, userID int64) error
+	}
+
 	AuthHandler struct {
 		authService   Service
 		tokensService TokensService
+		cartMerger    CartMerger
 	}
 )
 
-func NewAuthHandler(a Service, t TokensService) *AuthHandler {
-	return &AuthHandler{authService: a, tokensService: t}
+func NewAuthHandler(a Service, t TokensService, c CartMerger) *AuthHandler {
+	return &AuthHandler{authService: a, tokensService: t, cartMerger: c}
 }
 
 func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -50,6 +57,11 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A failed merge leaves the guest cart as it was and must not fail the login
+	if err := ah.cartMerger.MergeGuestCart(w, r, int64(u.ID)); err != nil {
+		log.Printf("error merging the guest cart of user %d: %v\n", u.ID, err)
+	}
+
 	httpx.HTTPResponse(w, http.StatusOK, map[string]string{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
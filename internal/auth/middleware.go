@@ -2,10 +2,15 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/roles"
 	"ecommerce-service/pkg/httpx"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,18 +21,28 @@ type (
 		VerifyToken(tokenStr string, secret string) (*jwt.Token, error)
 		ExtractClaims(token *jwt.Token) (jwt.MapClaims, error)
 	}
+
+	// RoleFinder resolves the current role of a user, so role changes apply without a new token.
+	RoleFinder interface {
+		FindByUserID(ctx context.Context, userID int64) (*roles.Role, error)
+	}
+
 	AuthMiddleware struct {
 		tokenService TokenService
+		roleFinder   RoleFinder
 		config       *config.Config
 	}
 
 	contextKey string
 )
 
-const userClaimsKey contextKey = "user_id"
+const (
+	userClaimsKey contextKey = "user_id"
+	adminKey      contextKey = "admin"
+)
 
-func NewAuthMiddleware(ts TokenService, c *config.Config) *AuthMiddleware {
-	return &AuthMiddleware{tokenService: ts, config: c}
+func NewAuthMiddleware(ts TokenService, rf RoleFinder, c *config.Config) *AuthMiddleware {
+	return &AuthMiddleware{tokenService: ts, roleFinder: rf, config: c}
 }
 
 func (am *AuthMiddleware) VerifyToken(next http.Handler) http.Handler {
@@ -58,4 +73,108 @@ func (am *AuthMiddleware) VerifyToken(next http.Handler) http.Handler {
 	})
 }
 
-// TODO: Add role-based authorization middleware
+// OptionalToken identifies the user like VerifyToken when a valid token is sent, and otherwise lets
+// the request through anonymously, for public routes that show more to signed-in users.
+func (am *AuthMiddleware) OptionalToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenStr := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tokenStr == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := am.tokenService.VerifyToken(tokenStr, am.config.JWTSecret)
+		if err != nil || !token.Valid {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := am.tokenService.ExtractClaims(token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userClaimsKey, claims)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// IdentifyAdmin marks the requests of admins and superadmins, see IsAdmin. Other requests pass
+// through unmarked. It must run after VerifyToken or OptionalToken.
+func (am *AuthMiddleware) IdentifyAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role, err := am.roleFinder.FindByUserID(r.Context(), userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("error finding role of user %d: %v\n", userID, err)
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+			return
+		}
+
+		if role != nil && (role.Name == roles.RoleAdmin || role.Name == roles.RoleSuperAdmin) {
+			r = r.WithContext(context.WithValue(r.Context(), adminKey, true))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsAdmin reports whether IdentifyAdmin marked the request as made by an admin.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey).(bool)
+	return admin
+}
+
+// UserIDFromContext returns the ID of the authenticated user set by VerifyToken.
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	claims, ok := ctx.Value(userClaimsKey).(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, false
+	}
+
+	return int64(userID), true
+}
+
+// RequireRole only lets through users with one of the given roles. It must run after VerifyToken.
+func (am *AuthMiddleware) RequireRole(names ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+				return
+			}
+
+			role, err := am.roleFinder.FindByUserID(r.Context(), userID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("error finding role of user %d: %v\n", userID, err)
+				httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+				return
+			}
+
+			if role == nil || !slices.Contains(names, role.Name) {
+				httpx.HTTPError(w, http.StatusForbidden, httpx.ForbiddenError)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin only lets through admins and superadmins. It must run after VerifyToken.
+func (am *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return am.RequireRole(roles.RoleAdmin, roles.RoleSuperAdmin)(next)
+}
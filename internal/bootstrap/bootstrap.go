@@ -2,19 +2,36 @@
 package bootstrap
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"net/http"
+	"strings"
+	"time"
 
+	"ecommerce-service/internal/addresses"
 	"ecommerce-service/internal/auth"
 	"ecommerce-service/internal/auth/strategies"
 	"ecommerce-service/internal/carts"
 	"ecommerce-service/internal/categories"
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/events"
+	"ecommerce-service/internal/fulfillment"
+	"ecommerce-service/internal/idempotency"
+	"ecommerce-service/internal/invoices"
+	"ecommerce-service/internal/media"
 	"ecommerce-service/internal/orders"
 	"ecommerce-service/internal/products"
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/reviews"
 	"ecommerce-service/internal/roles"
+	"ecommerce-service/internal/shipping"
+	"ecommerce-service/internal/taxes"
 	"ecommerce-service/internal/tokens"
 	"ecommerce-service/internal/users"
+	"ecommerce-service/internal/webhooks"
+	"ecommerce-service/internal/wishlists"
+	"ecommerce-service/pkg/mailx"
 
 	healthcheck "ecommerce-service/internal/health-check"
 
@@ -26,6 +43,8 @@ type Bootstrapper struct {
 	Config *config.Config
 	DB     *sql.DB
 	Router chi.Router
+
+	jobs []func(ctx context.Context)
 }
 
 func Bootstrap() (*Bootstrapper, error) {
@@ -52,8 +71,46 @@ func Bootstrap() (*Bootstrapper, error) {
 	// validator
 	validate := validator.New()
 
+	// mail, written to the log until an SMTP server is configured
+	var mailer mailx.Sender = mailx.LogSender{}
+	if b.Config.MailSMTPHost != "" {
+		mailer = mailx.NewSMTPSender(b.Config.MailSMTPHost, b.Config.MailSMTPPort, b.Config.MailSMTPUser, b.Config.MailSMTPPassword, b.Config.MailFrom)
+	}
+
 	// Initialize modules
 
+	// domain events, published from the outbox to the in-process bus and the configured sinks
+	eventBus := events.NewBus()
+	eventSinks := []events.Sink{eventBus}
+	for _, name := range strings.Split(b.Config.EventSinks, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "log":
+			eventSinks = append(eventSinks, events.LogSink{})
+		case "webhook":
+			if b.Config.EventWebhookURLs == "" {
+				log.Println("the webhook event sink needs EVENT_WEBHOOK_URLS, skipping it")
+				continue
+			}
+			eventSinks = append(eventSinks, events.NewWebhookSink(&http.Client{Timeout: 10 * time.Second}, strings.Split(b.Config.EventWebhookURLs, ",")...))
+		default:
+			log.Printf("unknown event sink %q, skipping it", name)
+		}
+	}
+	eventDispatcher := events.NewDispatcher(events.NewOutboxRepository(b.DB), b.Config, eventSinks...)
+	b.jobs = append(b.jobs, func(ctx context.Context) {
+		eventDispatcher.RunDispatch(ctx, time.Duration(max(b.Config.EventDispatchInterval, 1))*time.Second)
+	})
+
+	// webhooks module, merchant subscriptions fed from the event bus
+	webhookRepository := webhooks.NewWebhookRepository(b.DB)
+	webhookService := webhooks.NewWebhookService(webhookRepository, &http.Client{Timeout: time.Duration(max(b.Config.WebhookTimeout, 1)) * time.Second}, b.Config)
+	webhookHandler := webhooks.NewWebhookHandler(webhookService, validate, b.Config)
+	eventBus.Subscribe(webhooks.AllEvents, webhookService.Enqueue)
+	b.jobs = append(b.jobs, func(ctx context.Context) {
+		webhookService.RunDeliveries(ctx, time.Duration(max(b.Config.WebhookDeliveryInterval, 1))*time.Second)
+	})
+
 	// health-check module
 	healthCheckHandler := healthcheck.NewHealthCheckHandler()
 
@@ -80,37 +137,136 @@ func Bootstrap() (*Bootstrapper, error) {
 
 	// auth module
 	authService := auth.NewAuthService(authStrategies)
-	authHandler := auth.NewAuthHandler(authService, tokenService)
+	authMiddleware := auth.NewAuthMiddleware(tokenService, roleService, b.Config)
+
+	// addresses module
+	addressRepository := addresses.NewAddressRepository(b.DB)
+	addressService := addresses.NewAddressService(addressRepository)
+	addressHandler := addresses.NewAddressHandler(addressService, validate)
+
+	// media storage, the local filesystem is the only backend for now
+	if b.Config.MediaStorage != "local" {
+		log.Printf("unknown media storage %q, falling back to local", b.Config.MediaStorage)
+	}
+	mediaStorage, err := media.NewLocalStorage(b.Config.MediaLocalDir, b.Config.MediaBaseURL)
+	if err != nil {
+		log.Println("Error creating the media storage:", err)
+		return nil, err
+	}
 
 	// Initialize product module
-	productRepository := products.NewProductRepository(b.DB)
-	productService := products.NewProductService(productRepository, b.Config)
+	productRepository := products.NewProductRepository(b.DB, b.Config.StockLowThreshold)
+	productService := products.NewProductService(productRepository, mediaStorage, b.Config)
 	productHandler := products.NewProductHandler(productService, validate, b.Config)
+	b.jobs = append(b.jobs, func(ctx context.Context) {
+		productService.RunPublishSchedule(ctx, time.Duration(max(b.Config.ProductPublishInterval, 1))*time.Second)
+	})
 
 	// category module
 	categoryRepository := categories.NewCategoryRepository(b.DB)
-	categoryService := categories.NewCategoryService(categoryRepository)
-	categoryHandler := categories.NewCategoryHandler(categoryService, b.Config)
+	categoryService := categories.NewCategoryService(categoryRepository, productService)
+	categoryHandler := categories.NewCategoryHandler(categoryService, validate, b.Config)
+
+	// promotions module
+	promotionRepository := promotions.NewPromotionRepository(b.DB)
+	promotionService := promotions.NewPromotionService(promotionRepository)
+	promotionHandler := promotions.NewPromotionHandler(promotionService, validate, b.Config)
+
+	// taxes module, the built-in table provider is the default
+	var taxCalculator taxes.Calculator
+	switch b.Config.TaxProvider {
+	case "table":
+		taxCalculator = taxes.NewTableCalculator(taxes.NewTaxRateRepository(b.DB))
+	default:
+		log.Printf("unknown tax provider %q, falling back to table", b.Config.TaxProvider)
+		taxCalculator = taxes.NewTableCalculator(taxes.NewTaxRateRepository(b.DB))
+	}
+
+	// shipping module
+	shippingRepository := shipping.NewShippingRepository(b.DB)
+	shippingService := shipping.NewShippingService(shippingRepository)
+	shippingHandler := shipping.NewShippingHandler(shippingService, validate)
 
 	// cart module
 	cartRepository := carts.NewCartRepository(b.DB)
-	cartService := carts.NewCartService(cartRepository)
-	cartHandler := carts.NewCartHandler(cartService, validate)
+	cartService := carts.NewCartService(cartRepository, productService, promotionService, taxCalculator, shippingService, b.Config)
+	guestTokens := carts.NewGuestTokens(b.Config.CartTokenSecret)
+	cartHandler := carts.NewCartHandler(cartService, guestTokens, validate, b.Config)
+	switch b.Config.CartMergeStrategy {
+	case carts.MergeSum, carts.MergeMax, carts.MergeUser, carts.MergeGuest:
+	default:
+		log.Printf("unknown cart merge strategy %q, falling back to sum", b.Config.CartMergeStrategy)
+	}
+
+	// auth handler, merges the guest cart on login
+	authHandler := auth.NewAuthHandler(authService, tokenService, cartHandler)
+
+	// idempotency keys, for the POST endpoints that clients retry
+	idempotencyRepository := idempotency.NewIdempotencyRepository(b.DB)
+	idempotencyService := idempotency.NewIdempotencyService(idempotencyRepository, b.Config)
+	idempotencyMiddleware := idempotency.NewIdempotencyMiddleware(idempotencyService, guestTokens, auth.UserIDFromContext)
+	b.jobs = append(b.jobs, func(ctx context.Context) {
+		idempotencyService.RunCleanup(ctx, time.Duration(max(b.Config.IdempotencyCleanup, 1))*time.Second)
+	})
 
 	// orders module
-	orderRepository := orders.NewOrderRepository(b.DB)
-	orderService := orders.NewOrderService(orderRepository, cartRepository)
-	orderHandler := orders.NewOrderHandler(orderService, validate, b.Config)
+	orderRepository := orders.NewOrderRepository(b.DB, b.Config.StockLowThreshold)
+	orderService := orders.NewOrderService(orderRepository, cartRepository, cartService, addressService, shippingService, userService, roleService, mailer, b.Config)
+	orderHandler := orders.NewOrderHandler(orderService, guestTokens, validate, b.Config)
+
+	// fulfillment module
+	fulfillmentRepository := fulfillment.NewFulfillmentRepository(b.DB)
+	fulfillmentService := fulfillment.NewFulfillmentService(fulfillmentRepository, orderService)
+	fulfillmentHandler := fulfillment.NewFulfillmentHandler(fulfillmentService, validate)
+
+	// media module
+	imageRepository := media.NewImageRepository(b.DB)
+	mediaService := media.NewMediaService(imageRepository, productService, mediaStorage, b.Config)
+	mediaHandler := media.NewMediaHandler(mediaService, validate, b.Config)
+
+	// reviews module
+	reviewRepository := reviews.NewReviewRepository(b.DB)
+	reviewService := reviews.NewReviewService(reviewRepository, productService)
+	reviewHandler := reviews.NewReviewHandler(reviewService, validate, b.Config)
+
+	// wishlists module
+	wishlistRepository := wishlists.NewWishlistRepository(b.DB)
+	wishlistService := wishlists.NewWishlistService(wishlistRepository, productService, cartService, mailer)
+	wishlistHandler := wishlists.NewWishlistHandler(wishlistService, validate)
+	b.jobs = append(b.jobs, func(ctx context.Context) {
+		wishlistService.RunBackInStock(ctx, time.Duration(max(b.Config.BackInStockInterval, 1))*time.Second)
+	})
+
+	// invoices module
+	invoiceRepository := invoices.NewInvoiceRepository(b.DB)
+	invoiceService := invoices.NewInvoiceService(invoiceRepository, orderService, b.Config)
+	invoiceHandler := invoices.NewInvoiceHandler(invoiceService, validate)
 
 	// Register routes
 	healthcheck.RegisterRoutes(b.Router, healthCheckHandler)
 	roles.RegisterRoutes(b.Router, roleHandler)
-	users.RegisterRoutes(b.Router, userHandler)
-	products.RegisterRoutes(b.Router, productHandler)
+	users.RegisterRoutes(b.Router, userHandler, idempotencyMiddleware)
+	products.RegisterRoutes(b.Router, productHandler, authMiddleware)
 	auth.RegisterRoutes(b.Router, authHandler)
-	categories.RegisterRoutes(b.Router, categoryHandler)
-	carts.RegisterRoutes(b.Router, cartHandler)
-	orders.RegisterRoutes(b.Router, orderHandler)
+	categories.RegisterRoutes(b.Router, categoryHandler, authMiddleware)
+	carts.RegisterRoutes(b.Router, cartHandler, authMiddleware)
+	orders.RegisterRoutes(b.Router, orderHandler, authMiddleware, idempotencyMiddleware)
+	promotions.RegisterRoutes(b.Router, promotionHandler, authMiddleware)
+	addresses.RegisterRoutes(b.Router, addressHandler, authMiddleware)
+	shipping.RegisterRoutes(b.Router, shippingHandler, authMiddleware)
+	fulfillment.RegisterRoutes(b.Router, fulfillmentHandler, authMiddleware)
+	invoices.RegisterRoutes(b.Router, invoiceHandler, authMiddleware)
+	media.RegisterRoutes(b.Router, mediaHandler, mediaStorage, authMiddleware)
+	reviews.RegisterRoutes(b.Router, reviewHandler, authMiddleware)
+	wishlists.RegisterRoutes(b.Router, wishlistHandler, authMiddleware)
+	webhooks.RegisterRoutes(b.Router, webhookHandler, authMiddleware)
 
 	return &b, nil
 }
+
+// StartJobs runs the background jobs of the modules, each in its own goroutine, until ctx is done.
+func (b *Bootstrapper) StartJobs(ctx context.Context) {
+	for _, job := range b.jobs {
+		go job(ctx)
+	}
+}
@@ -0,0 +1,79 @@
+package carts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// The cart token of a guest travels in a cookie or, for clients without cookies, in a header.
+const (
+	GuestCookieName = "cart_token"
+	GuestHeaderName = "X-Cart-Token"
+)
+
+// Merge strategies for a product found both in the guest cart and in the cart of the user.
+const (
+	MergeSum   = "sum"   // Add up the quantities
+	MergeMax   = "max"   // Keep the larger quantity
+	MergeUser  = "user"  // Keep the line of the user
+	MergeGuest = "guest" // Keep the line of the guest
+)
+
+var ErrInvalidCartToken = errors.New("the cart token is invalid")
+
+// GuestTokens issues and verifies the cart tokens of guests: a random guest ID signed with
+// HMAC-SHA256, so that guests cannot reach the carts of others.
+type GuestTokens struct {
+	secret []byte
+}
+
+func NewGuestTokens(secret string) *GuestTokens {
+	return &GuestTokens{secret: []byte(secret)}
+}
+
+// New returns the token of a new guest and the guest ID it carries.
+func (gt *GuestTokens) New() (token, guestID string, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	guestID = hex.EncodeToString(b)
+	return guestID + "." + gt.sign(guestID), guestID, nil
+}
+
+// Verify returns the guest ID of the token.
+func (gt *GuestTokens) Verify(token string) (string, error) {
+	guestID, signature, ok := strings.Cut(token, ".")
+	if !ok || guestID == "" || !hmac.Equal([]byte(signature), []byte(gt.sign(guestID))) {
+		return "", ErrInvalidCartToken
+	}
+	return guestID, nil
+}
+
+// FromRequest returns the guest ID of the cart token of the request.
+func (gt *GuestTokens) FromRequest(r *http.Request) (string, error) {
+	return gt.Verify(guestToken(r))
+}
+
+// guestToken returns the cart token of the request, from the header or else the cookie.
+func guestToken(r *http.Request) string {
+	if token := r.Header.Get(GuestHeaderName); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(GuestCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func (gt *GuestTokens) sign(guestID string) string {
+	mac := hmac.New(sha256.New, gt.secret)
+	mac.Write([]byte(guestID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -2,9 +2,16 @@ package carts
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/shipping"
 	"ecommerce-service/pkg/httpx"
 
 	"github.com/go-chi/chi/v5"
@@ -14,18 +21,29 @@ import (
 type (
 	Service interface {
 		GetCart(ctx context.Context, userID int64) (*Cart, error)
-		AddItemToCart(ctx context.Context, userID int64, productID int64, quantity int) (*Cart, error)
+		AddItemToCart(ctx context.Context, userID int64, productID int64, variantID *int64, quantity int) (*Cart, error)
+		Find(ctx context.Context, owner Owner) (*Cart, error)
+		AddItem(ctx context.Context, owner Owner, productID int64, variantID *int64, quantity int) (*Cart, error)
+		SetItemQuantity(ctx context.Context, owner Owner, productID int64, variantID *int64, quantity int) (*Cart, error)
+		RemoveItem(ctx context.Context, owner Owner, productID int64, variantID *int64) (*Cart, error)
+		MergeGuestCart(ctx context.Context, guestID string, userID int64) error
+		Validate(ctx context.Context, owner Owner) (*Validation, error)
 		ClearCart(ctx context.Context, userID int64) error
 		CompleteCart(ctx context.Context, userID int64) error
+		ApplyCoupon(ctx context.Context, owner Owner, code string) (*Cart, error)
+		RemoveCoupon(ctx context.Context, owner Owner, code string) (*Cart, error)
+		ShippingOptions(ctx context.Context, owner Owner, country string) ([]shipping.Quote, error)
 	}
 	CartHandler struct {
 		cartService Service
+		guestTokens *GuestTokens
 		validate    *validator.Validate
+		config      *config.Config
 	}
 )
 
-func NewCartHandler(cartService Service, validate *validator.Validate) *CartHandler {
-	return &CartHandler{cartService: cartService, validate: validate}
+func NewCartHandler(cartService Service, guestTokens *GuestTokens, validate *validator.Validate, config *config.Config) *CartHandler {
+	return &CartHandler{cartService: cartService, guestTokens: guestTokens, validate: validate, config: config}
 }
 
 func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
@@ -57,8 +75,9 @@ func (h *CartHandler) AddItemToCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ProductID int64 `json:"product_id"`
-		Quantity  int   `json:"quantity"`
+		ProductID int64  `json:"product_id" validate:"required"`
+		VariantID *int64 `json:"variant_id"`
+		Quantity  int    `json:"quantity" validate:"required,gt=0"`
 	}
 
 	if err := httpx.ParseJSON(r, &req); err != nil {
@@ -67,19 +86,233 @@ func (h *CartHandler) AddItemToCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.validate.Struct(req); err != nil {
-		httpx.HTTPResponse(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
 		return
 	}
 
-	cart, err := h.cartService.AddItemToCart(ctx, userID, req.ProductID, req.Quantity)
+	cart, err := h.cartService.AddItemToCart(ctx, userID, req.ProductID, req.VariantID, req.Quantity)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// Me returns the cart of the request, see owner.
+func (h *CartHandler) Me(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	cart, err := h.cartService.Find(ctx, owner)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// Validate revalidates the cart of the request against the current prices and stock, and returns the
+// changes found with the updated cart.
+func (h *CartHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	validation, err := h.cartService.Validate(ctx, owner)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, validation)
+}
+
+// AddItem adds a quantity of a product, or of one of its variants, to the cart of the request.
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		ProductID int64  `json:"product_id" validate:"required"`
+		VariantID *int64 `json:"variant_id"`
+		Quantity  int    `json:"quantity" validate:"required,gt=0"`
+	}
+
+	if err := httpx.ParseJSON(r, &req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	cart, err := h.cartService.AddItem(ctx, owner, req.ProductID, req.VariantID, req.Quantity)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// SetItemQuantity sets the quantity of a line of the cart of the request, adding it when missing. The line is the product of the path or, with ?variant_id, one of its variants.
+func (h *CartHandler) SetItemQuantity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, variantID, err := parseLine(r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	var req struct {
+		Quantity int `json:"quantity" validate:"required,gt=0"`
+	}
+
+	if err := httpx.ParseJSON(r, &req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	cart, err := h.cartService.SetItemQuantity(ctx, owner, productID, variantID, req.Quantity)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// RemoveItem removes a line of the cart of the request, selected like in SetItemQuantity.
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, variantID, err := parseLine(r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	owner, err := h.owner(w, r)
 	if err != nil {
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
 		return
 	}
 
+	cart, err := h.cartService.RemoveItem(ctx, owner, productID, variantID)
+	if err != nil {
+		writeItemError(w, err)
+		return
+	}
+
 	httpx.HTTPResponse(w, http.StatusOK, &cart)
 }
 
+// MergeGuestCart merges the guest cart of the request, if any, into the cart of the user who has
+// just signed in, and forgets the cart token.
+func (h *CartHandler) MergeGuestCart(w http.ResponseWriter, r *http.Request, userID int64) error {
+	token := guestToken(r)
+	if token == "" {
+		return nil
+	}
+	h.setGuestCookie(w, "", -1)
+
+	guestID, err := h.guestTokens.Verify(token)
+	if err != nil {
+		return nil
+	}
+	return h.cartService.MergeGuestCart(r.Context(), guestID, userID)
+}
+
+// owner returns who the cart of the request belongs to: the authenticated user or else the guest
+// of the cart token. Guests without a valid token get a new one, in a cookie and a header.
+func (h *CartHandler) owner(w http.ResponseWriter, r *http.Request) (Owner, error) {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		return Owner{UserID: userID}, nil
+	}
+
+	if guestID, err := h.guestTokens.FromRequest(r); err == nil {
+		return Owner{GuestID: guestID}, nil
+	}
+
+	token, guestID, err := h.guestTokens.New()
+	if err != nil {
+		return Owner{}, err
+	}
+	h.setGuestCookie(w, token, h.config.CartGuestTTL)
+	w.Header().Set(GuestHeaderName, token)
+	return Owner{GuestID: guestID}, nil
+}
+
+// setGuestCookie sets the cart token cookie for maxAge seconds, or deletes it when maxAge is negative.
+func (h *CartHandler) setGuestCookie(w http.ResponseWriter, token string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     GuestCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.config.AppEnv == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// parseLine reads the product of the path and the optional variant_id query parameter of a cart line.
+func parseLine(r *http.Request) (int64, *int64, error) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	v := r.URL.Query().Get("variant_id")
+	if v == "" {
+		return productID, nil, nil
+	}
+	variantID, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	return productID, &variantID, nil
+}
+
+func writeItemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+	case errors.Is(err, ErrVariantRequired), errors.Is(err, ErrVariantNotFound):
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrOutOfStock):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	default:
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
+
 func (h *CartHandler) ClearCart(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userIDStr := chi.URLParam(r, "id")
@@ -113,3 +346,92 @@ func (h *CartHandler) CompleteCart(w http.ResponseWriter, r *http.Request) {
 
 	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.OkResponse})
 }
+
+// ApplyCoupon applies a coupon code to the cart of the request.
+func (h *CartHandler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := httpx.ParseJSON(r, &req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	cart, err := h.cartService.ApplyCoupon(ctx, owner, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, promotions.ErrCouponNotFound):
+			httpx.HTTPError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, promotions.ErrCouponInactive),
+			errors.Is(err, promotions.ErrCouponExpired),
+			errors.Is(err, promotions.ErrCouponUsageLimitReached),
+			errors.Is(err, promotions.ErrMinimumSpendNotMet),
+			errors.Is(err, promotions.ErrCouponNotApplicable):
+			httpx.HTTPError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// RemoveCoupon removes a coupon code from the cart of the request.
+func (h *CartHandler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	cart, err := h.cartService.RemoveCoupon(ctx, owner, chi.URLParam(r, "code"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, &cart)
+}
+
+// ShippingOptions quotes the shipping methods that deliver the cart of the request to ?country.
+func (h *CartHandler) ShippingOptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, err := h.owner(w, r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	country := strings.ToUpper(r.URL.Query().Get("country"))
+	if len(country) != 2 {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"country": "the country query parameter must be an ISO 3166-1 alpha-2 code"})
+		return
+	}
+
+	quotes, err := h.cartService.ShippingOptions(ctx, owner, country)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, quotes)
+}
@@ -1,11 +1,18 @@
 // Package carts defines the data models for the shopping cart feature.
 package carts
 
-import "time"
+import (
+	"time"
+
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/taxes"
+)
 
 type CartItem struct {
 	CartID        int64     `json:"cart_id"`
 	ProductID     int64     `json:"product_id"`
+	VariantID     *int64    `json:"variant_id,omitempty"`
+	SKU           string    `json:"sku,omitempty"`
 	Name          string    `json:"name"`
 	Description   string    `json:"description"`
 	Quantity      int64     `json:"quantity"`
@@ -13,24 +20,61 @@ type CartItem struct {
 	DiscountRate  float64   `json:"discount_rate"` // Percentage discount applied
 	TotalPrice    int64     `json:"total_price"`   // Quantity * SnapshotPrice - Discount
 	ImageURL      string    `json:"image_url"`
+	TaxCategory   string    `json:"tax_category"`
 	AddedAt       time.Time `json:"added_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// Owner is who a cart belongs to: a user or, until they sign in, a guest.
+type Owner struct {
+	UserID  int64
+	GuestID string // Set for guests, from their cart token
+}
+
 type Cart struct {
 	ID        int64      `json:"id"`
 	UserID    int64      `json:"user_id"`
 	CartItems []CartItem `json:"cart_items"`
+	Coupons   []string   `json:"coupons"`
 
 	// Calculated fields
-	Subtotal int64 `json:"subtotal"` // Added price of all items before discounts and taxes
-	Discount int64 `json:"discount"` // Total discount applied
-	Tax      int64 `json:"tax"`
-	Total    int64 `json:"total"` // Subtotal - Discount + Tax
+	Subtotal     int64                        `json:"subtotal"` // Added price of all items before discounts and taxes
+	Discount     int64                        `json:"discount"` // Total discount applied
+	Discounts    []promotions.AppliedDiscount `json:"discounts,omitempty"`
+	FreeShipping bool                         `json:"free_shipping"`
+	Tax          int64                        `json:"tax"` // Inclusive and exclusive taxes
+	TaxLines     []taxes.TaxLine              `json:"tax_lines,omitempty"`
+	Total        int64                        `json:"total"` // Subtotal - Discount + exclusive taxes
 
 	// Metadata
-	Status    string     `json:"status"` // e.g., "active", "abandoned", "completed"
+	Status    string     `json:"status"` // e.g., "active", "abandoned", "completed", "merged" into the cart of the user
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"` // To clear abandoned carts
 }
+
+// Kinds of change found when revalidating the lines of a cart against the catalog.
+const (
+	ChangePriceChanged   = "price_changed"
+	ChangeOutOfStock     = "out_of_stock"
+	ChangeProductRemoved = "product_removed"
+)
+
+// CartChange is a line of the cart that no longer matched its product when the cart was revalidated.
+type CartChange struct {
+	Type      string `json:"type"`
+	ProductID int64  `json:"product_id"`
+	VariantID *int64 `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int64  `json:"quantity"`
+	OldPrice  int64  `json:"old_price,omitempty"` // Unit prices in cents, for price_changed
+	NewPrice  int64  `json:"new_price,omitempty"`
+	Available *int   `json:"available,omitempty"` // The stock left, for out_of_stock
+}
+
+// Validation is the cart after revalidating it, with the changes found.
+type Validation struct {
+	Valid   bool         `json:"valid"` // No changes were found
+	Changes []CartChange `json:"changes"`
+	Cart    *Cart        `json:"cart"`
+}
@@ -3,6 +3,10 @@ package carts
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
 )
 
 type CartRepository struct {
@@ -14,50 +18,142 @@ func NewCartRepository(db *sql.DB) *CartRepository {
 }
 
 func (r *CartRepository) Create(ctx context.Context, userID int64) (*Cart, error) {
-	query := "INSERT INTO carts (user_id, subtotal, total) VALUES ($1) RETURNING id"
-	_, err := r.db.ExecContext(ctx, query, userID, 0, 0)
-	if err != nil {
+	query := "INSERT INTO carts (user_id, subtotal, total) VALUES ($1, 0, 0) RETURNING id, status, created_at, updated_at"
+	cart := Cart{UserID: userID}
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&cart.ID, &cart.Status, &cart.CreatedAt, &cart.UpdatedAt); err != nil {
 		return nil, err
 	}
-	var cartID int64
-	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&cartID); err != nil {
+	return &cart, nil
+}
+
+// cartColumns reads the amounts of the cart in cents, and user_id as 0 for guest carts.
+const cartColumns = "id, COALESCE(user_id, 0), status, subtotal::BIGINT, COALESCE(discount, 0)::BIGINT, COALESCE(tax, 0)::BIGINT, total::BIGINT, created_at, updated_at, expires_at"
+
+func scanCart(row *sql.Row) (*Cart, error) {
+	var cart Cart
+	if err := row.Scan(&cart.ID, &cart.UserID, &cart.Status, &cart.Subtotal, &cart.Discount, &cart.Tax, &cart.Total, &cart.CreatedAt, &cart.UpdatedAt, &cart.ExpiresAt); err != nil {
 		return nil, err
 	}
-	return &Cart{ID: cartID, UserID: userID}, nil
+	return &cart, nil
+}
+
+func (r *CartRepository) FindByID(ctx context.Context, cartID int64) (*Cart, error) {
+	query := "SELECT " + cartColumns + " FROM carts WHERE id = $1"
+	return scanCart(r.db.QueryRowContext(ctx, query, cartID))
 }
 
 func (r *CartRepository) FindOrCreateActiveCart(ctx context.Context, userID int64) (*Cart, error) {
-	query := "SELECT * from carts WHERE user_id = $1 and status = 'active' LIMIT 1"
+	query := "SELECT " + cartColumns + " FROM carts WHERE user_id = $1 and status = 'active' LIMIT 1"
+	cart, err := scanCart(r.db.QueryRowContext(ctx, query, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.Create(ctx, userID)
+	}
+	return cart, err
+}
 
-	var cart Cart
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&cart.ID, &cart.UserID, &cart.Status, &cart.Subtotal, &cart.Discount, &cart.Tax, &cart.Total, &cart.CreatedAt, &cart.UpdatedAt)
-	if err != nil {
-		return nil, err
+// FindGuestCart returns the active cart of the guest that has not expired.
+func (r *CartRepository) FindGuestCart(ctx context.Context, guestID string) (*Cart, error) {
+	query := "SELECT " + cartColumns + " FROM carts WHERE guest_id = $1 AND status = 'active' AND (expires_at IS NULL OR expires_at > NOW()) LIMIT 1"
+	return scanCart(r.db.QueryRowContext(ctx, query, guestID))
+}
+
+// FindOrCreateGuestCart returns the active cart of the guest, creating one that expires at
+// expiresAt when missing.
+func (r *CartRepository) FindOrCreateGuestCart(ctx context.Context, guestID string, expiresAt time.Time) (*Cart, error) {
+	cart, err := r.FindGuestCart(ctx, guestID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return cart, err
 	}
 
-	if cart.ID == 0 {
-		cart, err := r.Create(ctx, userID)
+	query := "INSERT INTO carts (guest_id, subtotal, total, expires_at) VALUES ($1, 0, 0, $2) RETURNING " + cartColumns
+	return scanCart(r.db.QueryRowContext(ctx, query, guestID, expiresAt))
+}
+
+// Merge saves the items in the cart toID, replacing the lines already there, moves the coupons of
+// the cart fromID to it and marks fromID as merged, all at once.
+func (r *CartRepository) Merge(ctx context.Context, fromID, toID int64, items []CartItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
 		if err != nil {
-			return nil, err
+			tx.Rollback()
 		}
-		return cart, nil
+	}()
+
+	// Lock the guest cart so that concurrent logins merge it once
+	var status string
+	if err = tx.QueryRowContext(ctx, "SELECT status FROM carts WHERE id = $1 FOR UPDATE", fromID).Scan(&status); err != nil {
+		return err
 	}
-	return &cart, nil
+	if status != "active" {
+		err = tx.Commit()
+		return err
+	}
+
+	for i := range items {
+		if _, err = tx.ExecContext(ctx, upsertItem, toID, items[i].ProductID, items[i].VariantID, items[i].Name, items[i].Description, items[i].Quantity, items[i].SnapshotPrice, items[i].TotalPrice, items[i].ImageURL); err != nil {
+			return err
+		}
+	}
+
+	query := "INSERT INTO cart_coupons (cart_id, promotion_id) SELECT $2, promotion_id FROM cart_coupons WHERE cart_id = $1 ON CONFLICT (cart_id, promotion_id) DO NOTHING"
+	if _, err = tx.ExecContext(ctx, query, fromID, toID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE carts SET status = 'merged', updated_at = NOW() WHERE id = $1", fromID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+const upsertItem = `INSERT INTO cart_items (cart_id, product_id, variant_id, name, description, quantity, snapshot_price, total_price, image_url)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (cart_id, product_id, COALESCE(variant_id, 0)) DO UPDATE SET
+		name = EXCLUDED.name,
+		description = EXCLUDED.description,
+		quantity = EXCLUDED.quantity,
+		snapshot_price = EXCLUDED.snapshot_price,
+		total_price = EXCLUDED.total_price,
+		image_url = EXCLUDED.image_url,
+		updated_at = NOW()`
+
+// UpsertItem adds the line of the item to the cart, or replaces the quantity and prices of the line
+// already there. Prices are in cents.
+func (r *CartRepository) UpsertItem(ctx context.Context, cartID int64, item *CartItem) error {
+	_, err := r.db.ExecContext(ctx, upsertItem, cartID, item.ProductID, item.VariantID, item.Name, item.Description, item.Quantity, item.SnapshotPrice, item.TotalPrice, item.ImageURL)
+	return err
 }
 
-// UpsertItem adds an item to the cart or updates the quantity if it already exists, also deleting if quantity is zero
-func (r *CartRepository) UpsertItem(ctx context.Context, cartID int64, productID int64, quantity int) error {
-	query := "INSERT INTO cart_items (cart_id, product_id, quantity) VALUES ($1, $2, $3) ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity"
-	_, err := r.db.ExecContext(ctx, query, cartID, productID, quantity)
+// DeleteItem removes the line of the product, or of its variant, from the cart
+func (r *CartRepository) DeleteItem(ctx context.Context, cartID int64, productID int64, variantID *int64) error {
+	query := "DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND COALESCE(variant_id, 0) = COALESCE($3, 0)"
+	res, err := r.db.ExecContext(ctx, query, cartID, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
 
 // GetItems retrieves all items in the specified cart
 func (r *CartRepository) GetItems(ctx context.Context, cartID int64) ([]CartItem, error) {
-	query := "SELECT cart_id, product_id, name, description, quantity, snapshot_price, discount_rate, total_price, image_url, added_at, updated_at FROM cart_items WHERE cart_id = $1"
+	query := "SELECT ci.cart_id, ci.product_id, ci.variant_id, COALESCE(v.sku, ''), ci.name, COALESCE(ci.description, ''), ci.quantity, ci.snapshot_price::BIGINT, COALESCE(ci.discount_rate, 0), ci.total_price::BIGINT, COALESCE(ci.image_url, ''), p.tax_category, ci.added_at, ci.updated_at FROM cart_items ci JOIN products p ON p.id = ci.product_id LEFT JOIN product_variants v ON v.id = ci.variant_id WHERE ci.cart_id = $1"
 	rows, err := r.db.QueryContext(ctx, query, cartID)
 	if err != nil {
 		return nil, err
@@ -70,6 +166,8 @@ func (r *CartRepository) GetItems(ctx context.Context, cartID int64) ([]CartItem
 		if err := rows.Scan(
 			&item.CartID,
 			&item.ProductID,
+			&item.VariantID,
+			&item.SKU,
 			&item.Name,
 			&item.Description,
 			&item.Quantity,
@@ -77,6 +175,7 @@ func (r *CartRepository) GetItems(ctx context.Context, cartID int64) ([]CartItem
 			&item.DiscountRate,
 			&item.TotalPrice,
 			&item.ImageURL,
+			&item.TaxCategory,
 			&item.AddedAt,
 			&item.UpdatedAt,
 		); err != nil {
@@ -108,3 +207,62 @@ func (r *CartRepository) SetCompleted(ctx context.Context, cartID int64) error {
 
 	return nil
 }
+
+// UpdateTotals persists the calculated pricing fields of the cart
+func (r *CartRepository) UpdateTotals(ctx context.Context, cart *Cart) error {
+	query := "UPDATE carts SET subtotal = $1, discount = $2, tax = $3, total = $4, updated_at = NOW() WHERE id = $5"
+	_, err := r.db.ExecContext(ctx, query, cart.Subtotal, cart.Discount, cart.Tax, cart.Total, cart.ID)
+	return err
+}
+
+// GetCoupons retrieves the coupon codes applied to the specified cart
+func (r *CartRepository) GetCoupons(ctx context.Context, cartID int64) ([]string, error) {
+	query := "SELECT p.code FROM cart_coupons cc JOIN promotions p ON p.id = cc.promotion_id WHERE cc.cart_id = $1 ORDER BY cc.added_at"
+	rows, err := r.db.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	codes := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// AddCoupon attaches a promotion to the specified cart
+func (r *CartRepository) AddCoupon(ctx context.Context, cartID int64, promotionID int) error {
+	query := "INSERT INTO cart_coupons (cart_id, promotion_id) VALUES ($1, $2) ON CONFLICT (cart_id, promotion_id) DO NOTHING"
+	_, err := r.db.ExecContext(ctx, query, cartID, promotionID)
+	return err
+}
+
+// RemoveCoupon detaches the promotion with the given code from the specified cart
+func (r *CartRepository) RemoveCoupon(ctx context.Context, cartID int64, code string) error {
+	query := "DELETE FROM cart_coupons cc USING promotions p WHERE cc.promotion_id = p.id AND cc.cart_id = $1 AND UPPER(p.code) = UPPER($2)"
+	res, err := r.db.ExecContext(ctx, query, cartID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
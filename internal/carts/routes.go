@@ -1,9 +1,27 @@
 package carts
 
-import "github.com/go-chi/chi/v5"
+import (
+	"ecommerce-service/internal/auth"
 
-func RegisterRoutes(r chi.Router, h *CartHandler) {
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *CartHandler, am *auth.AuthMiddleware) {
 	r.Route("/carts", func(r chi.Router) {
+		// The cart of the authenticated user or, without an access token, of the guest of the cart token
+		r.Route("/me", func(r chi.Router) {
+			r.Use(am.OptionalToken)
+
+			r.Get("/", h.Me)
+			r.Post("/validate", h.Validate)
+			r.Post("/items", h.AddItem)
+			r.Put("/items/{productID}", h.SetItemQuantity)
+			r.Delete("/items/{productID}", h.RemoveItem)
+			r.Post("/coupons", h.ApplyCoupon)
+			r.Delete("/coupons/{code}", h.RemoveCoupon)
+			r.Get("/shipping-options", h.ShippingOptions)
+		})
+
 		r.Get("/{id}", h.GetCart)
 		r.Post("/{id}/items", h.AddItemToCart)
 		r.Delete("/{id}/clear", h.ClearCart)
@@ -1,48 +1,388 @@
 package carts
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/products"
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/shipping"
+	"ecommerce-service/internal/taxes"
+)
+
+var (
+	ErrVariantRequired = errors.New("the product is sold in variants, a variant_id is required")
+	ErrVariantNotFound = errors.New("the variant does not belong to the product")
+	ErrOutOfStock      = errors.New("not enough stock for the requested quantity")
+)
+
+// ChangedError is returned when the lines of a cart no longer match the catalog. The prices and the
+// removed products have already been brought up to date, so placing the order again acknowledges
+// them, while out of stock lines must be changed first.
+type ChangedError struct {
+	Changes []CartChange
+}
+
+func (e *ChangedError) Error() string {
+	return "the cart changed since it was last seen, review the changes before placing the order"
+}
 
 type (
 	Repository interface {
+		FindByID(ctx context.Context, cartID int64) (*Cart, error)
 		FindOrCreateActiveCart(ctx context.Context, userID int64) (*Cart, error)
-		UpsertItem(ctx context.Context, cartID int64, productID int64, quantity int) error
+		FindGuestCart(ctx context.Context, guestID string) (*Cart, error)
+		FindOrCreateGuestCart(ctx context.Context, guestID string, expiresAt time.Time) (*Cart, error)
+		Merge(ctx context.Context, fromID, toID int64, items []CartItem) error
+		UpsertItem(ctx context.Context, cartID int64, item *CartItem) error
+		DeleteItem(ctx context.Context, cartID int64, productID int64, variantID *int64) error
 		GetItems(ctx context.Context, cartID int64) ([]CartItem, error)
 		ClearCart(ctx context.Context, cartID int64) error
 		SetCompleted(ctx context.Context, cartID int64) error
+		UpdateTotals(ctx context.Context, cart *Cart) error
+		GetCoupons(ctx context.Context, cartID int64) ([]string, error)
+		AddCoupon(ctx context.Context, cartID int64, promotionID int) error
+		RemoveCoupon(ctx context.Context, cartID int64, code string) error
+	}
+
+	// ProductFinder loads the products put in the cart.
+	ProductFinder interface {
+		FindByID(ctx context.Context, id int) (*products.Product, error)
+	}
+
+	// Discounter resolves the promotions that apply to a cart.
+	Discounter interface {
+		ValidateCoupon(ctx context.Context, userID int64, code string, lines []promotions.Line) (*promotions.Promotion, error)
+		Evaluate(ctx context.Context, userID int64, codes []string, lines []promotions.Line) (*promotions.Evaluation, error)
+	}
+
+	// TaxCalculator calculates the taxes owed on the cart lines.
+	TaxCalculator interface {
+		Calculate(ctx context.Context, jurisdiction taxes.Jurisdiction, lines []taxes.Line) (*taxes.Result, error)
+	}
+
+	// ShippingQuoter quotes the shipping methods available for the cart.
+	ShippingQuoter interface {
+		Quote(ctx context.Context, country string, items []shipping.Item, subtotal int64, freeShipping bool) ([]shipping.Quote, error)
 	}
 
 	CartService struct {
-		cartRepo Repository
+		cartRepo       Repository
+		productFinder  ProductFinder
+		discounter     Discounter
+		taxCalculator  TaxCalculator
+		shippingQuoter ShippingQuoter
+		config         *config.Config
 	}
 )
 
-func NewCartService(cartRepo Repository) *CartService {
-	return &CartService{cartRepo: cartRepo}
+func NewCartService(cartRepo Repository, productFinder ProductFinder, discounter Discounter, taxCalculator TaxCalculator, shippingQuoter ShippingQuoter, c *config.Config) *CartService {
+	return &CartService{cartRepo: cartRepo, productFinder: productFinder, discounter: discounter, taxCalculator: taxCalculator, shippingQuoter: shippingQuoter, config: c}
 }
 
 func (s *CartService) GetCart(ctx context.Context, userID int64) (*Cart, error) {
-	return s.cartRepo.FindOrCreateActiveCart(ctx, userID)
+	cart, err := s.cartRepo.FindOrCreateActiveCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// Find returns the priced active cart of the owner, creating it when missing.
+func (s *CartService) Find(ctx context.Context, owner Owner) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// AddItemToCart adds the quantity to the line of the product, or of its variant, in the user's
+// active cart.
+func (s *CartService) AddItemToCart(ctx context.Context, userID int64, productID int64, variantID *int64, quantity int) (*Cart, error) {
+	return s.AddItem(ctx, Owner{UserID: userID}, productID, variantID, quantity)
 }
 
-func (s *CartService) AddItemToCart(ctx context.Context, userID int64, productID int64, quantity int) (*Cart, error) {
+// AddItem adds the quantity to the line of the product, or of its variant, in the owner's active cart.
+func (s *CartService) AddItem(ctx context.Context, owner Owner, productID int64, variantID *int64, quantity int) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.cartRepo.GetItems(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item := findItem(items, productID, variantID); item != nil {
+		quantity += int(item.Quantity)
+	}
+
+	return s.setItem(ctx, cart, productID, variantID, quantity)
+}
+
+// SetItemQuantity sets the quantity of the line of the product, or of its variant, in the owner's
+// active cart, adding the line when missing.
+func (s *CartService) SetItemQuantity(ctx context.Context, owner Owner, productID int64, variantID *int64, quantity int) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setItem(ctx, cart, productID, variantID, quantity)
+}
+
+// RemoveItem deletes the line of the product, or of its variant, from the owner's active cart.
+func (s *CartService) RemoveItem(ctx context.Context, owner Owner, productID int64, variantID *int64) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.DeleteItem(ctx, cart.ID, productID, variantID); err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// MergeGuestCart moves the items and coupons of the guest's active cart into the active cart of the
+// user, who has just signed in. A product in both carts is resolved with the configured merge
+// strategy; products no longer on sale are dropped and quantities are capped at the stock.
+func (s *CartService) MergeGuestCart(ctx context.Context, guestID string, userID int64) error {
+	guest, err := s.cartRepo.FindGuestCart(ctx, guestID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	guestItems, err := s.cartRepo.GetItems(ctx, guest.ID)
+	if err != nil {
+		return err
+	}
+
 	cart, err := s.cartRepo.FindOrCreateActiveCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	userItems, err := s.cartRepo.GetItems(ctx, cart.ID)
+	if err != nil {
+		return err
+	}
+
+	merged := make([]CartItem, 0, len(guestItems))
+	for _, guestItem := range guestItems {
+		quantity := int(guestItem.Quantity)
+		if userItem := findItem(userItems, guestItem.ProductID, guestItem.VariantID); userItem != nil {
+			var keep bool
+			if quantity, keep = mergeQuantity(s.config.CartMergeStrategy, int(userItem.Quantity), quantity); !keep {
+				continue
+			}
+		}
+
+		item, stock, err := s.resolveItem(ctx, guestItem.ProductID, guestItem.VariantID)
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrVariantRequired) || errors.Is(err, ErrVariantNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if quantity = min(quantity, stock); quantity <= 0 {
+			continue
+		}
+		item.setQuantity(quantity)
+		merged = append(merged, *item)
+	}
+
+	if err := s.cartRepo.Merge(ctx, guest.ID, cart.ID, merged); err != nil {
+		return err
+	}
+	return s.Price(ctx, cart, nil)
+}
+
+// mergeQuantity resolves the quantity of a product in both the user's and the guest's cart. It
+// returns false when the line of the user is kept as it is.
+func mergeQuantity(strategy string, user, guest int) (int, bool) {
+	switch strategy {
+	case MergeMax:
+		return max(user, guest), guest > user
+	case MergeUser:
+		return user, false
+	case MergeGuest:
+		return guest, true
+	default:
+		return user + guest, true
+	}
+}
+
+// activeCart returns the active cart of the user or, for a guest, the guest cart that has not
+// expired, creating it when missing.
+func (s *CartService) activeCart(ctx context.Context, owner Owner) (*Cart, error) {
+	if owner.UserID != 0 {
+		return s.cartRepo.FindOrCreateActiveCart(ctx, owner.UserID)
+	}
+	return s.cartRepo.FindOrCreateGuestCart(ctx, owner.GuestID, time.Now().Add(time.Duration(s.config.CartGuestTTL)*time.Second))
+}
+
+// Validate revalidates the cart of the owner against the catalog, see Revalidate.
+func (s *CartService) Validate(ctx context.Context, owner Owner) (*Validation, error) {
+	cart, err := s.activeCart(ctx, owner)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.cartRepo.UpsertItem(ctx, cart.ID, productID, quantity)
+	changes, err := s.Revalidate(ctx, cart)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return &Validation{Valid: len(changes) == 0, Changes: changes, Cart: cart}, nil
+}
+
+// Revalidate compares each line of the cart with the current price and stock of its product. Lines
+// whose price changed are saved at the new price and lines of products no longer on sale are
+// removed, while lines without enough stock are kept as they are. It returns what it found.
+func (s *CartService) Revalidate(ctx context.Context, cart *Cart) ([]CartChange, error) {
 	items, err := s.cartRepo.GetItems(ctx, cart.ID)
 	if err != nil {
 		return nil, err
 	}
-	cart.CartItems = items
+
+	changes := []CartChange{}
+	for _, item := range items {
+		change := CartChange{ProductID: item.ProductID, VariantID: item.VariantID, Name: item.Name, Quantity: item.Quantity}
+
+		current, stock, err := s.resolveItem(ctx, item.ProductID, item.VariantID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, ErrVariantRequired), errors.Is(err, ErrVariantNotFound):
+			if err := s.cartRepo.DeleteItem(ctx, cart.ID, item.ProductID, item.VariantID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			change.Type = ChangeProductRemoved
+			changes = append(changes, change)
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if current.SnapshotPrice != item.SnapshotPrice {
+			current.setQuantity(int(item.Quantity))
+			if err := s.cartRepo.UpsertItem(ctx, cart.ID, current); err != nil {
+				return nil, err
+			}
+			priceChange := change
+			priceChange.Type, priceChange.OldPrice, priceChange.NewPrice = ChangePriceChanged, item.SnapshotPrice, current.SnapshotPrice
+			changes = append(changes, priceChange)
+		}
+
+		if item.Quantity > int64(stock) {
+			change.Type, change.Available = ChangeOutOfStock, &stock
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// setItem checks that the product is on sale with enough stock for the quantity and saves the line
+// with the current price of the product. It returns sql.ErrNoRows when the product is not on sale.
+func (s *CartService) setItem(ctx context.Context, cart *Cart, productID int64, variantID *int64, quantity int) (*Cart, error) {
+	item, stock, err := s.resolveItem(ctx, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity > stock {
+		return nil, ErrOutOfStock
+	}
+	item.setQuantity(quantity)
+
+	if err := s.cartRepo.UpsertItem(ctx, cart.ID, item); err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
 	return cart, nil
 }
 
+// resolveItem returns the line of the product, or of its variant, at its current price, without a
+// quantity, and the stock available. It returns sql.ErrNoRows when the product is not on sale.
+func (s *CartService) resolveItem(ctx context.Context, productID int64, variantID *int64) (*CartItem, int, error) {
+	product, err := s.productFinder.FindByID(ctx, int(productID))
+	if err != nil {
+		return nil, 0, err
+	}
+	if product.Status != products.StatusActive {
+		return nil, 0, sql.ErrNoRows
+	}
+
+	price, stock := product.Price, product.Stock
+	if variantID == nil && len(product.Variants) > 0 {
+		return nil, 0, ErrVariantRequired
+	}
+	if variantID != nil {
+		i := slices.IndexFunc(product.Variants, func(v products.Variant) bool { return v.ID == *variantID })
+		if i < 0 {
+			return nil, 0, ErrVariantNotFound
+		}
+		price, stock = product.Variants[i].Price, product.Variants[i].Stock
+	}
+
+	item := &CartItem{
+		ProductID:     productID,
+		VariantID:     variantID,
+		Name:          product.Name,
+		Description:   product.Description,
+		SnapshotPrice: int64(math.Round(price * 100)),
+	}
+	if len(product.Images) > 0 {
+		item.ImageURL = product.Images[0].URL
+	}
+	return item, stock, nil
+}
+
+func (item *CartItem) setQuantity(quantity int) {
+	item.Quantity = int64(quantity)
+	item.TotalPrice = item.SnapshotPrice * item.Quantity
+}
+
+// findItem returns the line of the product, or of its variant, among the items.
+func findItem(items []CartItem, productID int64, variantID *int64) *CartItem {
+	for i := range items {
+		if items[i].ProductID == productID && sameVariant(items[i].VariantID, variantID) {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+func sameVariant(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (s *CartService) ClearCart(ctx context.Context, userID int64) error {
 	cart, err := s.cartRepo.FindOrCreateActiveCart(ctx, userID)
 	if err != nil {
@@ -60,3 +400,154 @@ func (s *CartService) CompleteCart(ctx context.Context, userID int64) error {
 
 	return s.cartRepo.SetCompleted(ctx, cart.ID)
 }
+
+// ApplyCoupon validates a coupon code against the user's active cart and attaches it.
+func (s *CartService) ApplyCoupon(ctx context.Context, owner Owner, code string) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.cartRepo.GetItems(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	promotion, err := s.discounter.ValidateCoupon(ctx, cart.UserID, code, promotionLines(items))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.AddCoupon(ctx, cart.ID, promotion.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// RemoveCoupon detaches a coupon code from the owner's active cart.
+func (s *CartService) RemoveCoupon(ctx context.Context, owner Owner, code string) (*Cart, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.RemoveCoupon(ctx, cart.ID, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// ShippingOptions quotes every shipping method that can deliver the owner's active cart to the country.
+func (s *CartService) ShippingOptions(ctx context.Context, owner Owner, country string) ([]shipping.Quote, error) {
+	cart, err := s.activeCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Price(ctx, cart, nil); err != nil {
+		return nil, err
+	}
+
+	return s.shippingQuoter.Quote(ctx, country, ShippingItems(cart.CartItems), cart.Subtotal-cart.Discount, cart.FreeShipping)
+}
+
+// Price loads the items and coupons of the cart, recalculates its totals and persists them.
+// Taxes are estimated for the default jurisdiction unless a destination is given.
+func (s *CartService) Price(ctx context.Context, cart *Cart, destination *taxes.Jurisdiction) error {
+	items, err := s.cartRepo.GetItems(ctx, cart.ID)
+	if err != nil {
+		return err
+	}
+	cart.CartItems = items
+
+	coupons, err := s.cartRepo.GetCoupons(ctx, cart.ID)
+	if err != nil {
+		return err
+	}
+	cart.Coupons = coupons
+
+	cart.Subtotal = 0
+	for _, item := range items {
+		cart.Subtotal += item.TotalPrice
+	}
+
+	evaluation, err := s.discounter.Evaluate(ctx, cart.UserID, coupons, promotionLines(items))
+	if err != nil {
+		return err
+	}
+	cart.Discount = evaluation.Discount
+	cart.Discounts = evaluation.Discounts
+	cart.FreeShipping = evaluation.FreeShipping
+
+	jurisdiction := taxes.Jurisdiction{Country: s.config.TaxDefaultCountry, Region: s.config.TaxDefaultRegion}
+	if destination != nil {
+		jurisdiction = *destination
+	}
+
+	result, err := s.taxCalculator.Calculate(ctx, jurisdiction, taxLines(items, cart.Discount))
+	if err != nil {
+		return err
+	}
+	cart.Tax = result.Total
+	cart.TaxLines = result.Lines
+
+	cart.Total = cart.Subtotal - cart.Discount + result.Added
+
+	return s.cartRepo.UpdateTotals(ctx, cart)
+}
+
+func promotionLines(items []CartItem) []promotions.Line {
+	lines := make([]promotions.Line, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, promotions.Line{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.SnapshotPrice,
+			Total:     item.TotalPrice,
+		})
+	}
+	return lines
+}
+
+// taxLines builds the taxable lines, spreading the cart discount proportionally over the items.
+func taxLines(items []CartItem, discount int64) []taxes.Line {
+	var subtotal int64
+	for _, item := range items {
+		subtotal += item.TotalPrice
+	}
+
+	lines := make([]taxes.Line, 0, len(items))
+	remaining := discount
+	for i, item := range items {
+		share := remaining
+		if i < len(items)-1 && subtotal > 0 {
+			share = discount * item.TotalPrice / subtotal
+		}
+		remaining -= share
+
+		lines = append(lines, taxes.Line{
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			TaxCategory: item.TaxCategory,
+			Amount:      item.TotalPrice - share,
+		})
+	}
+	return lines
+}
+
+// ShippingItems describes the cart items for the shipping quoter.
+func ShippingItems(items []CartItem) []shipping.Item {
+	shippingItems := make([]shipping.Item, 0, len(items))
+	for _, item := range items {
+		shippingItems = append(shippingItems, shipping.Item{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return shippingItems
+}
@@ -2,35 +2,57 @@ package categories
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/products"
 	"ecommerce-service/internal/utils"
 	"ecommerce-service/pkg/httpx"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 )
 
 type (
 	Service interface {
 		FindAll(ctx context.Context, page, limit int) ([]Category, error)
+		FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]Category, *string, *string, error)
 		FindByID(ctx context.Context, id int) (*Category, error)
 		Count(ctx context.Context) (int, error)
+		FindBySlug(ctx context.Context, slug string) (*Category, error)
+		FindTree(ctx context.Context) ([]Category, error)
+		FindBreadcrumbs(ctx context.Context, id int) ([]Category, error)
+		FindProducts(ctx context.Context, id int, descendants bool, page, limit int) ([]products.Product, error)
+		CountProducts(ctx context.Context, id int, descendants bool) (int, error)
+		Create(ctx context.Context, data CreateCategoryRequest) (*Category, error)
+		Update(ctx context.Context, id int, data UpdateCategoryRequest) (*Category, error)
+		Delete(ctx context.Context, id int, reassignTo *int) error
+		Reorder(ctx context.Context, data ReorderCategoriesRequest) ([]Category, error)
 	}
 
 	CategoryHandler struct {
 		categoryService Service
+		validate        *validator.Validate
 		config          *config.Config
 	}
 )
 
-func NewCategoryHandler(categoryService Service, config *config.Config) *CategoryHandler {
-	return &CategoryHandler{categoryService: categoryService, config: config}
+func NewCategoryHandler(categoryService Service, validate *validator.Validate, config *config.Config) *CategoryHandler {
+	return &CategoryHandler{categoryService: categoryService, validate: validate, config: config}
 }
 
+// FindAll lists categories with page/limit, or with keyset pagination when ?cursor is present
+// (empty for the first page).
 func (h *CategoryHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if r.URL.Query().Has("cursor") {
+		h.findPage(w, r)
+		return
+	}
 
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
@@ -51,6 +73,31 @@ func (h *CategoryHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	httpx.HTTPPaginatedResponse(w, http.StatusOK, categories, page, limit, total)
 }
 
+func (h *CategoryHandler) findPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	c, limit, err := utils.ParseCursorParams(r.URL.Query().Get("cursor"), r.URL.Query().Get("limit"), "", h.config.Limit, h.config.MaxLimit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categories, next, prev, err := h.categoryService.FindPage(ctx, c, limit)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if categories == nil {
+		categories = []Category{}
+	}
+
+	httpx.HTTPCursorResponse(w, http.StatusOK, categories, next, prev)
+}
+
 func (h *CategoryHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -74,3 +121,207 @@ func (h *CategoryHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 
 	httpx.HTTPResponse(w, http.StatusOK, category)
 }
+
+func (h *CategoryHandler) FindProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if _, err := h.categoryService.FindByID(ctx, id); err != nil {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return
+	}
+
+	// Products of subcategories are included unless ?descendants=false
+	descendants := r.URL.Query().Get("descendants") != "false"
+
+	pageStr := r.URL.Query().Get("page")
+	limitStr := r.URL.Query().Get("limit")
+
+	page, limit := utils.ParsePaginationParams(pageStr, limitStr, h.config.Limit, h.config.MaxLimit)
+	total, err := h.categoryService.CountProducts(ctx, id, descendants)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	list, err := h.categoryService.FindProducts(ctx, id, descendants, page, limit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if list == nil {
+		list = []products.Product{}
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, list, page, limit, total)
+}
+
+func (h *CategoryHandler) FindBySlug(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	category, err := h.categoryService.FindBySlug(ctx, chi.URLParam(r, "slug"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, category)
+}
+
+func (h *CategoryHandler) FindTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tree, err := h.categoryService.FindTree(ctx)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if tree == nil {
+		tree = []Category{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) FindBreadcrumbs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	breadcrumbs, err := h.categoryService.FindBreadcrumbs(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, breadcrumbs)
+}
+
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := CreateCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	category, err := h.categoryService.Create(ctx, req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdateCategoryRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	category, err := h.categoryService.Update(ctx, id, req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, category)
+}
+
+// Delete removes a category. Categories with products need ?reassign_to={categoryID}.
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	var reassignTo *int
+	if v := r.URL.Query().Get("reassign_to"); v != "" {
+		target, err := strconv.Atoi(v)
+		if err != nil || target <= 0 {
+			httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+			return
+		}
+		reassignTo = &target
+	}
+
+	if err := h.categoryService.Delete(ctx, id, reassignTo); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+func (h *CategoryHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := ReorderCategoriesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	categories, err := h.categoryService.Reorder(ctx, req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, categories)
+}
+
+// writeCategoryError maps the errors of the category write operations to their HTTP status.
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+	case errors.Is(err, ErrInvalidSlug), errors.Is(err, ErrParentNotFound), errors.Is(err, ErrCategoryCycle),
+		errors.Is(err, ErrInvalidReassignTarget), errors.Is(err, ErrReorderMismatch):
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrDuplicateCategory), errors.Is(err, ErrCategoryInUse), errors.Is(err, ErrCategoryHasPromotions):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	default:
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
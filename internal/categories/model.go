@@ -2,7 +2,32 @@
 package categories
 
 type Category struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
+	ID          int        `json:"id"`
+	ParentID    *int       `json:"parent_id"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Position    int        `json:"position"` // Order among the categories with the same parent
+	Children    []Category `json:"children,omitempty"`
+}
+
+type CreateCategoryRequest struct {
+	ParentID    *int   `json:"parent_id" validate:"omitempty,gt=0"`
+	Name        string `json:"name" validate:"required,min=2,max=255"`
+	Slug        string `json:"slug" validate:"omitempty,max=255"` // Generated from the name when empty
 	Description string `json:"description"`
+	Position    *int   `json:"position" validate:"omitempty,gte=0"` // Appended after its siblings when empty
+}
+
+type UpdateCategoryRequest struct {
+	ParentID    *int    `json:"parent_id" validate:"omitempty,gte=0"` // 0 moves the category to the root
+	Name        *string `json:"name" validate:"omitempty,min=2,max=255"`
+	Slug        *string `json:"slug" validate:"omitempty,min=1,max=255"`
+	Description *string `json:"description"`
+}
+
+// ReorderCategoriesRequest sets the order of the children of a parent, or of the root categories when ParentID is empty.
+type ReorderCategoriesRequest struct {
+	ParentID    *int  `json:"parent_id" validate:"omitempty,gt=0"`
+	CategoryIDs []int `json:"category_ids" validate:"required,min=1,unique,dive,gt=0"`
 }
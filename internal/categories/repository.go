@@ -3,8 +3,18 @@ package categories
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ecommerce-service/internal/utils"
+
+	"github.com/lib/pq"
 )
 
+const categoryColumns = "id, parent_id, name, slug, COALESCE(description, ''), position"
+
 type CategoryRepository struct {
 	db *sql.DB
 }
@@ -13,37 +23,318 @@ func NewCategoryRepository(db *sql.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
-func (r *CategoryRepository) FindAll(ctx context.Context, limit, offset int) ([]Category, error) {
-	query := "SELECT id, name, description FROM categories LIMIT $1 OFFSET $2"
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCategory(s scanner) (*Category, error) {
+	var category Category
+	if err := s.Scan(&category.ID, &category.ParentID, &category.Name, &category.Slug, &category.Description, &category.Position); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+func (r *CategoryRepository) queryCategories(ctx context.Context, query string, args ...any) ([]Category, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
 
 	var categories []Category
-
 	for rows.Next() {
-		var category Category
-		if err := rows.Scan(&category.ID, &category.Name, &category.Description); err != nil {
+		category, err := scanCategory(rows)
+		if err != nil {
 			return nil, err
 		}
-		categories = append(categories, category)
+		categories = append(categories, *category)
+	}
+
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepository) FindAll(ctx context.Context, limit, offset int) ([]Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories ORDER BY parent_id NULLS FIRST, position, id LIMIT $1 OFFSET $2"
+	return r.queryCategories(ctx, query, limit, offset)
+}
+
+// categoryKeyset orders categories like FindAll, root categories first.
+var categoryKeyset = utils.Keyset{Columns: []string{"COALESCE(parent_id, 0)", "position", "id"}}
+
+// FindPage returns up to limit categories past the cursor, in the direction of the cursor.
+func (r *CategoryRepository) FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]Category, error) {
+	args := []any{}
+	bind := func(arg any) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
 	}
 
-	return categories, nil
+	cond, order, err := categoryKeyset.Clause(c, bind)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + categoryColumns + " FROM categories"
+	if cond != "" {
+		query += " WHERE " + cond
+	}
+	query += " ORDER BY " + order + " LIMIT " + bind(limit)
+	return r.queryCategories(ctx, query, args...)
 }
 
 func (r *CategoryRepository) FindByID(ctx context.Context, id int) (*Category, error) {
-	query := "SELECT id, name, description FROM categories WHERE id = $1"
-	row := r.db.QueryRowContext(ctx, query, id)
-	var category Category
+	query := "SELECT " + categoryColumns + " FROM categories WHERE id = $1"
+	return scanCategory(r.db.QueryRowContext(ctx, query, id))
+}
 
-	if err := row.Scan(&category.ID, &category.Name, &category.Description); err != nil {
+func (r *CategoryRepository) FindBySlug(ctx context.Context, slug string) (*Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories WHERE slug = $1"
+	return scanCategory(r.db.QueryRowContext(ctx, query, slug))
+}
+
+// FindTree returns every category in depth-first order, siblings sorted by position.
+func (r *CategoryRepository) FindTree(ctx context.Context) ([]Category, error) {
+	query := `WITH RECURSIVE tree AS (
+			SELECT ` + categoryColumns + `, ARRAY[position, id] AS path
+			FROM categories WHERE parent_id IS NULL
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, COALESCE(c.description, ''), c.position, t.path || ARRAY[c.position, c.id]
+			FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id, parent_id, name, slug, description, position FROM tree ORDER BY path`
+	return r.queryCategories(ctx, query)
+}
+
+// FindAncestors returns the category and its ancestors, from the root down to the category.
+func (r *CategoryRepository) FindAncestors(ctx context.Context, id int) ([]Category, error) {
+	query := `WITH RECURSIVE ancestors AS (
+			SELECT ` + categoryColumns + `, 0 AS depth
+			FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, COALESCE(c.description, ''), c.position, a.depth + 1
+			FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id, parent_id, name, slug, description, position FROM ancestors ORDER BY depth DESC`
+	return r.queryCategories(ctx, query, id)
+}
+
+// FindDescendantIDs returns the ID of the category followed by the IDs of all its descendants.
+func (r *CategoryRepository) FindDescendantIDs(ctx context.Context, id int) ([]int, error) {
+	query := `WITH RECURSIVE descendants AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN descendants d ON c.parent_id = d.id
+		)
+		SELECT id FROM descendants`
+	return r.queryIDs(ctx, query, id)
+}
+
+// FindChildIDs returns the IDs of the children of the parent, or of the root categories when parentID is nil.
+func (r *CategoryRepository) FindChildIDs(ctx context.Context, parentID *int) ([]int, error) {
+	query := "SELECT id FROM categories WHERE parent_id IS NOT DISTINCT FROM $1 ORDER BY position, id"
+	return r.queryIDs(ctx, query, parentID)
+}
+
+func (r *CategoryRepository) queryIDs(ctx context.Context, query string, args ...any) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
 
-	return &category, nil
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ExistsByNameOrSlug reports whether another category already uses the name or the slug.
+func (r *CategoryRepository) ExistsByNameOrSlug(ctx context.Context, name, slug string, exceptID int) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM categories WHERE (LOWER(name) = LOWER($1) OR slug = $2) AND id <> $3)"
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, name, slug, exceptID).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// Create inserts the category; without a position it is placed after its siblings.
+func (r *CategoryRepository) Create(ctx context.Context, data CreateCategoryRequest) (int, error) {
+	query := `INSERT INTO categories (parent_id, name, slug, description, position)
+		VALUES ($1, $2, $3, $4, COALESCE($5, (SELECT COALESCE(MAX(position) + 1, 0) FROM categories WHERE parent_id IS NOT DISTINCT FROM $1)))
+		RETURNING id`
+	var id int
+	if err := r.db.QueryRowContext(ctx, query, data.ParentID, data.Name, data.Slug, data.Description, data.Position).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Update changes the given fields; moving the category to another parent places it after its new siblings.
+func (r *CategoryRepository) Update(ctx context.Context, id int, c UpdateCategoryRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	if c.ParentID != nil {
+		fields = append(fields, fmt.Sprintf("parent_id = NULLIF($%d, 0)", i))
+		fields = append(fields, fmt.Sprintf("position = (SELECT COALESCE(MAX(position) + 1, 0) FROM categories WHERE parent_id IS NOT DISTINCT FROM NULLIF($%d, 0))", i))
+		args = append(args, *c.ParentID)
+		i++
+	}
+	if c.Name != nil {
+		fields = append(fields, fmt.Sprintf("name = $%d", i))
+		args = append(args, c.Name)
+		i++
+	}
+	if c.Slug != nil {
+		fields = append(fields, fmt.Sprintf("slug = $%d", i))
+		args = append(args, c.Slug)
+		i++
+	}
+	if c.Description != nil {
+		fields = append(fields, fmt.Sprintf("description = $%d", i))
+		args = append(args, c.Description)
+		i++
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	query := fmt.Sprintf("UPDATE categories SET %s WHERE id = $%d", strings.Join(fields, ", "), i)
+	args = append(args, id)
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Reorder sets the position of each category to its index in ids in a single transaction.
+func (r *CategoryRepository) Reorder(ctx context.Context, ids []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `UPDATE categories c SET position = o.position - 1, updated_at = NOW()
+		FROM UNNEST($1::INT[]) WITH ORDINALITY AS o (id, position)
+		WHERE c.id = o.id`
+	if _, err = tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("error updating category positions: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CountProducts returns how many products are assigned directly to the category.
+func (r *CategoryRepository) CountProducts(ctx context.Context, id int) (int, error) {
+	query := "SELECT COUNT(*) FROM product_category WHERE category_id = $1"
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountPromotions returns how many promotions are scoped to the category.
+func (r *CategoryRepository) CountPromotions(ctx context.Context, id int) (int, error) {
+	query := "SELECT COUNT(*) FROM promotions WHERE category_id = $1"
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Delete removes the category in a single transaction. Its products are first moved to reassignTo,
+// when given, and its children are moved up to its parent after their new siblings.
+func (r *CategoryRepository) Delete(ctx context.Context, id int, reassignTo *int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if reassignTo != nil {
+		query := `INSERT INTO product_category (product_id, category_id)
+			SELECT product_id, $2 FROM product_category WHERE category_id = $1
+			ON CONFLICT (product_id, category_id) DO NOTHING`
+		if _, err = tx.ExecContext(ctx, query, id, *reassignTo); err != nil {
+			return fmt.Errorf("error reassigning products: %w", err)
+		}
+	}
+
+	query := `UPDATE categories SET
+			parent_id = (SELECT parent_id FROM categories WHERE id = $1),
+			position = position + (SELECT COALESCE(MAX(s.position) + 1, 0) FROM categories s
+				WHERE s.parent_id IS NOT DISTINCT FROM (SELECT parent_id FROM categories WHERE id = $1) AND s.id <> $1),
+			updated_at = NOW()
+		WHERE parent_id = $1`
+	if _, err = tx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("error moving subcategories: %w", err)
+	}
+
+	var res sql.Result
+	res, err = tx.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+
+	var rows int64
+	if rows, err = res.RowsAffected(); err != nil {
+		return err
+	}
+	if rows == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
 }
 
 func (r *CategoryRepository) Count(ctx context.Context) (int, error) {
@@ -1,10 +1,27 @@
 package categories
 
-import "github.com/go-chi/chi/v5"
+import (
+	"ecommerce-service/internal/auth"
 
-func RegisterRoutes(r chi.Router, h *CategoryHandler) {
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *CategoryHandler, am *auth.AuthMiddleware) {
 	r.Route("/categories", func(r chi.Router) {
 		r.Get("/", h.FindAll)
+		r.Get("/tree", h.FindTree)
+		r.Get("/slug/{slug}", h.FindBySlug)
 		r.Get("/{id}", h.FindByID)
+		r.Get("/{id}/breadcrumbs", h.FindBreadcrumbs)
+		r.Get("/{id}/products", h.FindProducts)
+
+		r.Group(func(r chi.Router) {
+			r.Use(am.VerifyToken, am.RequireAdmin)
+
+			r.Post("/", h.Create)
+			r.Put("/reorder", h.Reorder)
+			r.Patch("/{id}", h.Update)
+			r.Delete("/{id}", h.Delete)
+		})
 	})
 }
@@ -2,21 +2,60 @@ package categories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+
+	"ecommerce-service/internal/products"
+	"ecommerce-service/internal/utils"
+)
+
+var (
+	ErrInvalidSlug           = errors.New("the slug must contain letters or digits")
+	ErrDuplicateCategory     = errors.New("a category with the same name or slug already exists")
+	ErrParentNotFound        = errors.New("the parent category does not exist")
+	ErrCategoryCycle         = errors.New("a category cannot be moved below itself or one of its descendants")
+	ErrCategoryInUse         = errors.New("the category has products assigned, pass reassign_to to move them to another category")
+	ErrCategoryHasPromotions = errors.New("the category is used by promotions")
+	ErrInvalidReassignTarget = errors.New("products can only be reassigned to another existing category")
+	ErrReorderMismatch       = errors.New("category_ids must list every child of the parent exactly once")
 )
 
 type (
 	Repository interface {
 		FindAll(ctx context.Context, limit, offset int) ([]Category, error)
+		FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]Category, error)
 		FindByID(ctx context.Context, id int) (*Category, error)
+		FindBySlug(ctx context.Context, slug string) (*Category, error)
+		FindTree(ctx context.Context) ([]Category, error)
+		FindAncestors(ctx context.Context, id int) ([]Category, error)
+		FindDescendantIDs(ctx context.Context, id int) ([]int, error)
 		Count(ctx context.Context) (int, error)
+		FindChildIDs(ctx context.Context, parentID *int) ([]int, error)
+		ExistsByNameOrSlug(ctx context.Context, name, slug string, exceptID int) (bool, error)
+		Create(ctx context.Context, data CreateCategoryRequest) (int, error)
+		Update(ctx context.Context, id int, data UpdateCategoryRequest) error
+		Reorder(ctx context.Context, ids []int) error
+		CountProducts(ctx context.Context, id int) (int, error)
+		CountPromotions(ctx context.Context, id int) (int, error)
+		Delete(ctx context.Context, id int, reassignTo *int) error
 	}
+
+	// ProductFinder lists the products assigned to categories.
+	ProductFinder interface {
+		FindAllByCategories(ctx context.Context, categoryIDs []int, limit, page int) ([]products.Product, error)
+		CountByCategories(ctx context.Context, categoryIDs []int) (int, error)
+	}
+
 	CategoryService struct {
-		categoryRepo Repository
+		categoryRepo  Repository
+		productFinder ProductFinder
 	}
 )
 
-func NewCategoryService(categoryRepo Repository) *CategoryService {
-	return &CategoryService{categoryRepo: categoryRepo}
+func NewCategoryService(categoryRepo Repository, productFinder ProductFinder) *CategoryService {
+	return &CategoryService{categoryRepo: categoryRepo, productFinder: productFinder}
 }
 
 func (s *CategoryService) FindAll(ctx context.Context, page, limit int) ([]Category, error) {
@@ -24,10 +63,272 @@ func (s *CategoryService) FindAll(ctx context.Context, page, limit int) ([]Categ
 	return s.categoryRepo.FindAll(ctx, limit, offset)
 }
 
+// FindPage returns a keyset-paginated page of categories with the cursors of the next and previous pages.
+func (s *CategoryService) FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]Category, *string, *string, error) {
+	categories, err := s.categoryRepo.FindPage(ctx, c, limit+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	categories, next, prev := utils.CursorPage(categories, limit, c, "", func(c Category) ([]string, int64) {
+		parent := 0
+		if c.ParentID != nil {
+			parent = *c.ParentID
+		}
+		return []string{strconv.Itoa(parent), strconv.Itoa(c.Position)}, int64(c.ID)
+	})
+	return categories, next, prev, nil
+}
+
 func (s *CategoryService) FindByID(ctx context.Context, id int) (*Category, error) {
 	return s.categoryRepo.FindByID(ctx, id)
 }
 
+func (s *CategoryService) FindBySlug(ctx context.Context, slug string) (*Category, error) {
+	return s.categoryRepo.FindBySlug(ctx, slug)
+}
+
 func (s *CategoryService) Count(ctx context.Context) (int, error) {
 	return s.categoryRepo.Count(ctx)
 }
+
+// FindTree returns the root categories with their descendants nested in Children.
+func (s *CategoryService) FindTree(ctx context.Context) ([]Category, error) {
+	flat, err := s.categoryRepo.FindTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]Category)
+	for _, c := range flat {
+		parent := 0
+		if c.ParentID != nil {
+			parent = *c.ParentID
+		}
+		children[parent] = append(children[parent], c)
+	}
+
+	return nest(children, 0), nil
+}
+
+func nest(children map[int][]Category, parent int) []Category {
+	nodes := children[parent]
+	for i := range nodes {
+		nodes[i].Children = nest(children, nodes[i].ID)
+	}
+	return nodes
+}
+
+// FindBreadcrumbs returns the path from the root category down to the given one.
+func (s *CategoryService) FindBreadcrumbs(ctx context.Context, id int) ([]Category, error) {
+	breadcrumbs, err := s.categoryRepo.FindAncestors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(breadcrumbs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return breadcrumbs, nil
+}
+
+// FindProducts lists the products assigned to the category and, optionally, to its descendants.
+func (s *CategoryService) FindProducts(ctx context.Context, id int, descendants bool, page, limit int) ([]products.Product, error) {
+	ids, err := s.categoryIDs(ctx, id, descendants)
+	if err != nil {
+		return nil, err
+	}
+	return s.productFinder.FindAllByCategories(ctx, ids, limit, page)
+}
+
+func (s *CategoryService) CountProducts(ctx context.Context, id int, descendants bool) (int, error) {
+	ids, err := s.categoryIDs(ctx, id, descendants)
+	if err != nil {
+		return 0, err
+	}
+	return s.productFinder.CountByCategories(ctx, ids)
+}
+
+func (s *CategoryService) categoryIDs(ctx context.Context, id int, descendants bool) ([]int, error) {
+	if !descendants {
+		return []int{id}, nil
+	}
+	return s.categoryRepo.FindDescendantIDs(ctx, id)
+}
+
+// Create adds a category, generating its slug from the name unless one is given.
+func (s *CategoryService) Create(ctx context.Context, data CreateCategoryRequest) (*Category, error) {
+	if data.Slug == "" {
+		data.Slug = data.Name
+	}
+	data.Slug = Slugify(data.Slug)
+	if data.Slug == "" {
+		return nil, ErrInvalidSlug
+	}
+
+	if data.ParentID != nil {
+		if err := s.checkParent(ctx, *data.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	exists, err := s.categoryRepo.ExistsByNameOrSlug(ctx, data.Name, data.Slug, 0)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrDuplicateCategory
+	}
+
+	id, err := s.categoryRepo.Create(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.categoryRepo.FindByID(ctx, id)
+}
+
+// Update changes a category. A parent_id of 0 moves it to the root; it can never be moved below itself.
+func (s *CategoryService) Update(ctx context.Context, id int, data UpdateCategoryRequest) (*Category, error) {
+	current, err := s.categoryRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Slug != nil {
+		slug := Slugify(*data.Slug)
+		if slug == "" {
+			return nil, ErrInvalidSlug
+		}
+		data.Slug = &slug
+	}
+
+	if data.ParentID != nil {
+		currentParent := 0
+		if current.ParentID != nil {
+			currentParent = *current.ParentID
+		}
+
+		switch {
+		case *data.ParentID == currentParent:
+			// Keep the position when the parent does not change
+			data.ParentID = nil
+		case *data.ParentID != 0:
+			if err := s.checkParent(ctx, *data.ParentID); err != nil {
+				return nil, err
+			}
+			descendants, err := s.categoryRepo.FindDescendantIDs(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if slices.Contains(descendants, *data.ParentID) {
+				return nil, ErrCategoryCycle
+			}
+		}
+	}
+
+	if data.Name != nil || data.Slug != nil {
+		name, slug := current.Name, current.Slug
+		if data.Name != nil {
+			name = *data.Name
+		}
+		if data.Slug != nil {
+			slug = *data.Slug
+		}
+		exists, err := s.categoryRepo.ExistsByNameOrSlug(ctx, name, slug, id)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrDuplicateCategory
+		}
+	}
+
+	if err := s.categoryRepo.Update(ctx, id, data); err != nil {
+		return nil, err
+	}
+
+	return s.categoryRepo.FindByID(ctx, id)
+}
+
+// Delete removes a category. Deleting would silently unassign its products, so a category with products
+// is only deleted when reassignTo names the category they move to. Its subcategories move up one level.
+func (s *CategoryService) Delete(ctx context.Context, id int, reassignTo *int) error {
+	if _, err := s.categoryRepo.FindByID(ctx, id); err != nil {
+		return err
+	}
+
+	promotions, err := s.categoryRepo.CountPromotions(ctx, id)
+	if err != nil {
+		return err
+	}
+	if promotions > 0 {
+		return ErrCategoryHasPromotions
+	}
+
+	if reassignTo != nil {
+		if *reassignTo == id {
+			return ErrInvalidReassignTarget
+		}
+		if _, err := s.categoryRepo.FindByID(ctx, *reassignTo); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidReassignTarget
+			}
+			return err
+		}
+	} else {
+		assigned, err := s.categoryRepo.CountProducts(ctx, id)
+		if err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return ErrCategoryInUse
+		}
+	}
+
+	return s.categoryRepo.Delete(ctx, id, reassignTo)
+}
+
+// Reorder sets the order of the children of a parent, which must all be listed.
+func (s *CategoryService) Reorder(ctx context.Context, data ReorderCategoriesRequest) ([]Category, error) {
+	if data.ParentID != nil {
+		if _, err := s.categoryRepo.FindByID(ctx, *data.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	children, err := s.categoryRepo.FindChildIDs(ctx, data.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := slices.Clone(data.CategoryIDs)
+	slices.Sort(requested)
+	slices.Sort(children)
+	if !slices.Equal(requested, children) {
+		return nil, ErrReorderMismatch
+	}
+
+	if err := s.categoryRepo.Reorder(ctx, data.CategoryIDs); err != nil {
+		return nil, err
+	}
+
+	categories := make([]Category, 0, len(data.CategoryIDs))
+	for _, id := range data.CategoryIDs {
+		category, err := s.categoryRepo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	return categories, nil
+}
+
+func (s *CategoryService) checkParent(ctx context.Context, parentID int) error {
+	if _, err := s.categoryRepo.FindByID(ctx, parentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrParentNotFound
+		}
+		return err
+	}
+	return nil
+}
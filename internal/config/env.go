@@ -36,6 +36,68 @@ type Config struct {
 	JWTExp           int // in seconds
 	JWTRefreshSecret string
 	JWTRefreshExp    int // in seconds
+
+	// Taxes
+	TaxProvider       string
+	TaxDefaultCountry string
+	TaxDefaultRegion  string
+
+	// Invoices
+	InvoiceCurrency         string
+	InvoiceSellerName       string
+	InvoiceSellerTaxID      string
+	InvoiceSellerLine1      string
+	InvoiceSellerCity       string
+	InvoiceSellerPostalCode string
+	InvoiceSellerCountry    string
+
+	// Media
+	MediaStorage        string
+	MediaLocalDir       string
+	MediaBaseURL        string
+	MediaMaxUploadBytes int64
+
+	// Catalog import
+	ImportBatchSize int
+	ImportMaxBytes  int64
+
+	// Guest carts
+	CartTokenSecret   string
+	CartGuestTTL      int    // in seconds
+	CartMergeStrategy string // sum, max, user or guest
+
+	// Guest checkout
+	OrderLookupSecret string
+	OrderLookupURL    string // The signed token is appended to it
+	OrderLookupTTL    int    // in seconds
+
+	// Idempotency keys
+	IdempotencyTTL int // in seconds
+
+	// Domain events
+	EventSinks        string // Comma-separated: log, webhook
+	EventWebhookURLs  string // Comma-separated, for the webhook sink
+	EventBatchSize    int
+	EventMaxAttempts  int
+	StockLowThreshold int
+
+	// Merchant webhooks
+	WebhookMaxAttempts int
+	WebhookTimeout     int // in seconds
+
+	// Mail, written to the log when no SMTP host is set
+	MailSMTPHost     string
+	MailSMTPPort     int
+	MailSMTPUser     string
+	MailSMTPPassword string
+	MailFrom         string
+
+	// Background jobs
+	ProductPublishInterval  int // in seconds
+	BackInStockInterval     int // in seconds
+	IdempotencyCleanup      int // in seconds
+	EventDispatchInterval   int // in seconds
+	WebhookDeliveryInterval int // in seconds
 }
 
 func LoadEnvVars() *Config {
@@ -81,6 +143,92 @@ func LoadEnvVars() *Config {
 		log.Printf("⚠️ Error al leer JWT_REFRESH_EXP: %v", err)
 	}
 
+	// media
+	mediaMaxUploadBytes, err := getIntEnv("MEDIA_MAX_UPLOAD_BYTES", 5<<20)
+	if err != nil {
+		log.Printf("⚠️ Error al leer MEDIA_MAX_UPLOAD_BYTES: %v", err)
+	}
+
+	// catalog import
+	importBatchSize, err := getIntEnv("IMPORT_BATCH_SIZE", 500)
+	if err != nil {
+		log.Printf("⚠️ Error al leer IMPORT_BATCH_SIZE: %v", err)
+	}
+	importMaxBytes, err := getIntEnv("IMPORT_MAX_BYTES", 20<<20)
+	if err != nil {
+		log.Printf("⚠️ Error al leer IMPORT_MAX_BYTES: %v", err)
+	}
+
+	// guest carts
+	cartGuestTTL, err := getIntEnv("CART_GUEST_TTL", 30*24*3600)
+	if err != nil {
+		log.Printf("⚠️ Error al leer CART_GUEST_TTL: %v", err)
+	}
+
+	// guest checkout
+	orderLookupTTL, err := getIntEnv("ORDER_LOOKUP_TTL", 30*24*3600)
+	if err != nil {
+		log.Printf("⚠️ Error al leer ORDER_LOOKUP_TTL: %v", err)
+	}
+
+	// idempotency keys
+	idempotencyTTL, err := getIntEnv("IDEMPOTENCY_TTL", 24*3600)
+	if err != nil {
+		log.Printf("⚠️ Error al leer IDEMPOTENCY_TTL: %v", err)
+	}
+
+	// domain events
+	eventBatchSize, err := getIntEnv("EVENT_BATCH_SIZE", 100)
+	if err != nil {
+		log.Printf("⚠️ Error al leer EVENT_BATCH_SIZE: %v", err)
+	}
+	eventMaxAttempts, err := getIntEnv("EVENT_MAX_ATTEMPTS", 10)
+	if err != nil {
+		log.Printf("⚠️ Error al leer EVENT_MAX_ATTEMPTS: %v", err)
+	}
+	stockLowThreshold, err := getIntEnv("STOCK_LOW_THRESHOLD", 5)
+	if err != nil {
+		log.Printf("⚠️ Error al leer STOCK_LOW_THRESHOLD: %v", err)
+	}
+
+	// merchant webhooks
+	webhookMaxAttempts, err := getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		log.Printf("⚠️ Error al leer WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+	webhookTimeout, err := getIntEnv("WEBHOOK_TIMEOUT", 10)
+	if err != nil {
+		log.Printf("⚠️ Error al leer WEBHOOK_TIMEOUT: %v", err)
+	}
+
+	// mail
+	mailSMTPPort, err := getIntEnv("MAIL_SMTP_PORT", 587)
+	if err != nil {
+		log.Printf("⚠️ Error al leer MAIL_SMTP_PORT: %v", err)
+	}
+
+	// background jobs
+	productPublishInterval, err := getIntEnv("PRODUCT_PUBLISH_INTERVAL", 60)
+	if err != nil {
+		log.Printf("⚠️ Error al leer PRODUCT_PUBLISH_INTERVAL: %v", err)
+	}
+	backInStockInterval, err := getIntEnv("BACK_IN_STOCK_INTERVAL", 300)
+	if err != nil {
+		log.Printf("⚠️ Error al leer BACK_IN_STOCK_INTERVAL: %v", err)
+	}
+	idempotencyCleanup, err := getIntEnv("IDEMPOTENCY_CLEANUP_INTERVAL", 3600)
+	if err != nil {
+		log.Printf("⚠️ Error al leer IDEMPOTENCY_CLEANUP_INTERVAL: %v", err)
+	}
+	eventDispatchInterval, err := getIntEnv("EVENT_DISPATCH_INTERVAL", 5)
+	if err != nil {
+		log.Printf("⚠️ Error al leer EVENT_DISPATCH_INTERVAL: %v", err)
+	}
+	webhookDeliveryInterval, err := getIntEnv("WEBHOOK_DELIVERY_INTERVAL", 5)
+	if err != nil {
+		log.Printf("⚠️ Error al leer WEBHOOK_DELIVERY_INTERVAL: %v", err)
+	}
+
 	cfg := &Config{
 		AppName: os.Getenv("APP_NAME"),
 		AppEnv:  getEnv("APP_ENV", "development"),
@@ -104,6 +252,57 @@ func LoadEnvVars() *Config {
 		JWTExp:           JWTExp,
 		JWTRefreshSecret: getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key"),
 		JWTRefreshExp:    JWTRefreshExp,
+
+		TaxProvider:       getEnv("TAX_PROVIDER", "table"),
+		TaxDefaultCountry: getEnv("TAX_DEFAULT_COUNTRY", "ES"),
+		TaxDefaultRegion:  os.Getenv("TAX_DEFAULT_REGION"),
+
+		InvoiceCurrency:         getEnv("INVOICE_CURRENCY", "EUR"),
+		InvoiceSellerName:       getEnv("INVOICE_SELLER_NAME", "Ecommerce Service S.L."),
+		InvoiceSellerTaxID:      os.Getenv("INVOICE_SELLER_TAX_ID"),
+		InvoiceSellerLine1:      os.Getenv("INVOICE_SELLER_LINE1"),
+		InvoiceSellerCity:       os.Getenv("INVOICE_SELLER_CITY"),
+		InvoiceSellerPostalCode: os.Getenv("INVOICE_SELLER_POSTAL_CODE"),
+		InvoiceSellerCountry:    getEnv("INVOICE_SELLER_COUNTRY", "ES"),
+
+		MediaStorage:        getEnv("MEDIA_STORAGE", "local"),
+		MediaLocalDir:       getEnv("MEDIA_LOCAL_DIR", "./uploads"),
+		MediaBaseURL:        getEnv("MEDIA_BASE_URL", "/media"),
+		MediaMaxUploadBytes: int64(mediaMaxUploadBytes),
+
+		ImportBatchSize: importBatchSize,
+		ImportMaxBytes:  int64(importMaxBytes),
+
+		CartTokenSecret:   getEnv("CART_TOKEN_SECRET", "your-cart-secret-key"),
+		CartGuestTTL:      cartGuestTTL,
+		CartMergeStrategy: getEnv("CART_MERGE_STRATEGY", "sum"),
+
+		OrderLookupSecret: getEnv("ORDER_LOOKUP_SECRET", "your-order-lookup-secret-key"),
+		OrderLookupURL:    getEnv("ORDER_LOOKUP_URL", "http://localhost:8080/orders/lookup/"),
+		OrderLookupTTL:    orderLookupTTL,
+
+		IdempotencyTTL: idempotencyTTL,
+
+		EventSinks:        getEnv("EVENT_SINKS", "log"),
+		EventWebhookURLs:  os.Getenv("EVENT_WEBHOOK_URLS"),
+		EventBatchSize:    eventBatchSize,
+		EventMaxAttempts:  eventMaxAttempts,
+		StockLowThreshold: stockLowThreshold,
+
+		WebhookMaxAttempts: webhookMaxAttempts,
+		WebhookTimeout:     webhookTimeout,
+
+		MailSMTPHost:     os.Getenv("MAIL_SMTP_HOST"),
+		MailSMTPPort:     mailSMTPPort,
+		MailSMTPUser:     os.Getenv("MAIL_SMTP_USER"),
+		MailSMTPPassword: os.Getenv("MAIL_SMTP_PASSWORD"),
+		MailFrom:         getEnv("MAIL_FROM", "no-reply@ecommerce.local"),
+
+		ProductPublishInterval:  productPublishInterval,
+		BackInStockInterval:     backInStockInterval,
+		IdempotencyCleanup:      idempotencyCleanup,
+		EventDispatchInterval:   eventDispatchInterval,
+		WebhookDeliveryInterval: webhookDeliveryInterval,
 	}
 
 	return cfg
@@ -0,0 +1,7 @@
+-- +migration no-transaction
+CREATE TABLE IF NOT EXISTS cart_coupons (
+    cart_id INT NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+    promotion_id INT NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cart_id, promotion_id)
+);
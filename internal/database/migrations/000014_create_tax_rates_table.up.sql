@@ -0,0 +1,13 @@
+-- +migration no-transaction
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    country CHAR(2) NOT NULL,
+    region VARCHAR(50) NOT NULL DEFAULT '',
+    tax_category VARCHAR(50) NOT NULL DEFAULT '',
+    rate NUMERIC(6, 4) NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (country, region, tax_category)
+);
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_category VARCHAR(50) NOT NULL DEFAULT 'standard';
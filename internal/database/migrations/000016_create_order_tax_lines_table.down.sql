@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_tax_lines;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax;
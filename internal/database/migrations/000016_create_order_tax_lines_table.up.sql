@@ -0,0 +1,15 @@
+-- +migration no-transaction
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS order_tax_lines (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    country CHAR(2) NOT NULL,
+    region VARCHAR(50) NOT NULL DEFAULT '',
+    rate NUMERIC(6, 4) NOT NULL,
+    taxable_amount NUMERIC(12, 2) NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS billing_address;
+ALTER TABLE orders ALTER COLUMN shipping_address TYPE TEXT USING concat_ws(', ', shipping_address->>'line1', shipping_address->>'line2', shipping_address->>'city', shipping_address->>'postal_code', shipping_address->>'country');
@@ -0,0 +1,5 @@
+-- Legacy free-text addresses are kept in line1 of the snapshot
+ALTER TABLE orders ALTER COLUMN shipping_address TYPE JSONB USING jsonb_build_object('line1', shipping_address);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_address JSONB;
+UPDATE orders SET billing_address = shipping_address WHERE billing_address IS NULL;
+ALTER TABLE orders ALTER COLUMN billing_address SET NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS weight_grams INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS length_cm NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS width_cm NUMERIC(8, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS height_cm NUMERIC(8, 2) NOT NULL DEFAULT 0;
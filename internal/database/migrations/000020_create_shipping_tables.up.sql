@@ -0,0 +1,26 @@
+-- +migration no-transaction
+CREATE TABLE IF NOT EXISTS shipping_zones (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    countries CHAR(2)[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+    id SERIAL PRIMARY KEY,
+    zone_id INT NOT NULL REFERENCES shipping_zones (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    carrier VARCHAR(100) NOT NULL DEFAULT '',
+    type VARCHAR(50) NOT NULL,
+    base_rate BIGINT NOT NULL DEFAULT 0,
+    per_kg_rate BIGINT NOT NULL DEFAULT 0,
+    free_threshold BIGINT NOT NULL DEFAULT 0,
+    max_weight_grams INT,
+    min_delivery_days INT NOT NULL DEFAULT 0,
+    max_delivery_days INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (zone_id, name)
+);
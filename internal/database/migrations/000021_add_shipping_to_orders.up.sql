@@ -0,0 +1,4 @@
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS shipping_method_id INT REFERENCES shipping_methods (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS shipping_method VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS shipping_cost NUMERIC(12, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,51 @@
+-- +migration no-transaction
+-- Last number issued in every series and year; the row is locked while an invoice is issued
+-- so numbers are consecutive and a rolled back invoice does not leave a gap.
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    series VARCHAR(10) NOT NULL,
+    year INT NOT NULL,
+    last_number INT NOT NULL,
+    PRIMARY KEY (series, year)
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id BIGSERIAL PRIMARY KEY,
+    number VARCHAR(30) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('invoice', 'credit_note')),
+    series VARCHAR(10) NOT NULL,
+    year INT NOT NULL,
+    sequence INT NOT NULL,
+    order_id BIGINT NOT NULL REFERENCES orders (id),
+    corrects_invoice_id BIGINT REFERENCES invoices (id),
+    seller JSONB NOT NULL,
+    buyer JSONB NOT NULL,
+    currency CHAR(3) NOT NULL,
+    subtotal NUMERIC(12, 2) NOT NULL,
+    tax NUMERIC(12, 2) NOT NULL,
+    total NUMERIC(12, 2) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (series, year, sequence)
+);
+
+-- An order is invoiced once; refunds are issued as credit notes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_order ON invoices (order_id) WHERE type = 'invoice';
+CREATE INDEX IF NOT EXISTS idx_invoices_corrects ON invoices (corrects_invoice_id);
+
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    id BIGSERIAL PRIMARY KEY,
+    invoice_id BIGINT NOT NULL REFERENCES invoices (id) ON DELETE CASCADE,
+    product_id BIGINT,
+    credits_line_id BIGINT REFERENCES invoice_lines (id),
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price NUMERIC(12, 4) NOT NULL,
+    net_amount NUMERIC(12, 2) NOT NULL,
+    tax_name VARCHAR(100) NOT NULL DEFAULT '',
+    tax_rate NUMERIC(6, 4) NOT NULL DEFAULT 0,
+    tax_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    total NUMERIC(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice ON invoice_lines (invoice_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_credits ON invoice_lines (credits_line_id);
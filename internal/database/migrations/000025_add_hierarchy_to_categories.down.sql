@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_categories_parent;
+DROP INDEX IF EXISTS idx_categories_slug;
+ALTER TABLE categories DROP COLUMN IF EXISTS position;
+ALTER TABLE categories DROP COLUMN IF EXISTS slug;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
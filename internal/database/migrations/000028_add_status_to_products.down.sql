@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_products_unpublish_at;
+DROP INDEX IF EXISTS idx_products_publish_at;
+DROP INDEX IF EXISTS idx_products_status;
+DROP INDEX IF EXISTS idx_products_name;
+
+ALTER TABLE products ADD CONSTRAINT products_name_key UNIQUE (name);
+ALTER TABLE products ADD CONSTRAINT products_name_unique UNIQUE (name);
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS unpublish_at,
+    DROP COLUMN IF EXISTS publish_at,
+    DROP COLUMN IF EXISTS status;
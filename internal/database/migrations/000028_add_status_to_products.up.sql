@@ -0,0 +1,14 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('draft', 'active', 'archived')),
+    ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Deleted products keep their name, so it only has to be unique among the rest
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_name_key;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_name_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_name ON products (name) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_products_status ON products (status) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_publish_at ON products (publish_at) WHERE status = 'draft' AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_products_unpublish_at ON products (unpublish_at) WHERE status = 'active' AND deleted_at IS NULL;
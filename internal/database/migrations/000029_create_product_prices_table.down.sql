@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_product_prices_product;
+DROP TABLE IF EXISTS product_prices;
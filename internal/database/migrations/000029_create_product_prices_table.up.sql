@@ -0,0 +1,19 @@
+-- +migration no-transaction
+CREATE TABLE IF NOT EXISTS product_prices (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price NUMERIC(10, 2) NOT NULL CHECK (price > 0),
+    kind VARCHAR(10) NOT NULL DEFAULT 'regular' CHECK (kind IN ('regular', 'sale')),
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ, -- Required for sales; regular prices last until the next one starts
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (valid_to IS NULL OR valid_to > valid_from),
+    CHECK ((kind = 'sale') = (valid_to IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices (product_id, kind, valid_from DESC);
+
+-- The current prices start the history
+INSERT INTO product_prices (product_id, price, kind, valid_from)
+SELECT id, price, 'regular', COALESCE(created_at, NOW()) FROM products
+WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = products.id);
@@ -0,0 +1,7 @@
+ALTER TABLE products
+    DROP COLUMN IF EXISTS rating_count,
+    DROP COLUMN IF EXISTS rating_sum;
+
+DROP INDEX IF EXISTS idx_reviews_status;
+DROP INDEX IF EXISTS idx_reviews_product;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_wishlist_items_notify;
+DROP INDEX IF EXISTS idx_wishlist_items_line;
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
@@ -0,0 +1,23 @@
+-- +migration no-transaction
+CREATE TABLE IF NOT EXISTS wishlists (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    share_token VARCHAR(64) UNIQUE, -- Set while the list is shared through a public link
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id BIGSERIAL PRIMARY KEY,
+    wishlist_id BIGINT NOT NULL REFERENCES wishlists (id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES product_variants (id) ON DELETE CASCADE,
+    notify_in_stock BOOLEAN NOT NULL DEFAULT FALSE,
+    in_stock BOOLEAN NOT NULL DEFAULT TRUE, -- Last availability seen, to notify when it comes back
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlist_items_line ON wishlist_items (wishlist_id, product_id, COALESCE(variant_id, 0));
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_notify ON wishlist_items (product_id) WHERE notify_in_stock;
@@ -0,0 +1,6 @@
+DELETE FROM carts WHERE user_id IS NULL;
+
+DROP INDEX IF EXISTS idx_carts_guest;
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_owner_check;
+ALTER TABLE carts DROP COLUMN IF EXISTS guest_id;
+ALTER TABLE carts ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,8 @@
+-- +migration no-transaction
+-- Guest carts belong to a shopper without an account, identified by a signed cart token, until
+-- they are merged into the cart of the user on login
+ALTER TABLE carts ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS guest_id VARCHAR(64);
+ALTER TABLE carts ADD CONSTRAINT carts_owner_check CHECK (user_id IS NOT NULL OR guest_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_carts_guest ON carts (guest_id) WHERE status = 'active';
@@ -0,0 +1,11 @@
+DELETE FROM promotion_redemptions WHERE user_id IS NULL;
+ALTER TABLE promotion_redemptions ALTER COLUMN user_id SET NOT NULL;
+
+DELETE FROM orders WHERE user_id IS NULL;
+
+DROP INDEX IF EXISTS idx_orders_guest_customer;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_owner_check;
+ALTER TABLE orders DROP COLUMN IF EXISTS guest_customer_id;
+ALTER TABLE orders ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS guest_customers;
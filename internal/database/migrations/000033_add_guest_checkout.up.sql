@@ -0,0 +1,21 @@
+-- +migration no-transaction
+-- Guest customers place orders with an email instead of an account, and can later claim them into
+-- a new account
+CREATE TABLE IF NOT EXISTS guest_customers (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    user_id INT REFERENCES users (id) ON DELETE SET NULL, -- Set once the orders are claimed
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guest_customers_email ON guest_customers (LOWER(email));
+
+ALTER TABLE orders ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS guest_customer_id BIGINT REFERENCES guest_customers (id);
+ALTER TABLE orders ADD CONSTRAINT orders_owner_check CHECK (user_id IS NOT NULL OR guest_customer_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_orders_guest_customer ON orders (guest_customer_id);
+
+-- Promotions used by guests count towards the total usage limit only
+ALTER TABLE promotion_redemptions ALTER COLUMN user_id DROP NOT NULL;
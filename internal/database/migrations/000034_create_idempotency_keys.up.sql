@@ -0,0 +1,17 @@
+-- +migration no-transaction
+-- Responses of requests sent with an Idempotency-Key header, replayed when the request is retried.
+-- The scope is the endpoint and the user or guest of the caller, so keys of different callers never
+-- meet, and a NULL status_code marks a request still in progress
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(64) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status_code INT,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys (expires_at);
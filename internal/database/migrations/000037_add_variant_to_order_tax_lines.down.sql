@@ -0,0 +1 @@
+ALTER TABLE order_tax_lines DROP COLUMN IF EXISTS variant_id;
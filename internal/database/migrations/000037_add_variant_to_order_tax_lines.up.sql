@@ -0,0 +1,3 @@
+-- +migration no-transaction
+-- An order can hold several variants of a product, each with its own tax line
+ALTER TABLE order_tax_lines ADD COLUMN IF NOT EXISTS variant_id BIGINT;
@@ -0,0 +1,3 @@
+-- +migration no-transaction
+-- The cart-level discount of the order, so that invoices can show it
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount NUMERIC(12, 2) NOT NULL DEFAULT 0;
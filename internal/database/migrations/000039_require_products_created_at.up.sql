@@ -0,0 +1,4 @@
+-- +migration no-transaction
+-- The catalog keyset pages by (created_at, id), which cannot compare rows without a creation date
+UPDATE products SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+ALTER TABLE products ALTER COLUMN created_at SET NOT NULL;
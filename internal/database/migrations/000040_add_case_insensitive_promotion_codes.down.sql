@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_promotions_code_upper;
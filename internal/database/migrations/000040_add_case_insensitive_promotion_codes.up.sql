@@ -0,0 +1,3 @@
+-- +migration no-transaction
+-- Coupon codes are looked up regardless of case, so they must also be unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotions_code_upper ON promotions (UPPER(code));
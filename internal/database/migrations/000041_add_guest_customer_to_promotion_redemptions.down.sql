@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_promotion_redemptions_guest_customer;
+ALTER TABLE promotion_redemptions DROP COLUMN IF EXISTS guest_customer_id;
@@ -0,0 +1,14 @@
+-- +migration no-transaction
+-- Promotions used by guests count towards the per-user usage limit of the guest customer of their
+-- email, and of the account it is claimed into
+ALTER TABLE promotion_redemptions ADD COLUMN IF NOT EXISTS guest_customer_id BIGINT REFERENCES guest_customers (id) ON DELETE CASCADE;
+
+UPDATE promotion_redemptions r SET guest_customer_id = o.guest_customer_id
+FROM orders o
+WHERE o.id = r.order_id AND o.guest_customer_id IS NOT NULL AND r.guest_customer_id IS NULL;
+
+UPDATE promotion_redemptions r SET user_id = o.user_id
+FROM orders o
+WHERE o.id = r.order_id AND o.user_id IS NOT NULL AND r.user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_guest_customer ON promotion_redemptions (promotion_id, guest_customer_id);
@@ -69,7 +69,7 @@ func SeedCartItems(db *sql.DB) error {
 			ImageURL:      "https://example.com/smartphone.jpg",
 		},
 	}
-	query := "INSERT INTO cart_items (cart_id, product_id, name, description, quantity, snapshot_price, discount_rate, total_price, image_url) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (cart_id, product_id) DO NOTHING"
+	query := "INSERT INTO cart_items (cart_id, product_id, name, description, quantity, snapshot_price, discount_rate, total_price, image_url) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (cart_id, product_id, COALESCE(variant_id, 0)) DO NOTHING"
 
 	for _, item := range cartItems {
 		if _, err := db.Exec(query, item.CartID, item.ProductID, item.Name, item.Description, item.Quantity, item.SnapshotPrice, item.DiscountRate, item.TotalPrice, item.ImageURL); err != nil {
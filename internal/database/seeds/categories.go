@@ -6,17 +6,37 @@ import (
 )
 
 func SeedCategories(db *sql.DB) error {
-	categories := []categories.Category{
-		{Name: "Electronics", Description: "Devices and gadgets"},
-		{Name: "Books", Description: "Printed and digital books"},
-		{Name: "Clothing", Description: "Apparel and accessories"},
-		{Name: "Home & Kitchen", Description: "Household items and kitchenware"},
-		{Name: "Sports & Outdoors", Description: "Sporting goods and outdoor equipment"},
+	roots := []categories.Category{
+		{Name: "Electronics", Description: "Devices and gadgets", Position: 0},
+		{Name: "Books", Description: "Printed and digital books", Position: 1},
+		{Name: "Clothing", Description: "Apparel and accessories", Position: 2},
+		{Name: "Home & Kitchen", Description: "Household items and kitchenware", Position: 3},
+		{Name: "Sports & Outdoors", Description: "Sporting goods and outdoor equipment", Position: 4},
 	}
-	query := "INSERT INTO categories (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING"
+	query := "INSERT INTO categories (name, slug, description, position) VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO NOTHING"
 
-	for _, category := range categories {
-		if _, err := db.Exec(query, category.Name, category.Description); err != nil {
+	for _, category := range roots {
+		if _, err := db.Exec(query, category.Name, categories.Slugify(category.Name), category.Description, category.Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeedSubcategories adds a second level below the root categories, which must be seeded first.
+func SeedSubcategories(db *sql.DB) error {
+	subcategories := []struct {
+		Parent string
+		categories.Category
+	}{
+		{Parent: "Electronics", Category: categories.Category{Name: "Computers", Description: "Laptops and desktops", Position: 0}},
+		{Parent: "Electronics", Category: categories.Category{Name: "Phones", Description: "Smartphones and accessories", Position: 1}},
+		{Parent: "Sports & Outdoors", Category: categories.Category{Name: "Running", Description: "Running shoes and gear", Position: 0}},
+	}
+	query := "INSERT INTO categories (parent_id, name, slug, description, position) SELECT id, $2, $3, $4, $5 FROM categories WHERE name = $1 ON CONFLICT (name) DO NOTHING"
+
+	for _, sub := range subcategories {
+		if _, err := db.Exec(query, sub.Parent, sub.Name, categories.Slugify(sub.Name), sub.Description, sub.Position); err != nil {
 			return err
 		}
 	}
@@ -2,6 +2,8 @@ package seeds
 
 import (
 	"database/sql"
+
+	"ecommerce-service/internal/addresses"
 	"ecommerce-service/internal/orders"
 )
 
@@ -11,18 +13,18 @@ func SeedOrders(db *sql.DB) error {
 			UserID:          1,
 			Status:          "pending",
 			Total:           1443,
-			ShippingAddress: "123 Main St, Anytown, USA",
+			ShippingAddress: addresses.PostalAddress{Name: "Super Admin", Line1: "123 Main St", City: "Anytown", Region: "CA", PostalCode: "90001", Country: "US"},
 			PaymentMethod:   "credit_card",
 		},
 		{
 			UserID:          2,
 			Status:          "shipped",
 			Total:           944,
-			ShippingAddress: "456 Oak Ave, Anytown, USA",
+			ShippingAddress: addresses.PostalAddress{Name: "Admin", Line1: "456 Oak Ave", City: "Anytown", Region: "NY", PostalCode: "10001", Country: "US"},
 			PaymentMethod:   "paypal",
 		},
 	}
-	query := "INSERT INTO orders (user_id, status, total, shipping_address, payment_method) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING"
+	query := "INSERT INTO orders (user_id, status, total, shipping_address, billing_address, payment_method) VALUES ($1, $2, $3, $4, $4, $5) ON CONFLICT DO NOTHING"
 
 	for _, order := range orders {
 		if _, err := db.Exec(query, order.UserID, order.Status, order.Total, order.ShippingAddress, order.PaymentMethod); err != nil {
@@ -35,19 +37,19 @@ func SeedOrders(db *sql.DB) error {
 func SeedOrderItems(db *sql.DB) error {
 	orderItems := []orders.OrderItem{
 		{
-			OrderID:  1,
+			OrderID:   1,
 			ProductID: 1,
 			Quantity:  1,
 			Price:     1200.00,
 		},
 		{
-			OrderID:  1,
+			OrderID:   1,
 			ProductID: 3,
 			Quantity:  1,
 			Price:     150.00,
 		},
 		{
-			OrderID:  2,
+			OrderID:   2,
 			ProductID: 2,
 			Quantity:  1,
 			Price:     800.00,
@@ -17,6 +17,9 @@ func SeedProductCategory(db *sql.DB) error {
 		{ProductID: 4, CategoryID: 3}, // Running Shoes -> Clothing
 		{ProductID: 4, CategoryID: 5}, // Running Shoes -> Sports & Outdoors
 		{ProductID: 5, CategoryID: 2}, // Novel -> Books
+		{ProductID: 1, CategoryID: 6}, // Laptop -> Electronics > Computers
+		{ProductID: 2, CategoryID: 7}, // Smartphone -> Electronics > Phones
+		{ProductID: 4, CategoryID: 8}, // Running Shoes -> Sports & Outdoors > Running
 	}
 
 	query := "INSERT INTO product_category (product_id, category_id) VALUES ($1, $2) ON CONFLICT (product_id, category_id) DO NOTHING"
@@ -0,0 +1,66 @@
+package seeds
+
+import (
+	"database/sql"
+
+	"ecommerce-service/internal/products"
+)
+
+// Running Shoes are sold in sizes and colours
+const variantProductID = 4
+
+func SeedProductOptions(db *sql.DB) error {
+	options := []products.CreateOptionRequest{
+		{Name: "size", Values: []string{"40", "41", "42", "43"}},
+		{Name: "colour", Values: []string{"Black", "White"}},
+	}
+	typeQuery := "INSERT INTO product_option_types (product_id, name, position) VALUES ($1, $2, $3) ON CONFLICT (product_id, name) DO UPDATE SET position = EXCLUDED.position RETURNING id"
+	valueQuery := "INSERT INTO product_option_values (option_type_id, value, position) VALUES ($1, $2, $3) ON CONFLICT (option_type_id, value) DO NOTHING"
+
+	for i, option := range options {
+		var typeID int
+		if err := db.QueryRow(typeQuery, variantProductID, option.Name, i).Scan(&typeID); err != nil {
+			return err
+		}
+		for j, value := range option.Values {
+			if _, err := db.Exec(valueQuery, typeID, value, j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func SeedProductVariants(db *sql.DB) error {
+	salePrice := 99.00
+	variants := []products.CreateVariantRequest{
+		{SKU: "RUN-40-BLK", Stock: 10, Options: map[string]string{"size": "40", "colour": "Black"}},
+		{SKU: "RUN-41-BLK", Stock: 15, Options: map[string]string{"size": "41", "colour": "Black"}},
+		{SKU: "RUN-42-BLK", Stock: 12, Options: map[string]string{"size": "42", "colour": "Black"}},
+		{SKU: "RUN-42-WHT", Stock: 8, Options: map[string]string{"size": "42", "colour": "White"}},
+		{SKU: "RUN-43-WHT", Stock: 10, PriceOverride: &salePrice, Options: map[string]string{"size": "43", "colour": "White"}},
+	}
+	variantQuery := "INSERT INTO product_variants (product_id, sku, price, stock) VALUES ($1, $2, $3, $4) ON CONFLICT (sku) DO NOTHING RETURNING id"
+	optionQuery := `INSERT INTO product_variant_options (variant_id, option_value_id)
+		SELECT $1, ov.id FROM product_option_values ov
+		JOIN product_option_types ot ON ot.id = ov.option_type_id
+		WHERE ot.product_id = $2 AND ot.name = $3 AND ov.value = $4
+		ON CONFLICT DO NOTHING`
+
+	for _, v := range variants {
+		var variantID int64
+		err := db.QueryRow(variantQuery, variantProductID, v.SKU, v.PriceOverride, v.Stock).Scan(&variantID)
+		if err == sql.ErrNoRows {
+			continue // Already seeded
+		}
+		if err != nil {
+			return err
+		}
+		for name, value := range v.Options {
+			if _, err := db.Exec(optionQuery, variantID, variantProductID, name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
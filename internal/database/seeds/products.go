@@ -14,11 +14,11 @@ func SeedProducts(db *sql.DB) error {
 	description5 := "A best-selling novel"
 
 	products := []products.Product{
-		{Name: "Laptop", Description: description1, Price: 1200.00, Stock: 12},
-		{Name: "Smartphone", Description: description2, Price: 800.00, Stock: 22},
-		{Name: "Coffee Maker", Description: description3, Price: 150.00, Stock: 32},
-		{Name: "Running Shoes", Description: description4, Price: 120.00, Stock: 55},
-		{Name: "Novel", Description: description5, Price: 20.00, Stock: 65},
+		{Name: "Laptop", Description: description1, Price: 1200.00, Stock: 12, WeightGrams: 2100, LengthCm: 36, WidthCm: 25, HeightCm: 3},
+		{Name: "Smartphone", Description: description2, Price: 800.00, Stock: 22, WeightGrams: 200, LengthCm: 16, WidthCm: 8, HeightCm: 1},
+		{Name: "Coffee Maker", Description: description3, Price: 150.00, Stock: 32, WeightGrams: 3500, LengthCm: 30, WidthCm: 25, HeightCm: 40},
+		{Name: "Running Shoes", Description: description4, Price: 120.00, Stock: 55, WeightGrams: 800, LengthCm: 33, WidthCm: 22, HeightCm: 12},
+		{Name: "Novel", Description: description5, Price: 20.00, Stock: 65, WeightGrams: 400, LengthCm: 23, WidthCm: 15, HeightCm: 3},
 	}
 
 	// Initialize stock values
@@ -28,10 +28,10 @@ func SeedProducts(db *sql.DB) error {
 	products[3].Stock = 150
 	products[4].Stock = 300
 
-	query := "INSERT INTO products (name, description, price, stock) VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO NOTHING"
+	query := "INSERT INTO products (name, description, price, stock, weight_grams, length_cm, width_cm, height_cm) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (name) WHERE deleted_at IS NULL DO NOTHING"
 
 	for _, product := range products {
-		if _, err := db.Exec(query, product.Name, product.Description, product.Price, product.Stock); err != nil {
+		if _, err := db.Exec(query, product.Name, product.Description, product.Price, product.Stock, product.WeightGrams, product.LengthCm, product.WidthCm, product.HeightCm); err != nil {
 			return err
 		}
 	}
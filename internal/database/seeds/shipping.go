@@ -0,0 +1,43 @@
+package seeds
+
+import (
+	"database/sql"
+
+	"ecommerce-service/internal/shipping"
+
+	"github.com/lib/pq"
+)
+
+func SeedShippingZones(db *sql.DB) error {
+	zones := []shipping.Zone{
+		{Name: "Spain", Countries: []string{"ES"}},
+		{Name: "European Union", Countries: []string{"PT", "FR", "DE", "IT", "BE", "NL", "IE", "AT"}},
+		{Name: "North America", Countries: []string{"US", "CA", "MX"}},
+	}
+	query := "INSERT INTO shipping_zones (name, countries) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING"
+
+	for _, zone := range zones {
+		if _, err := db.Exec(query, zone.Name, pq.Array(zone.Countries)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SeedShippingMethods(db *sql.DB) error {
+	maxParcel := 30000
+	methods := []shipping.Method{
+		{ZoneID: 1, Name: "Standard", Carrier: "Correos", Type: shipping.TypeFreeOverThreshold, BaseRate: 495, FreeThreshold: 5000, MinDeliveryDays: 2, MaxDeliveryDays: 4},
+		{ZoneID: 1, Name: "Express", Carrier: "SEUR", Type: shipping.TypeWeightBased, BaseRate: 795, PerKgRate: 100, MaxWeightGrams: &maxParcel, MinDeliveryDays: 1, MaxDeliveryDays: 1},
+		{ZoneID: 2, Name: "Standard", Carrier: "DHL", Type: shipping.TypeWeightBased, BaseRate: 1295, PerKgRate: 250, MaxWeightGrams: &maxParcel, MinDeliveryDays: 3, MaxDeliveryDays: 6},
+		{ZoneID: 3, Name: "International", Carrier: "UPS", Type: shipping.TypeFlatRate, BaseRate: 2995, MinDeliveryDays: 5, MaxDeliveryDays: 10},
+	}
+	query := "INSERT INTO shipping_methods (zone_id, name, carrier, type, base_rate, per_kg_rate, free_threshold, max_weight_grams, min_delivery_days, max_delivery_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (zone_id, name) DO NOTHING"
+
+	for _, m := range methods {
+		if _, err := db.Exec(query, m.ZoneID, m.Name, m.Carrier, m.Type, m.BaseRate, m.PerKgRate, m.FreeThreshold, m.MaxWeightGrams, m.MinDeliveryDays, m.MaxDeliveryDays); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package seeds
+
+import (
+	"database/sql"
+
+	"ecommerce-service/internal/taxes"
+)
+
+func SeedTaxRates(db *sql.DB) error {
+	rates := []taxes.TaxRate{
+		{Name: "IVA", Country: "ES", Rate: 0.21, Inclusive: true},
+		{Name: "IVA reducido", Country: "ES", TaxCategory: "reduced", Rate: 0.10, Inclusive: true},
+		{Name: "IVA superreducido", Country: "ES", TaxCategory: "books", Rate: 0.04, Inclusive: true},
+		{Name: "IGIC", Country: "ES", Region: "CN", Rate: 0.07, Inclusive: true},
+		{Name: "IGIC", Country: "ES", Region: "CN", TaxCategory: "books", Rate: 0, Inclusive: true},
+		{Name: "Sales Tax", Country: "US", Region: "CA", Rate: 0.0725},
+		{Name: "Sales Tax", Country: "US", Region: "NY", Rate: 0.04},
+	}
+	query := "INSERT INTO tax_rates (name, country, region, tax_category, rate, inclusive) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (country, region, tax_category) DO NOTHING"
+
+	for _, rate := range rates {
+		if _, err := db.Exec(query, rate.Name, rate.Country, rate.Region, rate.TaxCategory, rate.Rate, rate.Inclusive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
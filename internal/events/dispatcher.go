@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ecommerce-service/internal/config"
+)
+
+const (
+	// maxBackoff caps the wait between attempts to publish an event.
+	maxBackoff = time.Hour
+	// claimLease is how long other dispatchers leave a claimed batch alone. A dispatcher that dies
+	// halfway delays its events by as much, and a slower batch may be published twice.
+	claimLease = 5 * time.Minute
+)
+
+type (
+	Repository interface {
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+		MarkPublished(ctx context.Context, id int64) error
+		MarkFailed(ctx context.Context, id int64, retryAt *time.Time, lastError string) error
+	}
+
+	// Sink receives the published events. Events are delivered at least once, so sinks may see an
+	// event again after a failed attempt of another sink.
+	Sink interface {
+		Publish(ctx context.Context, e Event) error
+	}
+
+	Dispatcher struct {
+		outboxRepo Repository
+		sinks      []Sink
+		config     *config.Config
+	}
+)
+
+func NewDispatcher(repo Repository, c *config.Config, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{outboxRepo: repo, sinks: sinks, config: c}
+}
+
+// RunDispatch publishes the pending events every interval until ctx is done.
+func (d *Dispatcher) RunDispatch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Dispatch(ctx); err != nil {
+			log.Printf("error dispatching events: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch publishes a batch of pending events to every sink and returns how many were published.
+// Failed events are retried with exponential backoff until the last attempt, and block the later
+// events of their aggregate meanwhile.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	events, err := d.outboxRepo.Claim(ctx, max(d.config.EventBatchSize, 1), claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, e := range events {
+		if err := d.publish(ctx, e); err != nil {
+			var retryAt *time.Time
+			if e.Attempts+1 < d.config.EventMaxAttempts {
+				at := time.Now().Add(backoff(e.Attempts))
+				retryAt = &at
+			} else {
+				log.Printf("giving up on event %d (%s) after %d attempts: %v\n", e.ID, e.Type, e.Attempts+1, err)
+			}
+			if err := d.outboxRepo.MarkFailed(ctx, e.ID, retryAt, err.Error()); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := d.outboxRepo.MarkPublished(ctx, e.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+func (d *Dispatcher) publish(ctx context.Context, e Event) error {
+	var errs []error
+	for _, sink := range d.sinks {
+		if err := sink.Publish(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backoff returns the wait after the given number of failed attempts: 1s, 2s, 4s... up to maxBackoff.
+func backoff(attempts int) time.Duration {
+	if attempts >= 12 {
+		return maxBackoff
+	}
+	return min(time.Second<<attempts, maxBackoff)
+}
@@ -0,0 +1,63 @@
+// Package events records domain events in an outbox table, in the same transaction as the change
+// they describe, and publishes them to sinks once the change is committed.
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types.
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status_changed"
+	UserRegistered     = "user.registered"
+	StockLow           = "product.stock_low"
+)
+
+// Aggregate types, events of the same aggregate are published in the order they were recorded.
+const (
+	AggregateOrder   = "order"
+	AggregateUser    = "user"
+	AggregateProduct = "product"
+)
+
+type Event struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Attempts      int             `json:"-"` // Failed publishing attempts so far
+}
+
+// Payloads of the events.
+type (
+	OrderCreatedData struct {
+		OrderID         int64   `json:"order_id"`
+		UserID          int64   `json:"user_id,omitempty"`
+		GuestCustomerID *int64  `json:"guest_customer_id,omitempty"`
+		Status          string  `json:"status"`
+		Total           float64 `json:"total"`
+		Items           int     `json:"items"`
+	}
+
+	OrderStatusChangedData struct {
+		OrderID int64  `json:"order_id"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+	}
+
+	UserRegisteredData struct {
+		UserID int64  `json:"user_id"`
+		Email  string `json:"email"`
+	}
+
+	StockLowData struct {
+		ProductID int64  `json:"product_id"`
+		VariantID *int64 `json:"variant_id,omitempty"`
+		Stock     int    `json:"stock"`
+		Threshold int    `json:"threshold"`
+	}
+)
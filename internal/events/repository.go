@@ -0,0 +1,100 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+)
+
+// Execer runs a statement, within a transaction or on the database.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Record adds an event to the outbox. Pass the transaction of the change the event describes, so
+// that the event is recorded if and only if the change is committed.
+func Record(ctx context.Context, tx Execer, aggregateType string, aggregateID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4)"
+	_, err = tx.ExecContext(ctx, query, aggregateType, aggregateID, eventType, data)
+	return err
+}
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Claim leases up to limit events due for publishing, oldest first, so that other dispatchers skip
+// them until the lease ends. An event is only due once every earlier event of its aggregate has been
+// published or given up on.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	query := `UPDATE outbox_events SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT e.id FROM outbox_events e
+			WHERE e.published_at IS NULL AND e.failed_at IS NULL AND e.next_attempt_at <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM outbox_events p
+					WHERE p.aggregate_type = e.aggregate_type AND p.aggregate_id = e.aggregate_id AND p.id < e.id
+						AND p.published_at IS NULL AND p.failed_at IS NULL
+				)
+			ORDER BY e.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts`
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateType, &e.AggregateID, &e.Payload, &e.OccurredAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(events, func(a, b Event) int { return cmp.Compare(a.ID, b.ID) })
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE outbox_events SET published_at = NOW(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+// MarkFailed records a failed attempt to publish the event, to be retried at retryAt or, without it,
+// given up on.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, retryAt *time.Time, lastError string) error {
+	query := `UPDATE outbox_events SET
+			attempts = attempts + 1,
+			last_error = $3,
+			next_attempt_at = COALESCE($2, next_attempt_at),
+			failed_at = CASE WHEN $2::TIMESTAMPTZ IS NULL THEN NOW() END
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, retryAt, lastError)
+	return err
+}
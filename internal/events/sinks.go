@@ -0,0 +1,87 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// LogSink writes the events to the log.
+type LogSink struct{}
+
+func (LogSink) Publish(_ context.Context, e Event) error {
+	log.Printf("event %d %s on %s %d: %s\n", e.ID, e.Type, e.AggregateType, e.AggregateID, e.Payload)
+	return nil
+}
+
+// Handler reacts to an event published in process.
+type Handler func(ctx context.Context, e Event) error
+
+// Bus delivers the events to the handlers subscribed to their type in this process.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+// Subscribe calls h with every event of the type, or of every type when eventType is "*".
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[e.Type]...), b.handlers["*"]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil {
+			return fmt.Errorf("handler of %s: %w", e.Type, err)
+		}
+	}
+	return nil
+}
+
+// WebhookSink posts the events as JSON to a fixed list of URLs.
+type WebhookSink struct {
+	client *http.Client
+	urls   []string
+}
+
+func NewWebhookSink(client *http.Client, urls ...string) *WebhookSink {
+	return &WebhookSink{client: client, urls: urls}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range s.urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("posting event to %s: %w", url, err)
+		}
+		res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return fmt.Errorf("posting event to %s: status %d", url, res.StatusCode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package fulfillment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/orders"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		FindOrder(ctx context.Context, orderID int64) (*orders.Order, error)
+		FindByOrderID(ctx context.Context, orderID int64) ([]Shipment, error)
+		Create(ctx context.Context, orderID int64, req *CreateShipmentRequest) (*Shipment, error)
+		Update(ctx context.Context, id int64, req UpdateShipmentRequest) (*Shipment, error)
+	}
+
+	FulfillmentHandler struct {
+		fulfillmentService Service
+		validate           *validator.Validate
+	}
+)
+
+func NewFulfillmentHandler(fulfillmentService Service, validate *validator.Validate) *FulfillmentHandler {
+	return &FulfillmentHandler{fulfillmentService: fulfillmentService, validate: validate}
+}
+
+// FindByOrderID lists the shipments of one of the authenticated user's orders, or of any order for admins.
+func (h *FulfillmentHandler) FindByOrderID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	order, err := h.fulfillmentService.FindOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	// Do not reveal whether orders of other users exist
+	if order.UserID != userID && !auth.IsAdmin(ctx) {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return
+	}
+
+	shipments, err := h.fulfillmentService.FindByOrderID(ctx, orderID)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if shipments == nil {
+		shipments = []Shipment{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, shipments)
+}
+
+func (h *FulfillmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := CreateShipmentRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	shipment, err := h.fulfillmentService.Create(ctx, orderID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrUnknownOrderItem), errors.Is(err, ErrDuplicateOrderItem), errors.Is(err, ErrQuantityExceedsRemaining):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrOrderNotFulfillable), errors.Is(err, ErrNothingToFulfill):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, shipment)
+}
+
+func (h *FulfillmentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateShipmentRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdateShipmentRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	shipment, err := h.fulfillmentService.Update(ctx, id, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, shipment)
+}
@@ -0,0 +1,54 @@
+// Package fulfillment records the shipments that deliver the items of an order.
+package fulfillment
+
+import "time"
+
+const (
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+)
+
+type Shipment struct {
+	ID             int64          `json:"id"`
+	OrderID        int64          `json:"order_id"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         string         `json:"status"`
+	Items          []ShipmentItem `json:"items"`
+	ShippedAt      time.Time      `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt      *time.Time     `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time     `json:"updated_at,omitempty"`
+}
+
+type ShipmentItem struct {
+	OrderItemID int64 `json:"order_item_id" validate:"required"`
+	Quantity    int   `json:"quantity" validate:"required,gt=0"`
+}
+
+// CreateShipmentRequest ships the given items, or every unshipped item when Items is empty.
+type CreateShipmentRequest struct {
+	Carrier        string         `json:"carrier" validate:"required,max=100"`
+	TrackingNumber string         `json:"tracking_number" validate:"max=100"`
+	Items          []ShipmentItem `json:"items" validate:"dive"`
+	ShippedAt      *time.Time     `json:"shipped_at"`
+}
+
+type UpdateShipmentRequest struct {
+	Carrier        *string    `json:"carrier,omitempty" validate:"omitempty,max=100"`
+	TrackingNumber *string    `json:"tracking_number,omitempty" validate:"omitempty,max=100"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// OrderLine is an order item with the quantity already shipped.
+type OrderLine struct {
+	OrderItemID int64
+	Quantity    int
+	Shipped     int
+}
+
+// Remaining is the quantity still to be shipped.
+func (l OrderLine) Remaining() int {
+	return l.Quantity - l.Shipped
+}
@@ -0,0 +1,212 @@
+package fulfillment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type FulfillmentRepository struct {
+	db *sql.DB
+}
+
+func NewFulfillmentRepository(db *sql.DB) *FulfillmentRepository {
+	return &FulfillmentRepository{db: db}
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// FindOrderLines returns the items of the order with the quantity shipped so far.
+func (r *FulfillmentRepository) FindOrderLines(ctx context.Context, orderID int64) ([]OrderLine, error) {
+	return findOrderLines(ctx, r.db, orderID)
+}
+
+func findOrderLines(ctx context.Context, q queryer, orderID int64) ([]OrderLine, error) {
+	query := `SELECT oi.id, oi.quantity, COALESCE(SUM(si.quantity), 0)
+		FROM order_items oi
+		LEFT JOIN shipment_items si ON si.order_item_id = oi.id
+		WHERE oi.order_id = $1
+		GROUP BY oi.id, oi.quantity
+		ORDER BY oi.id`
+	rows, err := q.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var lines []OrderLine
+	for rows.Next() {
+		var l OrderLine
+		if err := rows.Scan(&l.OrderItemID, &l.Quantity, &l.Shipped); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+// Create inserts the shipment and its items in a single transaction. The items of the shipment are
+// checked against what is left to ship while the order is locked, so that concurrent shipments
+// cannot ship an item twice; without items, everything left is shipped.
+func (r *FulfillmentRepository) Create(ctx context.Context, s *Shipment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, "SELECT id FROM orders WHERE id = $1 FOR UPDATE", s.OrderID); err != nil {
+		return fmt.Errorf("error locking order: %w", err)
+	}
+
+	lines, err := findOrderLines(ctx, tx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	if s.Items, err = shipmentItems(lines, s.Items); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO shipments (order_id, carrier, tracking_number, shipped_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at"
+	err = tx.QueryRowContext(ctx, query, s.OrderID, s.Carrier, s.TrackingNumber, s.ShippedAt).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting shipment: %w", err)
+	}
+
+	itemStmt, err := tx.PrepareContext(ctx, "INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES ($1, $2, $3)")
+	if err != nil {
+		return fmt.Errorf("error preparing shipment item statement: %w", err)
+	}
+	defer itemStmt.Close()
+
+	for i, item := range s.Items {
+		if _, err = itemStmt.ExecContext(ctx, s.ID, item.OrderItemID, item.Quantity); err != nil {
+			return fmt.Errorf("error inserting shipment item #%d: %w", i+1, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *FulfillmentRepository) FindByID(ctx context.Context, id int64) (*Shipment, error) {
+	shipments, err := r.findShipments(ctx, "s.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &shipments[0], nil
+}
+
+func (r *FulfillmentRepository) FindByOrderID(ctx context.Context, orderID int64) ([]Shipment, error) {
+	return r.findShipments(ctx, "s.order_id = $1", orderID)
+}
+
+func (r *FulfillmentRepository) findShipments(ctx context.Context, where string, arg any) ([]Shipment, error) {
+	query := `SELECT s.id, s.order_id, s.carrier, s.tracking_number, s.shipped_at, s.delivered_at, s.created_at, s.updated_at, si.order_item_id, si.quantity
+		FROM shipments s
+		JOIN shipment_items si ON si.shipment_id = s.id
+		WHERE ` + where + `
+		ORDER BY s.id, si.order_item_id`
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var shipments []Shipment
+	for rows.Next() {
+		var s Shipment
+		var item ShipmentItem
+		if err := rows.Scan(&s.ID, &s.OrderID, &s.Carrier, &s.TrackingNumber, &s.ShippedAt, &s.DeliveredAt, &s.CreatedAt, &s.UpdatedAt, &item.OrderItemID, &item.Quantity); err != nil {
+			return nil, err
+		}
+
+		if n := len(shipments); n > 0 && shipments[n-1].ID == s.ID {
+			shipments[n-1].Items = append(shipments[n-1].Items, item)
+			continue
+		}
+
+		s.Status = StatusShipped
+		if s.DeliveredAt != nil {
+			s.Status = StatusDelivered
+		}
+		s.Items = []ShipmentItem{item}
+		shipments = append(shipments, s)
+	}
+
+	return shipments, rows.Err()
+}
+
+func (r *FulfillmentRepository) Update(ctx context.Context, id int64, s UpdateShipmentRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	if s.Carrier != nil {
+		fields = append(fields, fmt.Sprintf("carrier = $%d", i))
+		args = append(args, s.Carrier)
+		i++
+	}
+	if s.TrackingNumber != nil {
+		fields = append(fields, fmt.Sprintf("tracking_number = $%d", i))
+		args = append(args, s.TrackingNumber)
+		i++
+	}
+	if s.ShippedAt != nil {
+		fields = append(fields, fmt.Sprintf("shipped_at = $%d", i))
+		args = append(args, s.ShippedAt)
+		i++
+	}
+	if s.DeliveredAt != nil {
+		fields = append(fields, fmt.Sprintf("delivered_at = $%d", i))
+		args = append(args, s.DeliveredAt)
+		i++
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	query := fmt.Sprintf("UPDATE shipments SET %s WHERE id = $%d", strings.Join(fields, ", "), i)
+	args = append(args, id)
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
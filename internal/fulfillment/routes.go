@@ -0,0 +1,17 @@
+package fulfillment
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *FulfillmentHandler, am *auth.AuthMiddleware) {
+	r.Group(func(r chi.Router) {
+		r.Use(am.VerifyToken)
+
+		r.With(am.IdentifyAdmin).Get("/orders/{id}/shipments", h.FindByOrderID)
+		r.With(am.RequireAdmin).Post("/orders/{id}/shipments", h.Create)
+		r.With(am.RequireAdmin).Patch("/shipments/{id}", h.Update)
+	})
+}
@@ -0,0 +1,191 @@
+package fulfillment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecommerce-service/internal/orders"
+)
+
+var (
+	ErrOrderNotFulfillable      = errors.New("the order cannot be fulfilled in its current status")
+	ErrNothingToFulfill         = errors.New("every item of the order has already been shipped")
+	ErrUnknownOrderItem         = errors.New("the item does not belong to the order")
+	ErrDuplicateOrderItem       = errors.New("the item is listed more than once in the shipment")
+	ErrQuantityExceedsRemaining = errors.New("the quantity exceeds what is left to ship for the item")
+)
+
+type (
+	Repository interface {
+		FindOrderLines(ctx context.Context, orderID int64) ([]OrderLine, error)
+		Create(ctx context.Context, s *Shipment) error
+		FindByID(ctx context.Context, id int64) (*Shipment, error)
+		FindByOrderID(ctx context.Context, orderID int64) ([]Shipment, error)
+		Update(ctx context.Context, id int64, s UpdateShipmentRequest) error
+	}
+
+	// OrderService gives access to the orders being fulfilled.
+	OrderService interface {
+		FindByID(ctx context.Context, id int) (*orders.Order, error)
+		Update(ctx context.Context, id int, o *orders.UpdateOrderRequest) error
+	}
+
+	FulfillmentService struct {
+		fulfillmentRepo Repository
+		orderService    OrderService
+	}
+)
+
+func NewFulfillmentService(fulfillmentRepo Repository, orderService OrderService) *FulfillmentService {
+	return &FulfillmentService{fulfillmentRepo: fulfillmentRepo, orderService: orderService}
+}
+
+// FindOrder returns the order the shipments belong to.
+func (s *FulfillmentService) FindOrder(ctx context.Context, orderID int64) (*orders.Order, error) {
+	return s.orderService.FindByID(ctx, int(orderID))
+}
+
+func (s *FulfillmentService) FindByOrderID(ctx context.Context, orderID int64) ([]Shipment, error) {
+	return s.fulfillmentRepo.FindByOrderID(ctx, orderID)
+}
+
+// Create ships some or all of the remaining items of the order and updates the order status.
+func (s *FulfillmentService) Create(ctx context.Context, orderID int64, req *CreateShipmentRequest) (*Shipment, error) {
+	order, err := s.orderService.FindByID(ctx, int(orderID))
+	if err != nil {
+		return nil, err
+	}
+	switch order.Status {
+	case orders.StatusPending, orders.StatusProcessing, orders.StatusPartiallyShipped:
+	default:
+		return nil, ErrOrderNotFulfillable
+	}
+
+	// The repository checks the items against what is left to ship
+	shipment := &Shipment{
+		OrderID:        orderID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Status:         StatusShipped,
+		Items:          req.Items,
+		ShippedAt:      time.Now(),
+	}
+	if req.ShippedAt != nil {
+		shipment.ShippedAt = *req.ShippedAt
+	}
+
+	if err := s.fulfillmentRepo.Create(ctx, shipment); err != nil {
+		return nil, err
+	}
+
+	if err := s.syncOrderStatus(ctx, orderID); err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// Update records tracking changes or the delivery of a shipment and updates the order status.
+func (s *FulfillmentService) Update(ctx context.Context, id int64, req UpdateShipmentRequest) (*Shipment, error) {
+	if err := s.fulfillmentRepo.Update(ctx, id, req); err != nil {
+		return nil, err
+	}
+
+	shipment, err := s.fulfillmentRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DeliveredAt != nil {
+		if err := s.syncOrderStatus(ctx, shipment.OrderID); err != nil {
+			return nil, err
+		}
+	}
+
+	return shipment, nil
+}
+
+// shipmentItems checks the requested items against what is left to ship.
+// Without requested items, everything left is shipped.
+func shipmentItems(lines []OrderLine, requested []ShipmentItem) ([]ShipmentItem, error) {
+	if len(requested) == 0 {
+		items := []ShipmentItem{}
+		for _, l := range lines {
+			if l.Remaining() > 0 {
+				items = append(items, ShipmentItem{OrderItemID: l.OrderItemID, Quantity: l.Remaining()})
+			}
+		}
+		if len(items) == 0 {
+			return nil, ErrNothingToFulfill
+		}
+		return items, nil
+	}
+
+	remaining := make(map[int64]int, len(lines))
+	for _, l := range lines {
+		remaining[l.OrderItemID] = l.Remaining()
+	}
+
+	listed := make(map[int64]bool, len(requested))
+	for _, item := range requested {
+		if listed[item.OrderItemID] {
+			return nil, ErrDuplicateOrderItem
+		}
+		listed[item.OrderItemID] = true
+
+		left, ok := remaining[item.OrderItemID]
+		if !ok {
+			return nil, ErrUnknownOrderItem
+		}
+		if item.Quantity > left {
+			return nil, ErrQuantityExceedsRemaining
+		}
+		remaining[item.OrderItemID] = left - item.Quantity
+	}
+
+	return requested, nil
+}
+
+// syncOrderStatus derives the order status from what has been shipped and delivered.
+func (s *FulfillmentService) syncOrderStatus(ctx context.Context, orderID int64) error {
+	lines, err := s.fulfillmentRepo.FindOrderLines(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	shipments, err := s.fulfillmentRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	fullyShipped := true
+	for _, l := range lines {
+		if l.Remaining() > 0 {
+			fullyShipped = false
+			break
+		}
+	}
+
+	delivered := fullyShipped
+	for _, sh := range shipments {
+		if sh.DeliveredAt == nil {
+			delivered = false
+			break
+		}
+	}
+
+	status := orders.StatusPartiallyShipped
+	switch {
+	case delivered:
+		status = orders.StatusDelivered
+	case fullyShipped:
+		status = orders.StatusShipped
+	}
+
+	return s.orderService.Update(ctx, int(orderID), &orders.UpdateOrderRequest{Status: &status})
+}
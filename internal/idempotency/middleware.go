@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/pkg/httpx"
+)
+
+type (
+	Service interface {
+		Begin(ctx context.Context, scope, key, fingerprint string) (*Record, error)
+		Complete(ctx context.Context, rec *Record) error
+		Release(ctx context.Context, scope, key string) error
+	}
+
+	// GuestIdentifier identifies the guests, who have no access token, by their cart token.
+	GuestIdentifier interface {
+		FromRequest(r *http.Request) (string, error)
+	}
+
+	// UserIdentifier returns the authenticated user of the request context, if any.
+	UserIdentifier func(ctx context.Context) (int64, bool)
+
+	IdempotencyMiddleware struct {
+		idempotencyService Service
+		guests             GuestIdentifier
+		users              UserIdentifier
+	}
+
+	// recorder keeps a copy of the response written by the handler.
+	recorder struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+func NewIdempotencyMiddleware(s Service, g GuestIdentifier, u UserIdentifier) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{idempotencyService: s, guests: g, users: u}
+}
+
+// Handle makes the requests with an Idempotency-Key header safe to retry. The first request with a
+// key runs and its response is stored, unless it failed with a server error or a conflict; later
+// requests with the key and the same body get the stored response back. Requests without the header
+// run as usual. On authenticated routes it goes after the token check.
+func (im *IdempotencyMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderName)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			httpx.HTTPError(w, http.StatusBadRequest, "the idempotency key must be at most 255 characters long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The response is stored even if the client goes away, so that its retry gets it
+		ctx := context.WithoutCancel(r.Context())
+		scope := im.scope(r)
+		seen, err := im.idempotencyService.Begin(ctx, scope, key, digest(body))
+		switch {
+		case errors.Is(err, ErrKeyReused):
+			httpx.HTTPError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		case errors.Is(err, ErrInProgress):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+			return
+		case err != nil:
+			log.Printf("error reserving idempotency key: %v\n", err)
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+			return
+		case seen != nil:
+			replay(w, seen)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				if err := im.idempotencyService.Release(ctx, scope, key); err != nil {
+					log.Printf("error releasing idempotency key: %v\n", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		// Server errors and conflicts depend on a state that can change, such as a cart whose changes
+		// the client acknowledges by placing the order again, so their retries run the request again
+		if rec.status >= http.StatusInternalServerError || rec.status == http.StatusConflict {
+			return
+		}
+		status := rec.status
+		err = im.idempotencyService.Complete(ctx, &Record{
+			Scope:        scope,
+			Key:          key,
+			StatusCode:   &status,
+			ContentType:  rec.Header().Get("Content-Type"),
+			ResponseBody: rec.body.Bytes(),
+		})
+		if err != nil {
+			log.Printf("error storing idempotent response: %v\n", err)
+			return
+		}
+		completed = true
+	})
+}
+
+func replay(w http.ResponseWriter, seen *Record) {
+	if seen.ContentType != "" {
+		w.Header().Set("Content-Type", seen.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(*seen.StatusCode)
+	if _, err := w.Write(seen.ResponseBody); err != nil {
+		log.Printf("error replaying idempotent response: %v\n", err)
+	}
+}
+
+// scope identifies the endpoint and the caller, by its user or its guest, so that a key only replays
+// responses to whoever sent the request first, even after refreshing its access token.
+func (im *IdempotencyMiddleware) scope(r *http.Request) string {
+	caller := ""
+	if userID, ok := im.users(r.Context()); ok {
+		caller = "user " + strconv.FormatInt(userID, 10)
+	}
+	if guestID, err := im.guests.FromRequest(r); err == nil {
+		caller += "\nguest " + guestID
+	}
+	return digest([]byte(r.Method + " " + r.URL.Path + "\n" + caller))
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,24 @@
+// Package idempotency lets clients retry POST requests safely: the response to a request sent with
+// an Idempotency-Key header is stored and replayed when the same request comes again.
+package idempotency
+
+import "time"
+
+// HeaderName is the request header with the key chosen by the client, and ReplayedHeader marks the
+// responses replayed from a stored one.
+const (
+	HeaderName     = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Record is a request seen with an idempotency key and, once it has been handled, its response.
+type Record struct {
+	Scope        string
+	Key          string
+	Fingerprint  string // SHA-256 of the request body
+	StatusCode   *int   // Unset while the request is in progress
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
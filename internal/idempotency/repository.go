@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve records the request in progress unless the key is already in use, taking over keys that
+// have expired. It returns whether the key was reserved.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, rec *Record) (bool, error) {
+	query := `INSERT INTO idempotency_keys (scope, key, fingerprint, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			content_type = NULL,
+			response_body = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query, rec.Scope, rec.Key, rec.Fingerprint, rec.ExpiresAt).Scan(&rec.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *IdempotencyRepository) Find(ctx context.Context, scope, key string) (*Record, error) {
+	query := "SELECT scope, key, fingerprint, status_code, COALESCE(content_type, ''), response_body, created_at, expires_at FROM idempotency_keys WHERE scope = $1 AND key = $2"
+	var rec Record
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(&rec.Scope, &rec.Key, &rec.Fingerprint, &rec.StatusCode, &rec.ContentType, &rec.ResponseBody, &rec.CreatedAt, &rec.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Complete stores the response of the request in progress.
+func (r *IdempotencyRepository) Complete(ctx context.Context, rec *Record) error {
+	query := "UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5 WHERE scope = $1 AND key = $2"
+	_, err := r.db.ExecContext(ctx, query, rec.Scope, rec.Key, rec.StatusCode, rec.ContentType, rec.ResponseBody)
+	return err
+}
+
+// Release forgets the request in progress, so that it can be retried.
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL", scope, key)
+	return err
+}
+
+// DeleteExpired removes the keys whose window has passed and returns how many were removed.
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"ecommerce-service/internal/config"
+)
+
+var (
+	ErrKeyReused  = errors.New("the idempotency key was already used with a different request body")
+	ErrInProgress = errors.New("a request with the same idempotency key is still in progress")
+)
+
+// maxReserveAttempts bounds the retries of Begin when the key keeps being released under it.
+const maxReserveAttempts = 3
+
+type (
+	Repository interface {
+		Reserve(ctx context.Context, rec *Record) (bool, error)
+		Find(ctx context.Context, scope, key string) (*Record, error)
+		Complete(ctx context.Context, rec *Record) error
+		Release(ctx context.Context, scope, key string) error
+		DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	}
+
+	IdempotencyService struct {
+		idempotencyRepo Repository
+		config          *config.Config
+	}
+)
+
+func NewIdempotencyService(repo Repository, c *config.Config) *IdempotencyService {
+	return &IdempotencyService{idempotencyRepo: repo, config: c}
+}
+
+// Begin reserves the key for a new request and returns nil, or returns the record of the same
+// request seen before, whose response must be replayed. It fails with ErrKeyReused when the key came
+// with another body, and with ErrInProgress while the first request has not finished.
+func (s *IdempotencyService) Begin(ctx context.Context, scope, key, fingerprint string) (*Record, error) {
+	rec := &Record{
+		Scope:       scope,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(time.Duration(s.config.IdempotencyTTL) * time.Second),
+	}
+
+	// The key can be released by a failed first request between Reserve and Find, then it is free again
+	for range maxReserveAttempts {
+		reserved, err := s.idempotencyRepo.Reserve(ctx, rec)
+		if err != nil || reserved {
+			return nil, err
+		}
+
+		seen, err := s.idempotencyRepo.Find(ctx, scope, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen.Fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		if seen.StatusCode == nil {
+			return nil, ErrInProgress
+		}
+		return seen, nil
+	}
+	return nil, ErrInProgress
+}
+
+// Complete stores the response of the request, to be replayed until the key expires.
+func (s *IdempotencyService) Complete(ctx context.Context, rec *Record) error {
+	return s.idempotencyRepo.Complete(ctx, rec)
+}
+
+// Release frees the key of a request that failed, so that the client can retry it.
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) error {
+	return s.idempotencyRepo.Release(ctx, scope, key)
+}
+
+// RunCleanup removes the expired keys every interval until ctx is done.
+func (s *IdempotencyService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.idempotencyRepo.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			log.Printf("error removing expired idempotency keys: %v\n", err)
+		} else if deleted > 0 {
+			log.Printf("removed %d expired idempotency keys\n", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
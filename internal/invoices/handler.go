@@ -0,0 +1,205 @@
+package invoices
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/orders"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		FindOrder(ctx context.Context, orderID int64) (*orders.Order, error)
+		FindByID(ctx context.Context, id int64) (*Invoice, error)
+		InvoiceForOrder(ctx context.Context, orderID int64) (*Invoice, error)
+		FindCreditNotes(ctx context.Context, orderID int64) ([]Invoice, error)
+		CreateCreditNote(ctx context.Context, orderID int64, req *CreateCreditNoteRequest) (*Invoice, error)
+	}
+
+	InvoiceHandler struct {
+		invoiceService Service
+		validate       *validator.Validate
+	}
+)
+
+func NewInvoiceHandler(invoiceService Service, validate *validator.Validate) *InvoiceHandler {
+	return &InvoiceHandler{invoiceService: invoiceService, validate: validate}
+}
+
+// FindByOrderID returns the invoice of one of the authenticated user's orders as JSON,
+// or as PDF with ?format=pdf or an Accept: application/pdf header.
+func (h *InvoiceHandler) FindByOrderID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderID, ok := h.authorizeOrder(w, r)
+	if !ok {
+		return
+	}
+
+	inv, err := h.invoiceService.InvoiceForOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, ErrOrderNotInvoiceable) {
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	writeInvoice(w, r, inv)
+}
+
+// FindByID returns an invoice or credit note of the authenticated user as JSON or PDF.
+func (h *InvoiceHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	inv, err := h.invoiceService.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	order, err := h.invoiceService.FindOrder(ctx, inv.OrderID)
+	if err != nil || order.UserID != userID {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return
+	}
+
+	writeInvoice(w, r, inv)
+}
+
+func (h *InvoiceHandler) FindCreditNotes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderID, ok := h.authorizeOrder(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := h.invoiceService.FindCreditNotes(ctx, orderID)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if notes == nil {
+		notes = []Invoice{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, notes)
+}
+
+func (h *InvoiceHandler) CreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := CreateCreditNoteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	note, err := h.invoiceService.CreateCreditNote(ctx, orderID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrUnknownInvoiceLine), errors.Is(err, ErrQuantityExceedsInvoiced):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrNothingToCredit):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, note)
+}
+
+// authorizeOrder parses the order ID and checks that the order belongs to the authenticated user.
+// It writes the error response and returns false otherwise.
+func (h *InvoiceHandler) authorizeOrder(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return 0, false
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return 0, false
+	}
+
+	order, err := h.invoiceService.FindOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return 0, false
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return 0, false
+	}
+
+	// Do not reveal whether orders of other users exist
+	if order.UserID != userID {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return 0, false
+	}
+
+	return orderID, true
+}
+
+func writeInvoice(w http.ResponseWriter, r *http.Request, inv *Invoice) {
+	if r.URL.Query().Get("format") != "pdf" && !strings.Contains(r.Header.Get("Accept"), "application/pdf") {
+		httpx.HTTPResponse(w, http.StatusOK, inv)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, inv); err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", inv.Number+".pdf"))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,104 @@
+// Package invoices issues the invoices and credit notes of orders.
+package invoices
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"ecommerce-service/internal/addresses"
+)
+
+const (
+	TypeInvoice    = "invoice"
+	TypeCreditNote = "credit_note"
+)
+
+// Series numbers invoices and credit notes independently, restarting every year.
+const (
+	SeriesInvoice    = "INV"
+	SeriesCreditNote = "CN"
+)
+
+// Party is the seller or the buyer as they were when the invoice was issued.
+type Party struct {
+	Name    string                  `json:"name"`
+	TaxID   string                  `json:"tax_id,omitempty"`
+	Email   string                  `json:"email,omitempty"`
+	Address addresses.PostalAddress `json:"address"`
+}
+
+// Value stores the party as JSONB.
+func (p Party) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan reads the party from a JSONB column.
+func (p *Party) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return errors.New("unsupported type for Party")
+}
+
+// Invoice is an issued invoice or credit note. It is never modified after being issued:
+// refunds are recorded as credit notes that correct the original invoice with negative amounts.
+type Invoice struct {
+	ID                int64     `json:"id"`
+	Number            string    `json:"number"`
+	Type              string    `json:"type"`
+	Series            string    `json:"series"`
+	Year              int       `json:"year"`
+	Sequence          int       `json:"sequence"`
+	OrderID           int64     `json:"order_id"`
+	CorrectsInvoiceID *int64    `json:"corrects_invoice_id,omitempty"`
+	CorrectsNumber    string    `json:"corrects_number,omitempty"`
+	Seller            Party     `json:"seller"`
+	Buyer             Party     `json:"buyer"`
+	Lines             []Line    `json:"lines"`
+	Currency          string    `json:"currency"`
+	Subtotal          float64   `json:"subtotal"`
+	Tax               float64   `json:"tax"`
+	Total             float64   `json:"total"`
+	Reason            string    `json:"reason,omitempty"`
+	IssuedAt          time.Time `json:"issued_at"`
+}
+
+type Line struct {
+	ID            int64   `json:"id"`
+	InvoiceID     int64   `json:"invoice_id"`
+	ProductID     *int64  `json:"product_id,omitempty"` // Nil for the shipping line
+	CreditsLineID *int64  `json:"credits_line_id,omitempty"`
+	Description   string  `json:"description"`
+	Quantity      int     `json:"quantity"`
+	UnitPrice     float64 `json:"unit_price"` // Net of taxes
+	NetAmount     float64 `json:"net_amount"`
+	TaxName       string  `json:"tax_name,omitempty"`
+	TaxRate       float64 `json:"tax_rate"`
+	TaxAmount     float64 `json:"tax_amount"`
+	Total         float64 `json:"total"`
+}
+
+// CreateCreditNoteRequest refunds the given invoice lines, or everything not yet refunded when Lines is empty.
+type CreateCreditNoteRequest struct {
+	Reason string       `json:"reason" validate:"required,max=500"`
+	Lines  []CreditLine `json:"lines" validate:"dive"`
+}
+
+type CreditLine struct {
+	InvoiceLineID int64 `json:"invoice_line_id" validate:"required"`
+	Quantity      int   `json:"quantity" validate:"required,gt=0"`
+}
+
+// OrderLine is an order item with the product name to print on the invoice.
+type OrderLine struct {
+	ProductID int64
+	VariantID *int64
+	Name      string
+	Quantity  int
+	Price     float64
+}
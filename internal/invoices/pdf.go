@@ -0,0 +1,150 @@
+package invoices
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"ecommerce-service/pkg/pdfx"
+)
+
+const (
+	marginLeft   = 50.0
+	marginRight  = pdfx.PageWidth - 50
+	marginBottom = pdfx.PageHeight - 70
+	rowHeight    = 16.0
+
+	descriptionWidth = 230.0
+)
+
+// Right edges of the numeric columns of the lines table.
+const (
+	colQuantity  = 320.0
+	colUnitPrice = 390.0
+	colTaxRate   = 435.0
+	colTax       = 485.0
+	colTotal     = marginRight
+)
+
+// RenderPDF writes the invoice as a PDF document.
+func RenderPDF(w io.Writer, inv *Invoice) error {
+	doc := pdfx.New()
+	page := doc.AddPage()
+
+	title := "INVOICE"
+	if inv.Type == TypeCreditNote {
+		title = "CREDIT NOTE"
+	}
+	page.Text(marginLeft, 70, pdfx.HelveticaBold, 20, title)
+
+	y := 60.0
+	details := []string{
+		"Number: " + inv.Number,
+		"Date: " + inv.IssuedAt.Format("2006-01-02"),
+		fmt.Sprintf("Order: #%d", inv.OrderID),
+	}
+	if inv.CorrectsNumber != "" {
+		details = append(details, "Corrects invoice: "+inv.CorrectsNumber)
+	}
+	for _, d := range details {
+		page.TextRight(marginRight, y, pdfx.Helvetica, 10, d)
+		y += 14
+	}
+
+	partyY := max(y, 100) + 20
+	sellerEnd := drawParty(page, marginLeft, partyY, "From", inv.Seller)
+	buyerEnd := drawParty(page, 320, partyY, "Bill to", inv.Buyer)
+
+	y = max(sellerEnd, buyerEnd) + 30
+	y = drawTableHeader(page, y)
+
+	for _, l := range inv.Lines {
+		if y > marginBottom {
+			page = doc.AddPage()
+			y = drawTableHeader(page, 60)
+		}
+
+		page.Text(marginLeft, y, pdfx.Helvetica, 9, truncate(l.Description, pdfx.Helvetica, 9, descriptionWidth))
+		page.TextRight(colQuantity, y, pdfx.Helvetica, 9, fmt.Sprintf("%d", l.Quantity))
+		page.TextRight(colUnitPrice, y, pdfx.Helvetica, 9, fmt.Sprintf("%.2f", l.UnitPrice))
+		page.TextRight(colTaxRate, y, pdfx.Helvetica, 9, fmt.Sprintf("%.2f%%", l.TaxRate*100))
+		page.TextRight(colTax, y, pdfx.Helvetica, 9, fmt.Sprintf("%.2f", l.TaxAmount))
+		page.TextRight(colTotal, y, pdfx.Helvetica, 9, fmt.Sprintf("%.2f", l.Total))
+		y += rowHeight
+	}
+
+	if y > marginBottom-60 {
+		page = doc.AddPage()
+		y = 60
+	}
+
+	page.Line(colUnitPrice-60, y, marginRight, y, 0.5)
+	y += rowHeight
+	totals := []struct {
+		label  string
+		amount float64
+		font   pdfx.Font
+	}{
+		{"Subtotal", inv.Subtotal, pdfx.Helvetica},
+		{"Tax", inv.Tax, pdfx.Helvetica},
+		{"Total", inv.Total, pdfx.HelveticaBold},
+	}
+	for _, t := range totals {
+		page.Text(colUnitPrice-60, y, t.font, 10, t.label)
+		page.TextRight(marginRight, y, t.font, 10, fmt.Sprintf("%.2f %s", t.amount, inv.Currency))
+		y += rowHeight
+	}
+
+	if inv.Reason != "" {
+		y += rowHeight
+		page.Text(marginLeft, y, pdfx.Helvetica, 9, truncate("Reason: "+inv.Reason, pdfx.Helvetica, 9, marginRight-marginLeft))
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// drawParty prints the name, tax ID and address of a party and returns where the block ends.
+func drawParty(page *pdfx.Page, x, y float64, label string, p Party) float64 {
+	page.Text(x, y, pdfx.HelveticaBold, 10, label)
+	y += 14
+
+	a := p.Address
+	lines := []string{p.Name}
+	if p.TaxID != "" {
+		lines = append(lines, "Tax ID: "+p.TaxID)
+	}
+	lines = append(lines, a.Line1, a.Line2, strings.TrimSpace(a.PostalCode+" "+a.City), strings.TrimSpace(a.Region+" "+a.Country), p.Email)
+
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+		page.Text(x, y, pdfx.Helvetica, 9, truncate(l, pdfx.Helvetica, 9, 220))
+		y += 12
+	}
+	return y
+}
+
+func drawTableHeader(page *pdfx.Page, y float64) float64 {
+	page.Text(marginLeft, y, pdfx.HelveticaBold, 9, "Description")
+	page.TextRight(colQuantity, y, pdfx.HelveticaBold, 9, "Qty")
+	page.TextRight(colUnitPrice, y, pdfx.HelveticaBold, 9, "Unit price")
+	page.TextRight(colTaxRate, y, pdfx.HelveticaBold, 9, "Tax %")
+	page.TextRight(colTax, y, pdfx.HelveticaBold, 9, "Tax")
+	page.TextRight(colTotal, y, pdfx.HelveticaBold, 9, "Total")
+	page.Line(marginLeft, y+5, marginRight, y+5, 0.5)
+	return y + rowHeight + 4
+}
+
+// truncate shortens s with an ellipsis so it fits in width.
+func truncate(s string, font pdfx.Font, size, width float64) string {
+	if pdfx.TextWidth(font, size, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfx.TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
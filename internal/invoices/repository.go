@@ -0,0 +1,286 @@
+package invoices
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+const invoiceColumns = "i.id, i.number, i.type, i.series, i.year, i.sequence, i.order_id, i.corrects_invoice_id, COALESCE(c.number, ''), i.seller, i.buyer, i.currency, i.subtotal, i.tax, i.total, i.reason, i.issued_at"
+
+const invoiceFrom = " FROM invoices i LEFT JOIN invoices c ON c.id = i.corrects_invoice_id"
+
+type InvoiceRepository struct {
+	db *sql.DB
+}
+
+func NewInvoiceRepository(db *sql.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvoice(s scanner) (*Invoice, error) {
+	var inv Invoice
+	err := s.Scan(&inv.ID, &inv.Number, &inv.Type, &inv.Series, &inv.Year, &inv.Sequence, &inv.OrderID, &inv.CorrectsInvoiceID, &inv.CorrectsNumber, &inv.Seller, &inv.Buyer, &inv.Currency, &inv.Subtotal, &inv.Tax, &inv.Total, &inv.Reason, &inv.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// Issue numbers the invoice with the next number of its series and year and stores it with its lines.
+// The sequence row stays locked until the transaction ends, so numbers are gap-free.
+func (r *InvoiceRepository) Issue(ctx context.Context, inv *Invoice) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if inv.CorrectsInvoiceID != nil {
+		if err = checkCredited(ctx, tx, inv); err != nil {
+			return err
+		}
+	}
+
+	inv.Year = inv.IssuedAt.Year()
+	sequenceQuery := `INSERT INTO invoice_sequences (series, year, last_number) VALUES ($1, $2, 1)
+		ON CONFLICT (series, year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`
+	if err = tx.QueryRowContext(ctx, sequenceQuery, inv.Series, inv.Year).Scan(&inv.Sequence); err != nil {
+		return fmt.Errorf("error assigning invoice number: %w", err)
+	}
+	inv.Number = fmt.Sprintf("%s-%d-%06d", inv.Series, inv.Year, inv.Sequence)
+
+	invoiceQuery := `INSERT INTO invoices (number, type, series, year, sequence, order_id, corrects_invoice_id, seller, buyer, currency, subtotal, tax, total, reason, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`
+	err = tx.QueryRowContext(ctx, invoiceQuery, inv.Number, inv.Type, inv.Series, inv.Year, inv.Sequence, inv.OrderID, inv.CorrectsInvoiceID, inv.Seller, inv.Buyer, inv.Currency, inv.Subtotal, inv.Tax, inv.Total, inv.Reason, inv.IssuedAt).Scan(&inv.ID)
+	if err != nil {
+		return fmt.Errorf("error inserting invoice: %w", err)
+	}
+
+	lineStmt, err := tx.PrepareContext(ctx, `INSERT INTO invoice_lines (invoice_id, product_id, credits_line_id, description, quantity, unit_price, net_amount, tax_name, tax_rate, tax_amount, total)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`)
+	if err != nil {
+		return fmt.Errorf("error preparing invoice line statement: %w", err)
+	}
+	defer lineStmt.Close()
+
+	for i := range inv.Lines {
+		l := &inv.Lines[i]
+		l.InvoiceID = inv.ID
+		err = lineStmt.QueryRowContext(ctx, inv.ID, l.ProductID, l.CreditsLineID, l.Description, l.Quantity, l.UnitPrice, l.NetAmount, l.TaxName, l.TaxRate, l.TaxAmount, l.Total).Scan(&l.ID)
+		if err != nil {
+			return fmt.Errorf("error inserting invoice line #%d: %w", i+1, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkCredited locks the invoice corrected by the credit note and checks that, with the note, no
+// line is refunded more than it was invoiced, so that concurrent credit notes cannot over-refund.
+func checkCredited(ctx context.Context, tx *sql.Tx, note *Invoice) error {
+	rows, err := tx.QueryContext(ctx, "SELECT l.id, l.quantity FROM invoices i JOIN invoice_lines l ON l.invoice_id = i.id WHERE i.id = $1 FOR UPDATE OF i", *note.CorrectsInvoiceID)
+	if err != nil {
+		return fmt.Errorf("error locking invoice: %w", err)
+	}
+	invoiced := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var quantity int
+		if err := rows.Scan(&id, &quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		invoiced[id] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	credited, err := findCreditedQuantities(ctx, tx, *note.CorrectsInvoiceID)
+	if err != nil {
+		return err
+	}
+	for _, l := range note.Lines {
+		if l.CreditsLineID == nil {
+			continue
+		}
+		credited[*l.CreditsLineID] -= l.Quantity
+		if credited[*l.CreditsLineID] > invoiced[*l.CreditsLineID] {
+			return ErrQuantityExceedsInvoiced
+		}
+	}
+	return nil
+}
+
+func (r *InvoiceRepository) FindByID(ctx context.Context, id int64) (*Invoice, error) {
+	query := "SELECT " + invoiceColumns + invoiceFrom + " WHERE i.id = $1"
+	return r.findOne(ctx, query, id)
+}
+
+// FindByOrderID returns the invoice of the order, credit notes excluded.
+func (r *InvoiceRepository) FindByOrderID(ctx context.Context, orderID int64) (*Invoice, error) {
+	query := "SELECT " + invoiceColumns + invoiceFrom + " WHERE i.order_id = $1 AND i.type = 'invoice'"
+	return r.findOne(ctx, query, orderID)
+}
+
+func (r *InvoiceRepository) findOne(ctx context.Context, query string, arg any) (*Invoice, error) {
+	inv, err := scanInvoice(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.Lines, err = r.findLines(ctx, inv.ID); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// FindCreditNotes returns the credit notes that correct the invoice, oldest first.
+func (r *InvoiceRepository) FindCreditNotes(ctx context.Context, invoiceID int64) ([]Invoice, error) {
+	query := "SELECT " + invoiceColumns + invoiceFrom + " WHERE i.corrects_invoice_id = $1 ORDER BY i.id"
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var notes []Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range notes {
+		if notes[i].Lines, err = r.findLines(ctx, notes[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return notes, nil
+}
+
+func (r *InvoiceRepository) findLines(ctx context.Context, invoiceID int64) ([]Line, error) {
+	query := "SELECT id, invoice_id, product_id, credits_line_id, description, quantity, unit_price, net_amount, tax_name, tax_rate, tax_amount, total FROM invoice_lines WHERE invoice_id = $1 ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.ID, &l.InvoiceID, &l.ProductID, &l.CreditsLineID, &l.Description, &l.Quantity, &l.UnitPrice, &l.NetAmount, &l.TaxName, &l.TaxRate, &l.TaxAmount, &l.Total); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+// FindCreditedQuantities returns, per line of the invoice, the quantity already refunded by credit notes.
+func (r *InvoiceRepository) FindCreditedQuantities(ctx context.Context, invoiceID int64) (map[int64]int, error) {
+	return findCreditedQuantities(ctx, r.db, invoiceID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func findCreditedQuantities(ctx context.Context, q queryer, invoiceID int64) (map[int64]int, error) {
+	query := `SELECT l.credits_line_id, -SUM(l.quantity)
+		FROM invoice_lines l
+		JOIN invoices i ON i.id = l.invoice_id
+		WHERE i.corrects_invoice_id = $1 AND l.credits_line_id IS NOT NULL
+		GROUP BY l.credits_line_id`
+	rows, err := q.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	credited := make(map[int64]int)
+	for rows.Next() {
+		var lineID int64
+		var quantity int
+		if err := rows.Scan(&lineID, &quantity); err != nil {
+			return nil, err
+		}
+		credited[lineID] = quantity
+	}
+
+	return credited, rows.Err()
+}
+
+// FindOrderLines returns the items of the order with the current name of their products, followed by the variant SKU.
+func (r *InvoiceRepository) FindOrderLines(ctx context.Context, orderID int64) ([]OrderLine, error) {
+	query := `SELECT oi.product_id, oi.variant_id, COALESCE(p.name, '') || CASE WHEN oi.sku <> '' THEN ' (' || oi.sku || ')' ELSE '' END, oi.quantity, oi.price
+		FROM order_items oi
+		LEFT JOIN products p ON p.id = oi.product_id
+		WHERE oi.order_id = $1
+		ORDER BY oi.id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var lines []OrderLine
+	for rows.Next() {
+		var l OrderLine
+		if err := rows.Scan(&l.ProductID, &l.VariantID, &l.Name, &l.Quantity, &l.Price); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+func (r *InvoiceRepository) FindUserEmail(ctx context.Context, userID int64) (string, error) {
+	query := "SELECT email FROM users WHERE id = $1"
+	var email string
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&email); err != nil {
+		return "", err
+	}
+	return email, nil
+}
@@ -0,0 +1,18 @@
+package invoices
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *InvoiceHandler, am *auth.AuthMiddleware) {
+	r.Group(func(r chi.Router) {
+		r.Use(am.VerifyToken)
+
+		r.Get("/orders/{id}/invoice", h.FindByOrderID)
+		r.Get("/orders/{id}/credit-notes", h.FindCreditNotes)
+		r.With(am.RequireAdmin).Post("/orders/{id}/credit-notes", h.CreateCreditNote)
+		r.Get("/invoices/{id}", h.FindByID)
+	})
+}
@@ -0,0 +1,319 @@
+package invoices
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"ecommerce-service/internal/addresses"
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/orders"
+)
+
+var (
+	ErrOrderNotInvoiceable     = errors.New("only shipped or delivered orders can be invoiced")
+	ErrNothingToCredit         = errors.New("every line of the invoice has already been refunded")
+	ErrUnknownInvoiceLine      = errors.New("the line does not belong to the invoice")
+	ErrQuantityExceedsInvoiced = errors.New("the quantity exceeds what is left to refund for the line")
+)
+
+type (
+	Repository interface {
+		Issue(ctx context.Context, inv *Invoice) error
+		FindByID(ctx context.Context, id int64) (*Invoice, error)
+		FindByOrderID(ctx context.Context, orderID int64) (*Invoice, error)
+		FindCreditNotes(ctx context.Context, invoiceID int64) ([]Invoice, error)
+		FindCreditedQuantities(ctx context.Context, invoiceID int64) (map[int64]int, error)
+		FindOrderLines(ctx context.Context, orderID int64) ([]OrderLine, error)
+		FindUserEmail(ctx context.Context, userID int64) (string, error)
+	}
+
+	// OrderFinder gives access to the orders being invoiced.
+	OrderFinder interface {
+		FindByID(ctx context.Context, id int) (*orders.Order, error)
+	}
+
+	InvoiceService struct {
+		invoiceRepo Repository
+		orderFinder OrderFinder
+		config      *config.Config
+	}
+)
+
+func NewInvoiceService(invoiceRepo Repository, orderFinder OrderFinder, c *config.Config) *InvoiceService {
+	return &InvoiceService{invoiceRepo: invoiceRepo, orderFinder: orderFinder, config: c}
+}
+
+// FindOrder returns the order an invoice belongs to.
+func (s *InvoiceService) FindOrder(ctx context.Context, orderID int64) (*orders.Order, error) {
+	return s.orderFinder.FindByID(ctx, int(orderID))
+}
+
+func (s *InvoiceService) FindByID(ctx context.Context, id int64) (*Invoice, error) {
+	return s.invoiceRepo.FindByID(ctx, id)
+}
+
+// InvoiceForOrder returns the invoice of the order, issuing it the first time it is requested.
+func (s *InvoiceService) InvoiceForOrder(ctx context.Context, orderID int64) (*Invoice, error) {
+	inv, err := s.invoiceRepo.FindByOrderID(ctx, orderID)
+	if err == nil {
+		return inv, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	order, err := s.orderFinder.FindByID(ctx, int(orderID))
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != orders.StatusShipped && order.Status != orders.StatusDelivered {
+		return nil, ErrOrderNotInvoiceable
+	}
+
+	inv, err = s.buildInvoice(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.invoiceRepo.Issue(ctx, inv); err != nil {
+		// Another request may have issued the invoice concurrently
+		if existing, findErr := s.invoiceRepo.FindByOrderID(ctx, orderID); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// FindCreditNotes returns the credit notes issued for the order.
+func (s *InvoiceService) FindCreditNotes(ctx context.Context, orderID int64) ([]Invoice, error) {
+	inv, err := s.invoiceRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Invoice{}, nil
+		}
+		return nil, err
+	}
+	return s.invoiceRepo.FindCreditNotes(ctx, inv.ID)
+}
+
+// CreateCreditNote issues a credit note that refunds lines of the order invoice.
+func (s *InvoiceService) CreateCreditNote(ctx context.Context, orderID int64, req *CreateCreditNoteRequest) (*Invoice, error) {
+	inv, err := s.invoiceRepo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	credited, err := s.invoiceRepo.FindCreditedQuantities(ctx, inv.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make(map[int64]int, len(inv.Lines))
+	for _, l := range inv.Lines {
+		remaining[l.ID] = l.Quantity - credited[l.ID]
+	}
+
+	requested := req.Lines
+	if len(requested) == 0 {
+		for _, l := range inv.Lines {
+			if remaining[l.ID] > 0 {
+				requested = append(requested, CreditLine{InvoiceLineID: l.ID, Quantity: remaining[l.ID]})
+			}
+		}
+		if len(requested) == 0 {
+			return nil, ErrNothingToCredit
+		}
+	}
+
+	lines := make(map[int64]Line, len(inv.Lines))
+	for _, l := range inv.Lines {
+		lines[l.ID] = l
+	}
+
+	note := &Invoice{
+		Type:              TypeCreditNote,
+		Series:            SeriesCreditNote,
+		OrderID:           inv.OrderID,
+		CorrectsInvoiceID: &inv.ID,
+		CorrectsNumber:    inv.Number,
+		Seller:            s.seller(),
+		Buyer:             inv.Buyer,
+		Currency:          inv.Currency,
+		Reason:            req.Reason,
+		IssuedAt:          time.Now().UTC(),
+	}
+
+	for _, c := range requested {
+		original, ok := lines[c.InvoiceLineID]
+		if !ok {
+			return nil, ErrUnknownInvoiceLine
+		}
+		if c.Quantity > remaining[c.InvoiceLineID] {
+			return nil, ErrQuantityExceedsInvoiced
+		}
+		remaining[c.InvoiceLineID] -= c.Quantity
+
+		ratio := float64(c.Quantity) / float64(original.Quantity)
+		net := -round(original.NetAmount * ratio)
+		tax := -round(original.TaxAmount * ratio)
+		note.Lines = append(note.Lines, Line{
+			ProductID:     original.ProductID,
+			CreditsLineID: &original.ID,
+			Description:   original.Description,
+			Quantity:      -c.Quantity,
+			UnitPrice:     original.UnitPrice,
+			NetAmount:     net,
+			TaxName:       original.TaxName,
+			TaxRate:       original.TaxRate,
+			TaxAmount:     tax,
+			Total:         round(net + tax),
+		})
+	}
+	note.sum()
+
+	if err := s.invoiceRepo.Issue(ctx, note); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// buildInvoice snapshots the seller, the buyer and the lines of the order.
+func (s *InvoiceService) buildInvoice(ctx context.Context, order *orders.Order) (*Invoice, error) {
+	orderLines, err := s.invoiceRepo.FindOrderLines(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Guest orders carry the email of the guest
+	email := order.Email
+	if email == "" {
+		email, err = s.invoiceRepo.FindUserEmail(ctx, order.UserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	// An order holds an item per product variant, so tax lines are matched on both
+	taxLines := make(map[itemKey]orders.TaxLine, len(order.TaxLines))
+	for _, t := range order.TaxLines {
+		taxLines[keyOf(t.ProductID, t.VariantID)] = t
+	}
+
+	inv := &Invoice{
+		Type:     TypeInvoice,
+		Series:   SeriesInvoice,
+		OrderID:  order.ID,
+		Seller:   s.seller(),
+		Buyer:    Party{Name: order.BillingAddress.Name, Email: email, Address: order.BillingAddress},
+		Currency: s.config.InvoiceCurrency,
+		IssuedAt: time.Now().UTC(),
+	}
+
+	// Taxed lines are already net of their share of the order discount, the rest of it gets its own line
+	discount := order.Discount
+	for _, ol := range orderLines {
+		productID := ol.ProductID
+		line := Line{ProductID: &productID, Description: ol.Name, Quantity: ol.Quantity}
+		if line.Description == "" {
+			line.Description = fmt.Sprintf("Product #%d", ol.ProductID)
+		}
+
+		if t, ok := taxLines[keyOf(ol.ProductID, ol.VariantID)]; ok {
+			line.TaxName = t.Name
+			line.TaxRate = t.Rate
+			line.TaxAmount = t.Amount
+			discount -= ol.Price*float64(ol.Quantity) - t.TaxableAmount
+			if t.Inclusive {
+				line.Total = t.TaxableAmount
+				line.NetAmount = round(t.TaxableAmount - t.Amount)
+			} else {
+				line.NetAmount = t.TaxableAmount
+				line.Total = round(t.TaxableAmount + t.Amount)
+			}
+		} else {
+			line.NetAmount = round(ol.Price * float64(ol.Quantity))
+			line.Total = line.NetAmount
+		}
+
+		if ol.Quantity > 0 {
+			line.UnitPrice = math.Round(line.NetAmount/float64(ol.Quantity)*10000) / 10000
+		}
+		inv.Lines = append(inv.Lines, line)
+	}
+
+	if discount = round(discount); discount > 0 {
+		inv.Lines = append(inv.Lines, Line{
+			Description: "Discount",
+			Quantity:    1,
+			UnitPrice:   -discount,
+			NetAmount:   -discount,
+			Total:       -discount,
+		})
+	}
+
+	if order.ShippingCost > 0 {
+		inv.Lines = append(inv.Lines, Line{
+			Description: fmt.Sprintf("Shipping (%s)", order.ShippingMethod),
+			Quantity:    1,
+			UnitPrice:   order.ShippingCost,
+			NetAmount:   order.ShippingCost,
+			Total:       order.ShippingCost,
+		})
+	}
+
+	inv.sum()
+	return inv, nil
+}
+
+// itemKey identifies an order item by its product and variant, zero when it has none.
+type itemKey struct {
+	productID int64
+	variantID int64
+}
+
+func keyOf(productID int64, variantID *int64) itemKey {
+	key := itemKey{productID: productID}
+	if variantID != nil {
+		key.variantID = *variantID
+	}
+	return key
+}
+
+func (s *InvoiceService) seller() Party {
+	return Party{
+		Name:  s.config.InvoiceSellerName,
+		TaxID: s.config.InvoiceSellerTaxID,
+		Address: addresses.PostalAddress{
+			Name:       s.config.InvoiceSellerName,
+			Line1:      s.config.InvoiceSellerLine1,
+			City:       s.config.InvoiceSellerCity,
+			PostalCode: s.config.InvoiceSellerPostalCode,
+			Country:    s.config.InvoiceSellerCountry,
+		},
+	}
+}
+
+// sum recalculates the invoice totals from its lines.
+func (inv *Invoice) sum() {
+	inv.Subtotal, inv.Tax, inv.Total = 0, 0, 0
+	for _, l := range inv.Lines {
+		inv.Subtotal += l.NetAmount
+		inv.Tax += l.TaxAmount
+		inv.Total += l.Total
+	}
+	inv.Subtotal = round(inv.Subtotal)
+	inv.Tax = round(inv.Tax)
+	inv.Total = round(inv.Total)
+}
+
+// round rounds an amount to cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
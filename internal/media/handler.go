@@ -0,0 +1,181 @@
+package media
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// multipartOverhead leaves room for the headers and fields of the form around the file.
+const multipartOverhead = 1 << 20
+
+type (
+	Service interface {
+		Upload(ctx context.Context, productID int, file io.Reader, altText string) (*Image, error)
+		FindByProductID(ctx context.Context, productID int) ([]Image, error)
+		Delete(ctx context.Context, productID int, id int64) error
+		Reorder(ctx context.Context, productID int, ids []int64) ([]Image, error)
+	}
+
+	MediaHandler struct {
+		mediaService Service
+		validate     *validator.Validate
+		config       *config.Config
+	}
+)
+
+func NewMediaHandler(mediaService Service, validate *validator.Validate, config *config.Config) *MediaHandler {
+	return &MediaHandler{mediaService: mediaService, validate: validate, config: config}
+}
+
+// Upload handles a multipart form with the file in the "image" field and an optional "alt_text".
+func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.config.MediaMaxUploadBytes+multipartOverhead)
+	if err := r.ParseMultipartForm(h.config.MediaMaxUploadBytes); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			httpx.HTTPError(w, http.StatusRequestEntityTooLarge, ErrFileTooLarge.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+	defer func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			log.Printf("error removing multipart files: %v\n", err)
+		}
+	}()
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"image": "the image field is required"})
+		return
+	}
+	defer file.Close()
+
+	altText := r.FormValue("alt_text")
+	if err := h.validate.Var(altText, "max=255"); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"alt_text": "the alt_text field must be at most 255 characters long"})
+		return
+	}
+
+	img, err := h.mediaService.Upload(ctx, productID, file, altText)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrFileTooLarge), errors.Is(err, ErrImageTooLarge):
+			httpx.HTTPError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, ErrUnsupportedType):
+			httpx.HTTPError(w, http.StatusUnsupportedMediaType, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, img)
+}
+
+func (h *MediaHandler) FindByProductID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	images, err := h.mediaService.FindByProductID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if images == nil {
+		images = []Image{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, images)
+}
+
+func (h *MediaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+	imageID, err := strconv.ParseInt(chi.URLParam(r, "imageID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.mediaService.Delete(ctx, productID, imageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+func (h *MediaHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := ReorderImagesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	images, err := h.mediaService.Reorder(ctx, productID, req.ImageIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrImageReorderMismatch):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, images)
+}
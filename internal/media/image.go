@@ -0,0 +1,128 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	_ "image/gif" // Registers the GIF decoder
+)
+
+var (
+	ErrUnsupportedType = errors.New("the file must be a JPEG, PNG or GIF image")
+	ErrImageTooLarge   = errors.New("the image dimensions are too large")
+)
+
+// maxPixels caps the decoded size of an image, so a small file cannot expand into a huge bitmap.
+const maxPixels = 40_000_000
+
+// Longest side, in pixels, of the resized versions of an image.
+const (
+	mediumSize    = 800
+	thumbnailSize = 200
+)
+
+// extensions are the accepted content types, sniffed from the file contents, and their extension.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// decoded is an uploaded image with its sniffed content type.
+type decoded struct {
+	image       image.Image
+	contentType string
+}
+
+// decodeImage checks the real type and the dimensions of the file before decoding it.
+func decodeImage(data []byte) (*decoded, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	return &decoded{image: img, contentType: contentType}, nil
+}
+
+// encodeResized resizes the image to fit in a size x size box and encodes it. JPEG stays JPEG;
+// PNG and GIF become PNG so transparency is kept. It returns the content type of the result.
+func encodeResized(d *decoded, size int) ([]byte, string, error) {
+	img := resize(d.image, size)
+
+	var buf bytes.Buffer
+	if d.contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// resize scales the image down to fit in a size x size box, averaging the source pixels covered
+// by each destination pixel. Images that already fit are returned unchanged.
+func resize(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, h*size/w
+	if h > w {
+		dw, dh = w*size/h, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					bl += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
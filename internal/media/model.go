@@ -0,0 +1,29 @@
+// Package media manages the images of products and the storage backends that hold them.
+package media
+
+import "time"
+
+// Image is an uploaded product image with the URLs of its resized versions.
+type Image struct {
+	ID           int64      `json:"id"`
+	ProductID    int        `json:"product_id"`
+	Position     int        `json:"position"`
+	URL          string     `json:"url"`
+	MediumURL    string     `json:"medium_url"`
+	ThumbnailURL string     `json:"thumbnail_url"`
+	ContentType  string     `json:"content_type"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	SizeBytes    int64      `json:"size_bytes"`
+	AltText      string     `json:"alt_text"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+
+	// Storage keys of the original and the resized versions
+	OriginalKey  string `json:"-"`
+	MediumKey    string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []int64 `json:"image_ids" validate:"required,min=1,unique,dive,gt=0"`
+}
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+const imageColumns = "id, product_id, position, original_key, medium_key, thumbnail_key, content_type, width, height, size_bytes, alt_text, created_at"
+
+type ImageRepository struct {
+	db *sql.DB
+}
+
+func NewImageRepository(db *sql.DB) *ImageRepository {
+	return &ImageRepository{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImage(s scanner) (*Image, error) {
+	var img Image
+	err := s.Scan(&img.ID, &img.ProductID, &img.Position, &img.OriginalKey, &img.MediumKey, &img.ThumbnailKey, &img.ContentType, &img.Width, &img.Height, &img.SizeBytes, &img.AltText, &img.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// Create inserts the image after the other images of the product.
+func (r *ImageRepository) Create(ctx context.Context, img *Image) error {
+	query := `INSERT INTO product_images (product_id, position, original_key, medium_key, thumbnail_key, content_type, width, height, size_bytes, alt_text)
+		VALUES ($1, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1), $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, position, created_at`
+	return r.db.QueryRowContext(ctx, query, img.ProductID, img.OriginalKey, img.MediumKey, img.ThumbnailKey, img.ContentType, img.Width, img.Height, img.SizeBytes, img.AltText).
+		Scan(&img.ID, &img.Position, &img.CreatedAt)
+}
+
+func (r *ImageRepository) FindByID(ctx context.Context, productID int, id int64) (*Image, error) {
+	query := "SELECT " + imageColumns + " FROM product_images WHERE product_id = $1 AND id = $2"
+	return scanImage(r.db.QueryRowContext(ctx, query, productID, id))
+}
+
+// FindByProductID returns the images of the product in display order.
+func (r *ImageRepository) FindByProductID(ctx context.Context, productID int) ([]Image, error) {
+	query := "SELECT " + imageColumns + " FROM product_images WHERE product_id = $1 ORDER BY position, id"
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var images []Image
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+
+	return images, rows.Err()
+}
+
+func (r *ImageRepository) Delete(ctx context.Context, productID int, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM product_images WHERE product_id = $1 AND id = $2", productID, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Reorder sets the position of each image to its index in ids in a single statement.
+func (r *ImageRepository) Reorder(ctx context.Context, productID int, ids []int64) error {
+	query := `UPDATE product_images i SET position = o.position - 1
+		FROM UNNEST($2::BIGINT[]) WITH ORDINALITY AS o (id, position)
+		WHERE i.id = o.id AND i.product_id = $1`
+	if _, err := r.db.ExecContext(ctx, query, productID, pq.Array(ids)); err != nil {
+		return fmt.Errorf("error updating image positions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package media
+
+import (
+	"net/http"
+
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FilesPath is where the local storage backend serves the uploaded files.
+const FilesPath = "/media"
+
+// RegisterRoutes registers the image endpoints and, when given, the handler serving the stored files.
+func RegisterRoutes(r chi.Router, h *MediaHandler, files http.Handler, am *auth.AuthMiddleware) {
+	r.Get("/products/{id}/images", h.FindByProductID)
+
+	r.Group(func(r chi.Router) {
+		r.Use(am.VerifyToken, am.RequireAdmin)
+
+		r.Post("/products/{id}/images", h.Upload)
+		r.Put("/products/{id}/images/order", h.Reorder)
+		r.Delete("/products/{id}/images/{imageID}", h.Delete)
+	})
+
+	if files != nil {
+		r.Handle(FilesPath+"/*", http.StripPrefix(FilesPath, files))
+	}
+}
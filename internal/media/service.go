@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/products"
+)
+
+var (
+	ErrFileTooLarge         = errors.New("the file exceeds the maximum upload size")
+	ErrImageReorderMismatch = errors.New("image_ids must list every image of the product exactly once")
+)
+
+type (
+	Repository interface {
+		Create(ctx context.Context, img *Image) error
+		FindByID(ctx context.Context, productID int, id int64) (*Image, error)
+		FindByProductID(ctx context.Context, productID int) ([]Image, error)
+		Delete(ctx context.Context, productID int, id int64) error
+		Reorder(ctx context.Context, productID int, ids []int64) error
+	}
+
+	// ProductFinder checks that the product of the images exists.
+	ProductFinder interface {
+		FindByID(ctx context.Context, id int) (*products.Product, error)
+	}
+
+	MediaService struct {
+		imageRepo     Repository
+		productFinder ProductFinder
+		storage       Storage
+		config        *config.Config
+	}
+)
+
+func NewMediaService(imageRepo Repository, productFinder ProductFinder, storage Storage, c *config.Config) *MediaService {
+	return &MediaService{imageRepo: imageRepo, productFinder: productFinder, storage: storage, config: c}
+}
+
+// Upload validates the image, stores it with its medium and thumbnail versions and appends it
+// to the images of the product.
+func (s *MediaService) Upload(ctx context.Context, productID int, file io.Reader, altText string) (*Image, error) {
+	if _, err := s.productFinder.FindByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, s.config.MediaMaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.config.MediaMaxUploadBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	d, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	medium, mediumType, err := encodeResized(d, mediumSize)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, thumbnailType, err := encodeResized(d, thumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("products/%d/%s", productID, name)
+
+	bounds := d.image.Bounds()
+	img := &Image{
+		ProductID:    productID,
+		OriginalKey:  prefix + extensions[d.contentType],
+		MediumKey:    prefix + "-medium" + extensions[mediumType],
+		ThumbnailKey: prefix + "-thumbnail" + extensions[thumbnailType],
+		ContentType:  d.contentType,
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+		SizeBytes:    int64(len(data)),
+		AltText:      altText,
+	}
+
+	files := []struct {
+		key         string
+		data        []byte
+		contentType string
+	}{
+		{img.OriginalKey, data, d.contentType},
+		{img.MediumKey, medium, mediumType},
+		{img.ThumbnailKey, thumbnail, thumbnailType},
+	}
+	stored := []string{}
+	for _, f := range files {
+		if err := s.storage.Put(ctx, f.key, bytes.NewReader(f.data), f.contentType); err != nil {
+			s.deleteFiles(ctx, stored...)
+			return nil, fmt.Errorf("error storing image: %w", err)
+		}
+		stored = append(stored, f.key)
+	}
+
+	if err := s.imageRepo.Create(ctx, img); err != nil {
+		s.deleteFiles(ctx, stored...)
+		return nil, err
+	}
+
+	s.setURLs(img)
+	return img, nil
+}
+
+// FindByProductID returns the images of the product in display order.
+func (s *MediaService) FindByProductID(ctx context.Context, productID int) ([]Image, error) {
+	if _, err := s.productFinder.FindByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	images, err := s.imageRepo.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		s.setURLs(&images[i])
+	}
+	return images, nil
+}
+
+// Delete removes the image and its files. Files that cannot be removed are only logged, as the
+// image is already gone from the product.
+func (s *MediaService) Delete(ctx context.Context, productID int, id int64) error {
+	img, err := s.imageRepo.FindByID(ctx, productID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.imageRepo.Delete(ctx, productID, id); err != nil {
+		return err
+	}
+
+	s.deleteFiles(ctx, img.OriginalKey, img.MediumKey, img.ThumbnailKey)
+	return nil
+}
+
+// Reorder sets the display order of the images of the product, which must all be listed.
+func (s *MediaService) Reorder(ctx context.Context, productID int, ids []int64) ([]Image, error) {
+	images, err := s.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make([]int64, 0, len(images))
+	for _, img := range images {
+		current = append(current, img.ID)
+	}
+	requested := slices.Clone(ids)
+	slices.Sort(current)
+	slices.Sort(requested)
+	if !slices.Equal(current, requested) {
+		return nil, ErrImageReorderMismatch
+	}
+
+	if err := s.imageRepo.Reorder(ctx, productID, ids); err != nil {
+		return nil, err
+	}
+
+	return s.FindByProductID(ctx, productID)
+}
+
+func (s *MediaService) setURLs(img *Image) {
+	img.URL = s.storage.URL(img.OriginalKey)
+	img.MediumURL = s.storage.URL(img.MediumKey)
+	img.ThumbnailURL = s.storage.URL(img.ThumbnailKey)
+}
+
+func (s *MediaService) deleteFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("error deleting media file %s: %v", key, err)
+		}
+	}
+}
+
+// randomName returns an unguessable file name, so uploads never overwrite each other.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
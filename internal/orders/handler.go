@@ -2,12 +2,18 @@ package orders
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
 
+	"ecommerce-service/internal/addresses"
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/carts"
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/shipping"
 	"ecommerce-service/internal/utils"
 	"ecommerce-service/pkg/httpx"
 
@@ -19,8 +25,12 @@ type (
 	// Service interface defines the methods we expect from our OrderService.
 	Service interface {
 		CreateOrderFromCart(ctx context.Context, o *CreateOrderRequest) (*Order, error)
+		CreateGuestOrder(ctx context.Context, guestID string, o *GuestOrderRequest) (*Order, error)
+		FindByLookupToken(ctx context.Context, token string) (*Order, error)
+		Claim(ctx context.Context, token string, req *ClaimOrderRequest) (*Order, error)
 		FindByID(ctx context.Context, id int) (*Order, error)
 		ListByUserID(ctx context.Context, userID, page, limit int) ([]*Order, error)
+		ListPageByUserID(ctx context.Context, userID int, c *utils.Cursor, limit int) ([]*Order, *string, *string, error)
 		Update(ctx context.Context, id int, o *UpdateOrderRequest) error
 		Delete(ctx context.Context, id int) error
 		CountByUserID(ctx context.Context, userID int) (int, error)
@@ -29,24 +39,32 @@ type (
 	// OrdersHandler is the HTTP handler for orders.
 	OrdersHandler struct {
 		orderService Service
+		guestTokens  *carts.GuestTokens
 		validate     *validator.Validate
 		config       *config.Config
 	}
 )
 
 // NewOrderHandler creates a new OrdersHandler.
-func NewOrderHandler(orderService Service, validate *validator.Validate, config *config.Config) *OrdersHandler {
-	return &OrdersHandler{orderService: orderService, validate: validate, config: config}
+func NewOrderHandler(orderService Service, guestTokens *carts.GuestTokens, validate *validator.Validate, config *config.Config) *OrdersHandler {
+	return &OrdersHandler{orderService: orderService, guestTokens: guestTokens, validate: validate, config: config}
 }
 
 // Create handles the HTTP request to create a new order from a cart.
 func (h *OrdersHandler) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
 	var req CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
 		return
 	}
+	req.UserID = userID
 
 	if err := h.validate.Struct(req); err != nil {
 		validationErrors := httpx.FormatValidatorErrors(err)
@@ -56,17 +74,107 @@ func (h *OrdersHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	createdOrder, err := h.orderService.CreateOrderFromCart(ctx, &req)
 	if err != nil {
-		if errors.Is(err, ErrEmptyCart) {
-			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		writeOrderError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, createdOrder)
+}
+
+// CreateGuest handles the HTTP request to place an order from the guest cart of the request, without
+// an account.
+func (h *OrdersHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	guestID, err := h.guestTokens.FromRequest(r)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req GuestOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	createdOrder, err := h.orderService.CreateGuestOrder(ctx, guestID, &req)
+	if err != nil {
+		writeOrderError(w, err)
 		return
 	}
 
 	httpx.HTTPResponse(w, http.StatusCreated, createdOrder)
 }
 
+// Lookup handles the HTTP request to see a guest order through the link emailed to the guest.
+func (h *OrdersHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	order, err := h.orderService.FindByLookupToken(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, order)
+}
+
+// Claim handles the HTTP request to create an account for the guest of a lookup link, with the
+// orders of the guest.
+func (h *OrdersHandler) Claim(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req ClaimOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	order, err := h.orderService.Claim(ctx, chi.URLParam(r, "token"), &req)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, order)
+}
+
+// writeOrderError maps the errors of placing and looking up orders to responses.
+func writeOrderError(w http.ResponseWriter, err error) {
+	var addressErr *addresses.ValidationError
+	var changedErr *carts.ChangedError
+	switch {
+	case errors.As(err, &addressErr):
+		httpx.HTTPErrors(w, http.StatusBadRequest, addressErr.Fields)
+	case errors.As(err, &changedErr):
+		httpx.HTTPResponse(w, http.StatusConflict, map[string]any{"error": changedErr.Error(), "changes": changedErr.Changes})
+	case errors.Is(err, ErrEmptyCart), errors.Is(err, ErrAddressRequired),
+		errors.Is(err, ErrNoShippingMethod), errors.Is(err, shipping.ErrMethodUnavailable):
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, promotions.ErrCouponUsageLimitReached):
+		httpx.HTTPError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrCartNotOwned):
+		httpx.HTTPError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrAddressNotFound):
+		httpx.HTTPError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidLookupToken), errors.Is(err, sql.ErrNoRows):
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+	case errors.Is(err, ErrOrderClaimed), errors.Is(err, ErrLookupTokenExpired):
+		httpx.HTTPError(w, http.StatusGone, err.Error())
+	case errors.Is(err, ErrEmailRegistered), errors.Is(err, ErrCartNotActive):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	default:
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
+
 // FindByID handles the HTTP request to find an order by its ID.
 func (h *OrdersHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -87,22 +195,48 @@ func (h *OrdersHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 	httpx.HTTPResponse(w, http.StatusOK, order)
 }
 
-// ListByUserID handles the HTTP request to list orders for a given user.
+// ListByUserID handles the HTTP request to list the orders of the authenticated user, newest first.
+// It paginates with page/limit, or with keyset pagination when ?cursor is present (empty for the first page).
 func (h *OrdersHandler) ListByUserID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	idStr := chi.URLParam(r, "id")
-	limitStr := r.URL.Query().Get("limit")
-	pageStr := r.URL.Query().Get("page")
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id := int(userID)
 
-	page, limit := utils.ParsePaginationParams(pageStr, limitStr, h.config.Limit, h.config.MaxLimit)
+	if r.URL.Query().Has("cursor") {
+		c, limit, err := utils.ParseCursorParams(r.URL.Query().Get("cursor"), r.URL.Query().Get("limit"), "", h.config.Limit, h.config.MaxLimit)
+		if err != nil {
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		orders, next, prev, err := h.orderService.ListPageByUserID(ctx, id, c, limit)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidCursor) {
+				httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+			return
+		}
+
+		if orders == nil {
+			orders = []*Order{}
+		}
+
+		httpx.HTTPCursorResponse(w, http.StatusOK, orders, next, prev)
 		return
 	}
 
+	limitStr := r.URL.Query().Get("limit")
+	pageStr := r.URL.Query().Get("page")
+
+	page, limit := utils.ParsePaginationParams(pageStr, limitStr, h.config.Limit, h.config.MaxLimit)
+
 	total, err := h.orderService.CountByUserID(ctx, id)
 	if err != nil {
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
@@ -114,6 +248,10 @@ func (h *OrdersHandler) ListByUserID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if orders == nil {
+		orders = []*Order{}
+	}
+
 	httpx.HTTPPaginatedResponse(w, http.StatusOK, orders, page, limit, total)
 }
 
@@ -0,0 +1,65 @@
+package orders
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLookupToken = errors.New("the order lookup token is invalid")
+	ErrLookupTokenExpired = errors.New("the order lookup link has expired")
+)
+
+// LookupTokens issues and verifies the tokens of the order lookup links sent to guests: the order
+// ID and the expiry of the link signed with HMAC-SHA256, so that guests cannot reach the orders of
+// others, nor claim them once the link has expired.
+type LookupTokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewLookupTokens(secret string, ttl time.Duration) *LookupTokens {
+	return &LookupTokens{secret: []byte(secret), ttl: ttl}
+}
+
+// New returns the lookup token of the order.
+func (lt *LookupTokens) New(orderID int64) string {
+	payload := strconv.FormatInt(orderID, 10) + "." + strconv.FormatInt(time.Now().Add(lt.ttl).Unix(), 10)
+	return payload + "." + lt.sign(payload)
+}
+
+// Verify returns the order ID of the token.
+func (lt *LookupTokens) Verify(token string) (int64, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(lt.sign(token[:i]))) {
+		return 0, ErrInvalidLookupToken
+	}
+
+	id, exp, ok := strings.Cut(token[:i], ".")
+	if !ok {
+		return 0, ErrInvalidLookupToken
+	}
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLookupToken
+	}
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLookupToken
+	}
+	if time.Now().Unix() > expiresAt {
+		return 0, ErrLookupTokenExpired
+	}
+	return orderID, nil
+}
+
+func (lt *LookupTokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, lt.secret)
+	mac.Write([]byte("order:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
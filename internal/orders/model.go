@@ -1,34 +1,102 @@
 // Package orders defines the data models for the orders module.
 package orders
 
+import (
+	"ecommerce-service/internal/addresses"
+	"ecommerce-service/internal/promotions"
+)
+
 type Order struct {
-	ID              int64       `json:"id"`
-	UserID          int64       `json:"user_id"`
-	Items           []OrderItem `json:"items"`
-	Total           float64     `json:"total"`
-	Status          string      `json:"status"`
-	ShippingAddress string      `json:"shipping_address"`
-	PaymentMethod   string      `json:"payment_method"`
-	CreatedAt       int64       `json:"created_at"`
-	UpdatedAt       int64       `json:"updated_at"`
+	ID               int64                        `json:"id"`
+	UserID           int64                        `json:"user_id"`                     // 0 for guest orders until they are claimed
+	GuestCustomerID  *int64                       `json:"guest_customer_id,omitempty"` // Set on guest orders
+	Email            string                       `json:"email,omitempty"`             // The email of the guest
+	Items            []OrderItem                  `json:"items"`
+	Discount         float64                      `json:"discount"` // Cart-level promotions, before taxes
+	Tax              float64                      `json:"tax"`
+	TaxLines         []TaxLine                    `json:"tax_lines,omitempty"`
+	Total            float64                      `json:"total"`
+	Status           string                       `json:"status"`
+	ShippingAddress  addresses.PostalAddress      `json:"shipping_address"`
+	BillingAddress   addresses.PostalAddress      `json:"billing_address"`
+	ShippingMethodID *int                         `json:"shipping_method_id,omitempty"`
+	ShippingMethod   string                       `json:"shipping_method"`
+	ShippingCost     float64                      `json:"shipping_cost"`
+	PaymentMethod    string                       `json:"payment_method"`
+	Discounts        []promotions.AppliedDiscount `json:"-"` // Redeemed when the order is created
+	CartID           int64                        `json:"-"` // Completed when the order is created
+	CreatedAt        int64                        `json:"created_at"`
+	UpdatedAt        int64                        `json:"updated_at"`
 }
 
+const (
+	StatusPending          = "pending"
+	StatusProcessing       = "processing"
+	StatusPartiallyShipped = "partially_shipped"
+	StatusShipped          = "shipped"
+	StatusDelivered        = "delivered"
+	StatusCancelled        = "cancelled"
+)
+
 type CreateOrderRequest struct {
-	UserID          int64  `json:"user_id" validate:"required"`
-	CartID          int64  `json:"cart_id" validate:"required"`
-	ShippingAddress string `json:"shipping_address" validate:"required"`
-	PaymentMethod   string `json:"payment_method" validate:"required"`
+	UserID        int64  `json:"-"` // The authenticated user
+	CartID        int64  `json:"cart_id" validate:"required"`
+	PaymentMethod string `json:"payment_method" validate:"required"`
+
+	// Addresses are taken from the address book by ID or given inline.
+	// Without either, the default address of the user is used, and billing falls back to shipping.
+	ShippingAddressID *int64                   `json:"shipping_address_id"`
+	ShippingAddress   *addresses.PostalAddress `json:"shipping_address" validate:"omitempty"`
+	BillingAddressID  *int64                   `json:"billing_address_id"`
+	BillingAddress    *addresses.PostalAddress `json:"billing_address" validate:"omitempty"`
+
+	// ShippingMethodID is one of the methods quoted by /carts/me/shipping-options.
+	// Without it the cheapest available method is used.
+	ShippingMethodID *int `json:"shipping_method_id"`
+}
+
+// GuestOrderRequest places an order from the guest cart of the request, for a shopper without an
+// account. The addresses are given inline, and billing falls back to shipping.
+type GuestOrderRequest struct {
+	Email            string                   `json:"email" validate:"required,email,max=255"`
+	PaymentMethod    string                   `json:"payment_method" validate:"required"`
+	ShippingAddress  *addresses.PostalAddress `json:"shipping_address" validate:"required"`
+	BillingAddress   *addresses.PostalAddress `json:"billing_address" validate:"omitempty"`
+	ShippingMethodID *int                     `json:"shipping_method_id"`
+}
+
+// ClaimOrderRequest creates an account with the email of a guest order and moves the guest's
+// orders to it.
+type ClaimOrderRequest struct {
+	Password string `json:"password" validate:"required,min=8"`
 }
 
 type UpdateOrderRequest struct {
-	Status          *string `json:"status" validate:"required,oneof=pending processing shipped delivered cancelled"`
-	ShippingAddress *string `json:"shipping_address,omitempty"`
+	Status          *string                  `json:"status" validate:"required,oneof=pending processing partially_shipped shipped delivered cancelled"`
+	ShippingAddress *addresses.PostalAddress `json:"shipping_address,omitempty" validate:"omitempty"`
 }
 
 type OrderItem struct {
 	ID        int64   `json:"id"`
 	OrderID   int64   `json:"order_id"`
 	ProductID int64   `json:"product_id"`
+	VariantID *int64  `json:"variant_id,omitempty"`
+	SKU       string  `json:"sku,omitempty"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
 }
+
+// TaxLine is the tax charged on an order item, snapshotted when the order is placed.
+type TaxLine struct {
+	ID            int64   `json:"id"`
+	OrderID       int64   `json:"order_id"`
+	ProductID     int64   `json:"product_id"`
+	VariantID     *int64  `json:"variant_id,omitempty"`
+	Name          string  `json:"name"`
+	Country       string  `json:"country"`
+	Region        string  `json:"region,omitempty"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Amount        float64 `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+}
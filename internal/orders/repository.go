@@ -1,20 +1,32 @@
 package orders
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"time"
+
+	"ecommerce-service/internal/carts"
+	"ecommerce-service/internal/events"
+	"ecommerce-service/internal/promotions"
+	"ecommerce-service/internal/users"
+	"ecommerce-service/internal/utils"
+
+	"github.com/lib/pq"
 )
 
 type OrderRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	lowStock int // Stock at or below which a StockLow event is recorded
 }
 
-func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+func NewOrderRepository(db *sql.DB, lowStockThreshold int) *OrderRepository {
+	return &OrderRepository{db: db, lowStock: lowStockThreshold}
 }
 
 func (r *OrderRepository) Create(ctx context.Context, order *Order) (*Order, error) {
@@ -30,71 +42,380 @@ func (r *OrderRepository) Create(ctx context.Context, order *Order) (*Order, err
 		}
 	}()
 
-	// 1. Insert into orders table and get the new order ID
-	orderQuery := "INSERT INTO orders (user_id, total, status, shipping_address, payment_method) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
-	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.Total, order.Status, order.ShippingAddress, order.PaymentMethod).Scan(&order.ID, &order.CreatedAt)
+	// 1. Complete the cart, which also locks it against concurrent checkouts of the same cart
+	res, err := tx.ExecContext(ctx, "UPDATE carts SET status = 'completed', updated_at = NOW() WHERE id = $1 AND status = 'active'", order.CartID)
+	if err != nil {
+		return nil, fmt.Errorf("error completing cart: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = ErrCartNotActive
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM cart_items WHERE cart_id = $1", order.CartID); err != nil {
+		return nil, fmt.Errorf("error clearing cart: %w", err)
+	}
+
+	// 2. Insert into orders table and get the new order ID
+	orderQuery := "INSERT INTO orders (user_id, guest_customer_id, total, discount, tax, status, shipping_address, billing_address, shipping_method_id, shipping_method, shipping_cost, payment_method) VALUES (NULLIF($1::INT, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at"
+	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.GuestCustomerID, order.Total, order.Discount, order.Tax, order.Status, order.ShippingAddress, order.BillingAddress, order.ShippingMethodID, order.ShippingMethod, order.ShippingCost, order.PaymentMethod).Scan(&order.ID, &order.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("error inserting order: %w", err)
 	}
 
-	// 2. Prepare statement for inserting order items
-	itemStmt, err := tx.PrepareContext(ctx, "INSERT INTO order_items (order_id, product_id, quantity, price) VALUES ($1, $2, $3, $4)")
+	// 3. Prepare statement for inserting order items
+	itemStmt, err := tx.PrepareContext(ctx, "INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, price) VALUES ($1, $2, $3, $4, $5, $6)")
 	if err != nil {
 		return nil, fmt.Errorf("error preparing order item statement: %w", err)
 	}
 	defer itemStmt.Close()
 
-	// 3. Insert all order items
+	// 4. Insert all order items
 	for i, item := range order.Items {
-		_, err := itemStmt.ExecContext(ctx, order.ID, item.ProductID, item.Quantity, item.Price)
+		_, err = itemStmt.ExecContext(ctx, order.ID, item.ProductID, item.VariantID, item.SKU, item.Quantity, item.Price)
 		if err != nil {
 			return nil, fmt.Errorf("error inserting order item #%d: %w", i+1, err)
 		}
 	}
 
-	// 4. Commit the transaction
-	if err := tx.Commit(); err != nil {
+	// 5. Take the items out of stock, failing if another order got there first
+	if err = r.reserveStock(ctx, tx, order.Items); err != nil {
+		return nil, err
+	}
+
+	// 6. Insert the tax lines
+	taxStmt, err := tx.PrepareContext(ctx, "INSERT INTO order_tax_lines (order_id, product_id, variant_id, name, country, region, rate, taxable_amount, amount, inclusive) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id")
+	if err != nil {
+		return nil, fmt.Errorf("error preparing order tax line statement: %w", err)
+	}
+	defer taxStmt.Close()
+
+	for i := range order.TaxLines {
+		line := &order.TaxLines[i]
+		line.OrderID = order.ID
+		err = taxStmt.QueryRowContext(ctx, order.ID, line.ProductID, line.VariantID, line.Name, line.Country, line.Region, line.Rate, line.TaxableAmount, line.Amount, line.Inclusive).Scan(&line.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting order tax line #%d: %w", i+1, err)
+		}
+	}
+
+	// 7. Redeem the promotions, within the usage limits
+	if err = redeem(ctx, tx, order); err != nil {
+		return nil, err
+	}
+
+	// 8. Record the event with the order
+	err = events.Record(ctx, tx, events.AggregateOrder, order.ID, events.OrderCreated, events.OrderCreatedData{
+		OrderID:         order.ID,
+		UserID:          order.UserID,
+		GuestCustomerID: order.GuestCustomerID,
+		Status:          order.Status,
+		Total:           order.Total,
+		Items:           len(order.Items),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error recording order event: %w", err)
+	}
+
+	// 9. Commit the transaction
+	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("error committing transaction: %w", err)
 	}
 
 	return order, nil
 }
 
+// reserveStock decrements the stock of the products, or of their variants, of the items. Stock is
+// only taken when enough is left, so concurrent orders cannot oversell; the items short of stock are
+// returned as out of stock changes of the cart.
+func (r *OrderRepository) reserveStock(ctx context.Context, tx *sql.Tx, items []OrderItem) error {
+	// Rows are locked in the same order by every order, so that concurrent orders do not deadlock
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b OrderItem) int {
+		return cmp.Or(cmp.Compare(a.ProductID, b.ProductID), cmp.Compare(variantOf(a), variantOf(b)))
+	})
+
+	var changes []carts.CartChange
+	for _, item := range items {
+		query := "UPDATE products SET stock = stock - $2, updated_at = NOW() WHERE id = $1 AND stock >= $2 RETURNING stock"
+		id := item.ProductID
+		if item.VariantID != nil {
+			query = "UPDATE product_variants SET stock = stock - $2, updated_at = NOW() WHERE id = $1 AND stock >= $2 RETURNING stock"
+			id = *item.VariantID
+		}
+
+		var stock int
+		err := tx.QueryRowContext(ctx, query, id, item.Quantity).Scan(&stock)
+		if errors.Is(err, sql.ErrNoRows) {
+			change, err := r.outOfStock(ctx, tx, item)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, *change)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reserving stock of product %d: %w", item.ProductID, err)
+		}
+
+		if stock+item.Quantity > r.lowStock && stock <= r.lowStock {
+			data := events.StockLowData{ProductID: item.ProductID, VariantID: item.VariantID, Stock: stock, Threshold: r.lowStock}
+			if err := events.Record(ctx, tx, events.AggregateProduct, item.ProductID, events.StockLow, data); err != nil {
+				return fmt.Errorf("error recording stock event: %w", err)
+			}
+		}
+	}
+
+	if len(changes) > 0 {
+		return &carts.ChangedError{Changes: changes}
+	}
+	return nil
+}
+
+func variantOf(item OrderItem) int64 {
+	if item.VariantID == nil {
+		return 0
+	}
+	return *item.VariantID
+}
+
+// outOfStock describes an item that could not be taken out of stock, with the stock left.
+func (r *OrderRepository) outOfStock(ctx context.Context, tx *sql.Tx, item OrderItem) (*carts.CartChange, error) {
+	query := "SELECT name, COALESCE(stock, 0) FROM products WHERE id = $1"
+	args := []any{item.ProductID}
+	if item.VariantID != nil {
+		query = "SELECT p.name, v.stock FROM product_variants v JOIN products p ON p.id = v.product_id WHERE v.id = $1"
+		args = []any{*item.VariantID}
+	}
+
+	change := &carts.CartChange{Type: carts.ChangeOutOfStock, ProductID: item.ProductID, VariantID: item.VariantID, Quantity: int64(item.Quantity)}
+	var available int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&change.Name, &available); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	change.Available = &available
+	return change, nil
+}
+
+// redeem records the use of the promotions of the order. The promotions are locked while their
+// redemptions are counted, so that concurrent orders cannot go over the usage limits.
+func redeem(ctx context.Context, tx *sql.Tx, order *Order) error {
+	if len(order.Discounts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(order.Discounts))
+	for _, d := range order.Discounts {
+		ids = append(ids, int64(d.PromotionID))
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, usage_limit, usage_limit_per_user FROM promotions WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error locking promotions: %w", err)
+	}
+	type limits struct{ total, perUser *int }
+	locked := map[int]limits{}
+	for rows.Next() {
+		var id int
+		var l limits
+		if err := rows.Scan(&id, &l.total, &l.perUser); err != nil {
+			rows.Close()
+			return err
+		}
+		locked[id] = l
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range order.Discounts {
+		l := locked[d.PromotionID]
+		if l.total != nil {
+			var used int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1", d.PromotionID).Scan(&used); err != nil {
+				return err
+			}
+			if used >= *l.total {
+				return promotions.ErrCouponUsageLimitReached
+			}
+		}
+		if l.perUser != nil {
+			// Guests are counted by the guest customer of their email, and the account it was claimed into
+			query := "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND user_id = $2"
+			args := []any{d.PromotionID, order.UserID}
+			if order.GuestCustomerID != nil {
+				query = "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND (guest_customer_id = $2 OR user_id = (SELECT user_id FROM guest_customers WHERE id = $2))"
+				args = []any{d.PromotionID, *order.GuestCustomerID}
+			}
+			var used int
+			if err := tx.QueryRowContext(ctx, query, args...).Scan(&used); err != nil {
+				return err
+			}
+			if used >= *l.perUser {
+				return promotions.ErrCouponUsageLimitReached
+			}
+		}
+
+		query := "INSERT INTO promotion_redemptions (promotion_id, user_id, guest_customer_id, order_id, amount) VALUES ($1, NULLIF($2::INT, 0), $3, $4, $5) ON CONFLICT (promotion_id, order_id) DO NOTHING"
+		if _, err := tx.ExecContext(ctx, query, d.PromotionID, order.UserID, order.GuestCustomerID, order.ID, d.Amount); err != nil {
+			return fmt.Errorf("error redeeming promotion %d: %w", d.PromotionID, err)
+		}
+	}
+	return nil
+}
+
 func (r *OrderRepository) FindByID(ctx context.Context, id int) (*Order, error) {
-	query := "SELECT id, user_id, shipping_address, payment_method, created_at FROM orders WHERE id = $1"
+	query := "SELECT o.id, COALESCE(o.user_id, 0), o.guest_customer_id, COALESCE(g.email, ''), o.status, o.total, o.discount, o.tax, o.shipping_address, o.billing_address, o.shipping_method_id, o.shipping_method, o.shipping_cost, o.payment_method, EXTRACT(EPOCH FROM o.created_at)::BIGINT FROM orders o LEFT JOIN guest_customers g ON g.id = o.guest_customer_id WHERE o.id = $1"
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var o Order
-	if err := row.Scan(&o.ID, &o.UserID, &o.ShippingAddress, &o.PaymentMethod, &o.CreatedAt); err != nil {
+	if err := row.Scan(&o.ID, &o.UserID, &o.GuestCustomerID, &o.Email, &o.Status, &o.Total, &o.Discount, &o.Tax, &o.ShippingAddress, &o.BillingAddress, &o.ShippingMethodID, &o.ShippingMethod, &o.ShippingCost, &o.PaymentMethod, &o.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
 		}
 		return nil, err
 	}
+
+	taxLines, err := r.FindTaxLines(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	o.TaxLines = taxLines
+
 	return &o, nil
 }
 
+// CreateGuestCustomer returns the ID of the guest customer with the email, creating it when missing.
+func (r *OrderRepository) CreateGuestCustomer(ctx context.Context, email string) (int64, error) {
+	query := "INSERT INTO guest_customers (email) VALUES ($1) ON CONFLICT ((LOWER(email))) DO UPDATE SET updated_at = NOW() RETURNING id"
+	var id int64
+	if err := r.db.QueryRowContext(ctx, query, email).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Claim creates the account of a guest customer and moves its unclaimed orders, and their
+// redemptions, to it, all at once. The password of the account must already be hashed.
+func (r *OrderRepository) Claim(ctx context.Context, guestCustomerID int64, u *users.CreateUserRequest) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (email, password, role_id) VALUES ($1, $2, $3) RETURNING id", u.Email, u.Password, u.RoleID).Scan(&userID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation, the email signed up meanwhile
+		err = ErrEmailRegistered
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = events.Record(ctx, tx, events.AggregateUser, userID, events.UserRegistered, events.UserRegisteredData{UserID: userID, Email: u.Email}); err != nil {
+		return 0, fmt.Errorf("error recording user event: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE guest_customers SET user_id = $2, updated_at = NOW() WHERE id = $1", guestCustomerID, userID); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE orders SET user_id = $2, updated_at = NOW() WHERE guest_customer_id = $1 AND user_id IS NULL", guestCustomerID, userID); err != nil {
+		return 0, err
+	}
+
+	// The promotions used as a guest count towards the per-user limits of the account
+	if _, err = tx.ExecContext(ctx, "UPDATE promotion_redemptions SET user_id = $2 WHERE guest_customer_id = $1 AND user_id IS NULL", guestCustomerID, userID); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return userID, nil
+}
+
+// FindTaxLines retrieves the tax lines persisted for an order.
+func (r *OrderRepository) FindTaxLines(ctx context.Context, orderID int64) ([]TaxLine, error) {
+	query := "SELECT id, order_id, product_id, variant_id, name, country, region, rate, taxable_amount, amount, inclusive FROM order_tax_lines WHERE order_id = $1 ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var lines []TaxLine
+	for rows.Next() {
+		var l TaxLine
+		if err := rows.Scan(&l.ID, &l.OrderID, &l.ProductID, &l.VariantID, &l.Name, &l.Country, &l.Region, &l.Rate, &l.TaxableAmount, &l.Amount, &l.Inclusive); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+const orderSummaryColumns = "id, user_id, status, total, shipping_address, billing_address, payment_method, EXTRACT(EPOCH FROM created_at)::BIGINT"
+
 func (r *OrderRepository) ListByUserID(ctx context.Context, userID, limit, offset int) ([]*Order, error) {
-	query := "SELECT id, user_id, shipping_address, payment_method, created_at FROM orders WHERE user_id = $1 LIMIT $2 OFFSET $3"
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	query := "SELECT " + orderSummaryColumns + " FROM orders WHERE user_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3"
+	return r.queryOrders(ctx, query, userID, limit, offset)
+}
+
+// orderKeyset lists the newest orders first, like ListByUserID.
+var orderKeyset = utils.Keyset{Columns: []string{"id"}, Desc: true}
+
+// ListPageByUserID returns up to limit orders of the user past the cursor, in the direction of the cursor.
+func (r *OrderRepository) ListPageByUserID(ctx context.Context, userID int, c *utils.Cursor, limit int) ([]*Order, error) {
+	args := []any{userID}
+	bind := func(arg any) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	cond, order, err := orderKeyset.Clause(c, bind)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + orderSummaryColumns + " FROM orders WHERE user_id = $1"
+	if cond != "" {
+		query += " AND " + cond
+	}
+	query += " ORDER BY " + order + " LIMIT " + bind(limit)
+	return r.queryOrders(ctx, query, args...)
+}
+
+func (r *OrderRepository) queryOrders(ctx context.Context, query string, args ...any) ([]*Order, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Fatal("Eror closing rows")
+			log.Printf("error closing rows: %v\n", err)
 		}
 	}()
 
 	var orders []*Order
 	for rows.Next() {
 		var o Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.ShippingAddress, &o.PaymentMethod, &o.CreatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Status, &o.Total, &o.ShippingAddress, &o.BillingAddress, &o.PaymentMethod, &o.CreatedAt); err != nil {
 			return nil, err
 		}
 		orders = append(orders, &o)
 	}
-	return orders, nil
+	return orders, rows.Err()
 }
 
 func (r *OrderRepository) Update(ctx context.Context, id int, o *UpdateOrderRequest) error {
@@ -130,22 +451,40 @@ func (r *OrderRepository) Update(ctx context.Context, id int, o *UpdateOrderRequ
 		i, // placeholder para id
 	)
 
-	// Ejecutar
-	args = append(args, id)
-	res, err := r.db.ExecContext(ctx, query, args...)
+	// Ejecutar, registrando el cambio de estado en el outbox
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var previous string
+	if err = tx.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1 FOR UPDATE", id).Scan(&previous); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("no order updated with id %d", id)
+		}
 		return err
 	}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
+	args = append(args, id)
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
 		return err
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("no order updated with id %d", id)
+	if o.Status != nil && *o.Status != previous {
+		err = events.Record(ctx, tx, events.AggregateOrder, int64(id), events.OrderStatusChanged, events.OrderStatusChangedData{OrderID: int64(id), From: previous, To: *o.Status})
+		if err != nil {
+			return fmt.Errorf("error recording order event: %w", err)
+		}
 	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
 	return nil
 }
 
@@ -1,9 +1,20 @@
 package orders
 
-import "github.com/go-chi/chi/v5"
+import (
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/idempotency"
 
-func RegisterRoutes(r chi.Router, h *OrdersHandler) {
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *OrdersHandler, am *auth.AuthMiddleware, im *idempotency.IdempotencyMiddleware) {
 	r.Route("/orders", func(r chi.Router) {
-		r.Post("/", h.Create)
+		r.With(am.VerifyToken, im.Handle).Post("/", h.Create)
+		r.With(am.VerifyToken).Get("/", h.ListByUserID)
+
+		// Guest checkout, from the guest cart of the cart token
+		r.With(im.Handle).Post("/guest", h.CreateGuest)
+		r.Get("/lookup/{token}", h.Lookup)
+		r.With(im.Handle).Post("/lookup/{token}/claim", h.Claim)
 	})
 }
@@ -2,14 +2,35 @@ package orders
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"ecommerce-service/internal/addresses"
 	"ecommerce-service/internal/carts"
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/roles"
+	"ecommerce-service/internal/shipping"
+	"ecommerce-service/internal/taxes"
+	"ecommerce-service/internal/users"
+	"ecommerce-service/internal/utils"
+	"ecommerce-service/pkg/cryptox"
+	"ecommerce-service/pkg/mailx"
 )
 
-var ErrEmptyCart = errors.New("cannot create order from an empty cart")
+var (
+	ErrEmptyCart        = errors.New("cannot create order from an empty cart")
+	ErrAddressRequired  = errors.New("a shipping address is required and the user has no default address")
+	ErrAddressNotFound  = errors.New("the address was not found in the user's address book")
+	ErrNoShippingMethod = errors.New("no shipping method delivers to the shipping address")
+	ErrOrderClaimed     = errors.New("the order has been claimed into an account, sign in to see it")
+	ErrEmailRegistered  = errors.New("an account with the email of the order already exists, sign in instead")
+	ErrCartNotOwned     = errors.New("the cart does not belong to the user")
+	ErrCartNotActive    = errors.New("the cart is no longer active, it may have been checked out already")
+)
 
 type (
 	// Repository is the interface for the order repository.
@@ -17,96 +38,347 @@ type (
 		Create(ctx context.Context, o *Order) (*Order, error)
 		FindByID(ctx context.Context, id int) (*Order, error)
 		ListByUserID(ctx context.Context, userID, limit, offset int) ([]*Order, error)
+		ListPageByUserID(ctx context.Context, userID int, c *utils.Cursor, limit int) ([]*Order, error)
 		Update(ctx context.Context, id int, o *UpdateOrderRequest) error
 		Delete(ctx context.Context, id int) error
 		CountByUserID(ctx context.Context, userID int) (int, error)
+		CreateGuestCustomer(ctx context.Context, email string) (int64, error)
+		Claim(ctx context.Context, guestCustomerID int64, u *users.CreateUserRequest) (int64, error)
 	}
 
 	// CartRepository defines the dependency on the cart repository.
 	CartRepository interface {
+		FindByID(ctx context.Context, cartID int64) (*carts.Cart, error)
+		FindGuestCart(ctx context.Context, guestID string) (*carts.Cart, error)
 		GetItems(ctx context.Context, cartID int64) ([]carts.CartItem, error)
-		SetCompleted(ctx context.Context, cartID int64) error
-		ClearCart(ctx context.Context, cartID int64) error
+	}
+
+	// CartPricer revalidates the lines of a cart against the catalog and recalculates its totals,
+	// including its promotions and taxes.
+	CartPricer interface {
+		Revalidate(ctx context.Context, cart *carts.Cart) ([]carts.CartChange, error)
+		Price(ctx context.Context, cart *carts.Cart, destination *taxes.Jurisdiction) error
+	}
+
+	// AddressBook gives access to the saved addresses of a user.
+	AddressBook interface {
+		FindByID(ctx context.Context, userID, id int64) (*addresses.Address, error)
+		FindDefault(ctx context.Context, userID int64) (*addresses.Address, error)
+	}
+
+	// ShippingQuoter quotes the shipping methods for the order destination.
+	ShippingQuoter interface {
+		Quote(ctx context.Context, country string, items []shipping.Item, subtotal int64, freeShipping bool) ([]shipping.Quote, error)
+		QuoteMethod(ctx context.Context, methodID int, country string, items []shipping.Item, subtotal int64, freeShipping bool) (*shipping.Quote, error)
+	}
+
+	// UserFinder looks up the accounts of the emails of guests who claim their orders.
+	UserFinder interface {
+		FindByEmail(ctx context.Context, email string) (*users.User, error)
+	}
+
+	// RoleFinder resolves the role given to the accounts of claimed guest orders.
+	RoleFinder interface {
+		FindByName(ctx context.Context, name string) (*roles.Role, error)
 	}
 
 	// OrderService is the service for managing orders.
 	OrderService struct {
-		orderRepo Repository
-		cartRepo  CartRepository
+		orderRepo      Repository
+		cartRepo       CartRepository
+		cartPricer     CartPricer
+		addressBook    AddressBook
+		shippingQuoter ShippingQuoter
+		userFinder     UserFinder
+		roleFinder     RoleFinder
+		mailer         mailx.Sender
+		lookupTokens   *LookupTokens
+		config         *config.Config
 	}
 )
 
 // NewOrderService creates a new OrderService.
-func NewOrderService(orderRepo Repository, cartRepo CartRepository) *OrderService {
+func NewOrderService(orderRepo Repository, cartRepo CartRepository, cartPricer CartPricer, addressBook AddressBook, shippingQuoter ShippingQuoter, userFinder UserFinder, roleFinder RoleFinder, mailer mailx.Sender, c *config.Config) *OrderService {
 	return &OrderService{
-		orderRepo: orderRepo,
-		cartRepo:  cartRepo,
+		orderRepo:      orderRepo,
+		cartRepo:       cartRepo,
+		cartPricer:     cartPricer,
+		addressBook:    addressBook,
+		shippingQuoter: shippingQuoter,
+		userFinder:     userFinder,
+		roleFinder:     roleFinder,
+		mailer:         mailer,
+		lookupTokens:   NewLookupTokens(c.OrderLookupSecret, time.Duration(c.OrderLookupTTL)*time.Second),
+		config:         c,
 	}
 }
 
-// CreateOrderFromCart creates a new order from a shopping cart.
+// CreateOrderFromCart creates a new order from a shopping cart of the user of the request.
 func (s *OrderService) CreateOrderFromCart(ctx context.Context, req *CreateOrderRequest) (*Order, error) {
-	// 1. Get items from the cart
-	cartItems, err := s.cartRepo.GetItems(ctx, req.CartID)
+	cart, err := s.cartRepo.FindByID(ctx, req.CartID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	if cart.UserID != req.UserID {
+		return nil, ErrCartNotOwned
+	}
+	if cart.Status != "active" {
+		return nil, ErrCartNotActive
+	}
+
+	// 1. Resolve the addresses to snapshot on the order
+	shippingAddress, err := s.resolveAddress(ctx, req.UserID, req.ShippingAddressID, req.ShippingAddress, nil)
+	if err != nil {
+		return nil, err
+	}
+	billingAddress, err := s.resolveAddress(ctx, req.UserID, req.BillingAddressID, req.BillingAddress, shippingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{
+		UserID:          req.UserID,
+		ShippingAddress: *shippingAddress,
+		BillingAddress:  *billingAddress,
+		PaymentMethod:   req.PaymentMethod,
+	}
+	return s.placeOrder(ctx, cart, order, req.ShippingMethodID)
+}
+
+// CreateGuestOrder creates a new order from the cart of a guest, for a guest customer with the email
+// of the request, and emails the guest a link to look the order up.
+func (s *OrderService) CreateGuestOrder(ctx context.Context, guestID string, req *GuestOrderRequest) (*Order, error) {
+	shippingAddress, err := s.resolveAddress(ctx, 0, nil, req.ShippingAddress, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cart items: %w", err)
+		return nil, err
 	}
-	if len(cartItems) == 0 {
+	billingAddress, err := s.resolveAddress(ctx, 0, nil, req.BillingAddress, shippingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartRepo.FindGuestCart(ctx, guestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEmptyCart
+		}
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+
+	guestCustomerID, err := s.orderRepo.CreateGuestCustomer(ctx, req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest customer: %w", err)
+	}
+
+	order := &Order{
+		GuestCustomerID: &guestCustomerID,
+		Email:           req.Email,
+		ShippingAddress: *shippingAddress,
+		BillingAddress:  *billingAddress,
+		PaymentMethod:   req.PaymentMethod,
+	}
+	order, err = s.placeOrder(ctx, cart, order, req.ShippingMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The order is placed even if the email is lost, the guest can still claim it from support
+	if err := s.mailer.Send(ctx, s.lookupMessage(order)); err != nil {
+		log.Printf("error: failed to email the lookup link of order %d: %v\n", order.ID, err)
+	}
+
+	return order, nil
+}
+
+// placeOrder prices the cart for the shipping address of the order and creates the order with its
+// items, taxes and shipping. The cart is completed with the order, so it can only be checked out once.
+func (s *OrderService) placeOrder(ctx context.Context, cart *carts.Cart, order *Order, shippingMethodID *int) (*Order, error) {
+	// 2. Check the cart against the current prices and stock, the client must review any change
+	changes, err := s.cartPricer.Revalidate(ctx, cart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revalidate cart: %w", err)
+	}
+	if len(changes) > 0 {
+		return nil, &carts.ChangedError{Changes: changes}
+	}
+
+	// Price the cart, applying its promotions and the taxes of the destination
+	destination := &taxes.Jurisdiction{Country: order.ShippingAddress.Country, Region: order.ShippingAddress.Region}
+	if err := s.cartPricer.Price(ctx, cart, destination); err != nil {
+		return nil, fmt.Errorf("failed to price cart: %w", err)
+	}
+	if len(cart.CartItems) == 0 {
 		return nil, ErrEmptyCart
 	}
 
-	// 2. Calculate total and prepare order items
-	var total float64
-	orderItems := make([]OrderItem, 0, len(cartItems))
-	for _, item := range cartItems {
+	quote, err := s.quoteShipping(ctx, shippingMethodID, order.ShippingAddress.Country, cart)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Prepare order items
+	orderItems := make([]OrderItem, 0, len(cart.CartItems))
+	for _, item := range cart.CartItems {
 		price := float64(item.TotalPrice) / 100.0 // Convert cents to dollars, includes discount
-		total += price
 		unitPrice := price / float64(item.Quantity)
 		orderItems = append(orderItems, OrderItem{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			SKU:       item.SKU,
 			Quantity:  int(item.Quantity),
 			Price:     unitPrice, // Unit price after discount
 		})
 	}
 
-	// 3. Create the order object
-	order := &Order{
-		UserID:          req.UserID,
-		Items:           orderItems,
-		Total:           total,
-		Status:          "pending", // Initial status
-		ShippingAddress: req.ShippingAddress,
-		PaymentMethod:   req.PaymentMethod,
+	taxLines := make([]TaxLine, 0, len(cart.TaxLines))
+	for _, line := range cart.TaxLines {
+		taxLines = append(taxLines, TaxLine{
+			ProductID:     line.ProductID,
+			VariantID:     line.VariantID,
+			Name:          line.Name,
+			Country:       line.Country,
+			Region:        line.Region,
+			Rate:          line.Rate,
+			TaxableAmount: float64(line.TaxableAmount) / 100.0,
+			Amount:        float64(line.Amount) / 100.0,
+			Inclusive:     line.Inclusive,
+		})
 	}
 
-	// 4. Use the repository to create the order transactionally
+	// 4. Complete the order, the total includes cart-level promotions, taxes and shipping
+	order.Items = orderItems
+	order.Discount = float64(cart.Discount) / 100.0
+	order.Tax = float64(cart.Tax) / 100.0
+	order.TaxLines = taxLines
+	order.ShippingMethodID = &quote.MethodID
+	order.ShippingMethod = quote.Name
+	order.ShippingCost = float64(quote.Cost) / 100.0
+	order.Total = float64(cart.Total+quote.Cost) / 100.0
+	order.Status = StatusPending // Initial status
+	order.Discounts = cart.Discounts
+	order.CartID = cart.ID
+
+	// 5. Use the repository to create the order and complete the cart transactionally
 	createdOrder, err := s.orderRepo.Create(ctx, order)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order in repository: %w", err)
 	}
 
-	// 5. Mark cart as completed and clear it.
-	// If either cleanup step fails, roll back the order and return an error.
-	if err := s.cartRepo.SetCompleted(ctx, req.CartID); err != nil {
-		log.Printf("error: failed to mark cart %d as completed: %v\n", req.CartID, err)
-		// Compensating transaction: delete the created order
-		if delErr := s.orderRepo.Delete(ctx, createdOrder.ID); delErr != nil {
-			log.Printf("critical: failed to delete order %d after cart cleanup failure: %v\n", createdOrder.ID, delErr)
-			return nil, fmt.Errorf("failed to mark cart as completed (and failed to roll back order): %v, %v", err, delErr)
+	return createdOrder, nil
+}
+
+// FindByLookupToken returns the guest order of a lookup link, as long as it has not been claimed.
+func (s *OrderService) FindByLookupToken(ctx context.Context, token string) (*Order, error) {
+	id, err := s.lookupTokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orderRepo.FindByID(ctx, int(id))
+	if err != nil {
+		return nil, err
+	}
+	if order.GuestCustomerID == nil {
+		return nil, ErrInvalidLookupToken
+	}
+	if order.UserID != 0 {
+		return nil, ErrOrderClaimed
+	}
+	return order, nil
+}
+
+// Claim creates an account with the email of the guest order of a lookup link and moves the orders
+// of the guest customer to it, both at once.
+func (s *OrderService) Claim(ctx context.Context, token string, req *ClaimOrderRequest) (*Order, error) {
+	order, err := s.FindByLookupToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userFinder.FindByEmail(ctx, order.Email); err == nil {
+		return nil, ErrEmailRegistered
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	role, err := s.roleFinder.FindByName(ctx, roles.RoleUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the role of customers: %w", err)
+	}
+	password, err := cryptox.HashPassword(req.Password, s.config.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.orderRepo.Claim(ctx, *order.GuestCustomerID, &users.CreateUserRequest{Email: order.Email, Password: password, RoleID: role.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim orders: %w", err)
+	}
+	order.UserID = userID
+	return order, nil
+}
+
+func (s *OrderService) lookupMessage(order *Order) mailx.Message {
+	link := strings.TrimRight(s.config.OrderLookupURL, "/") + "/" + s.lookupTokens.New(order.ID)
+	return mailx.Message{
+		To:      order.Email,
+		Subject: fmt.Sprintf("Tu pedido #%d", order.ID),
+		Body: fmt.Sprintf("Hola,\n\nHemos recibido tu pedido #%d por un total de %.2f.\n\n"+
+			"Puedes consultarlo en este enlace y, si quieres, crear una cuenta para guardarlo:\n%s\n", order.ID, order.Total, link),
+	}
+}
+
+// quoteShipping prices the chosen shipping method, or the cheapest one when none was chosen.
+func (s *OrderService) quoteShipping(ctx context.Context, methodID *int, country string, cart *carts.Cart) (*shipping.Quote, error) {
+	items := carts.ShippingItems(cart.CartItems)
+	subtotal := cart.Subtotal - cart.Discount
+
+	if methodID != nil {
+		return s.shippingQuoter.QuoteMethod(ctx, *methodID, country, items, subtotal, cart.FreeShipping)
+	}
+
+	quotes, err := s.shippingQuoter.Quote(ctx, country, items, subtotal, cart.FreeShipping)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotes) == 0 {
+		return nil, ErrNoShippingMethod
+	}
+	return &quotes[0], nil
+}
+
+// resolveAddress picks the address from the address book, the inline value, the fallback or the user's default, in that order.
+// The returned value is a copy, so later changes to the address book do not alter the order.
+func (s *OrderService) resolveAddress(ctx context.Context, userID int64, id *int64, inline *addresses.PostalAddress, fallback *addresses.PostalAddress) (*addresses.PostalAddress, error) {
+	switch {
+	case id != nil:
+		a, err := s.addressBook.FindByID(ctx, userID, *id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrAddressNotFound
+			}
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to mark cart as completed: %w (order rolled back)", err)
-	}
-	if err := s.cartRepo.ClearCart(ctx, req.CartID); err != nil {
-		log.Printf("error: failed to clear cart %d: %v\n", req.CartID, err)
-		// Compensating transaction: delete the created order
-		if delErr := s.orderRepo.Delete(ctx, createdOrder.ID); delErr != nil {
-			log.Printf("critical: failed to delete order %d after cart cleanup failure: %v\n", createdOrder.ID, delErr)
-			return nil, fmt.Errorf("failed to clear cart (and failed to roll back order): %v, %v", err, delErr)
+		return &a.PostalAddress, nil
+	case inline != nil:
+		if err := addresses.ValidateForCountry(*inline); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to clear cart: %w (order rolled back)", err)
+		snapshot := *inline
+		return &snapshot, nil
+	case fallback != nil:
+		snapshot := *fallback
+		return &snapshot, nil
 	}
 
-	return createdOrder, nil
+	a, err := s.addressBook.FindDefault(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAddressRequired
+		}
+		return nil, err
+	}
+	return &a.PostalAddress, nil
 }
 
 // FindByID is a pass-through to the repository.
@@ -120,6 +392,20 @@ func (s *OrderService) ListByUserID(ctx context.Context, userID, page, limit int
 	return s.orderRepo.ListByUserID(ctx, userID, limit, offset)
 }
 
+// ListPageByUserID returns a keyset-paginated page of the orders of a user with the cursors of the
+// next and previous pages.
+func (s *OrderService) ListPageByUserID(ctx context.Context, userID int, c *utils.Cursor, limit int) ([]*Order, *string, *string, error) {
+	orders, err := s.orderRepo.ListPageByUserID(ctx, userID, c, limit+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	orders, next, prev := utils.CursorPage(orders, limit, c, "", func(o *Order) ([]string, int64) {
+		return nil, o.ID
+	})
+	return orders, next, prev, nil
+}
+
 // Update is a pass-through to the repository.
 func (s *OrderService) Update(ctx context.Context, id int, o *UpdateOrderRequest) error {
 	return s.orderRepo.Update(ctx, id, o)
@@ -2,10 +2,17 @@ package products
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"ecommerce-service/internal/auth"
 	"ecommerce-service/internal/config"
 	"ecommerce-service/internal/utils"
 	"ecommerce-service/pkg/httpx"
@@ -17,10 +24,23 @@ import (
 type Service interface {
 	Create(ctx context.Context, data *CreateProductRequest) error
 	FindByID(ctx context.Context, id int) (*Product, error)
-	FindAll(ctx context.Context, limit, offset int) ([]Product, error)
+	FindAll(ctx context.Context, params SearchParams, limit, page int) ([]Product, error)
+	FindPage(ctx context.Context, params SearchParams, c *utils.Cursor, limit int) ([]Product, *string, *string, error)
 	Update(ctx context.Context, id int, data UpdateProductRequest) error
 	Delete(ctx context.Context, id int) error
-	Count(ctx context.Context) (int, error)
+	Count(ctx context.Context, params SearchParams) (int, error)
+	Facets(ctx context.Context, params SearchParams) (*Facets, error)
+	CreateOption(ctx context.Context, productID int, data *CreateOptionRequest) (*Option, error)
+	DeleteOption(ctx context.Context, productID, optionID int) error
+	CreateVariant(ctx context.Context, productID int, data *CreateVariantRequest) (*Variant, error)
+	UpdateVariant(ctx context.Context, productID int, variantID int64, data UpdateVariantRequest) (*Variant, error)
+	DeleteVariant(ctx context.Context, productID int, variantID int64) error
+	SetCategories(ctx context.Context, id int, categoryIDs []int) (*Product, error)
+	Import(ctx context.Context, rows *RowReader, dryRun bool) (*ImportReport, error)
+	Export(ctx context.Context, fn func(*CatalogRow) error) error
+	SchedulePrice(ctx context.Context, productID int, data *SchedulePriceRequest) (*Price, error)
+	CancelPrice(ctx context.Context, productID int, id int64) error
+	PriceHistory(ctx context.Context, productID int, admin bool) ([]Price, error)
 }
 
 type ProductHandler struct {
@@ -62,20 +82,43 @@ func (ph *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	httpx.HTTPResponse(w, http.StatusCreated, map[string]string{"message": httpx.CreatedResponse})
 }
 
+// FindAll searches the catalog with ?q, ?min_price, ?max_price, ?category_id, ?in_stock and ?sort,
+// returning the facets of the search alongside the page of products. It paginates with page/limit,
+// or with keyset pagination when ?cursor is present (empty for the first page). Admins see the
+// products of every status, or those of ?status; everyone else only sees active products.
 func (ph *ProductHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	params, errs := parseSearchParams(r.URL.Query())
+	if errs != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+	if !auth.IsAdmin(ctx) {
+		params.Status = StatusActive
+	}
+
+	if err := ph.validate.Struct(params); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if r.URL.Query().Has("cursor") {
+		ph.findPage(w, r, params)
+		return
+	}
+
 	limitStr := r.URL.Query().Get("limit")
 	pageStr := r.URL.Query().Get("page")
 
 	page, limit := utils.ParsePaginationParams(pageStr, limitStr, ph.config.Limit, ph.config.MaxLimit)
-	total, err := ph.productService.Count(ctx)
+	total, err := ph.productService.Count(ctx, params)
 	if err != nil {
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
 		return
 	}
 
-	products, err := ph.productService.FindAll(ctx, limit, page)
+	products, err := ph.productService.FindAll(ctx, params, limit, page)
 	if err != nil {
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
 		return
@@ -85,7 +128,94 @@ func (ph *ProductHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 		products = []Product{}
 	}
 
-	httpx.HTTPPaginatedResponse(w, http.StatusOK, products, page, limit, total)
+	facets, err := ph.productService.Facets(ctx, params)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPPaginatedResponseWith(w, http.StatusOK, products, page, limit, total, map[string]any{"facets": facets})
+}
+
+func (ph *ProductHandler) findPage(w http.ResponseWriter, r *http.Request, params SearchParams) {
+	ctx := r.Context()
+	c, limit, err := utils.ParseCursorParams(r.URL.Query().Get("cursor"), r.URL.Query().Get("limit"), effectiveSort(params), ph.config.Limit, ph.config.MaxLimit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	products, next, prev, err := ph.productService.FindPage(ctx, params, c, limit)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if products == nil {
+		products = []Product{}
+	}
+
+	facets, err := ph.productService.Facets(ctx, params)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPCursorResponseWith(w, http.StatusOK, products, next, prev, map[string]any{"facets": facets})
+}
+
+// parseSearchParams reads the search parameters of the query string, reporting the malformed ones.
+func parseSearchParams(q url.Values) (SearchParams, map[string]string) {
+	params := SearchParams{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Sort:   q.Get("sort"),
+		Status: q.Get("status"),
+	}
+	errs := map[string]string{}
+
+	parsePrice := func(key string) *float64 {
+		v := q.Get(key)
+		if v == "" {
+			return nil
+		}
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			errs[key] = fmt.Sprintf("the %s field must be a number", key)
+			return nil
+		}
+		return &price
+	}
+	params.MinPrice = parsePrice("min_price")
+	params.MaxPrice = parsePrice("max_price")
+	if params.MinPrice != nil && params.MaxPrice != nil && *params.MinPrice > *params.MaxPrice {
+		errs["max_price"] = "the max_price field must be greater than or equal to min_price"
+	}
+
+	if v := q.Get("category_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs["category_id"] = "the category_id field must be an integer"
+		} else {
+			params.CategoryID = &id
+		}
+	}
+
+	if v := q.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			errs["in_stock"] = "the in_stock field must be true or false"
+		}
+		params.InStock = inStock
+	}
+
+	if len(errs) > 0 {
+		return params, errs
+	}
+	return params, nil
 }
 
 func (ph *ProductHandler) FindByID(w http.ResponseWriter, r *http.Request) {
@@ -105,6 +235,12 @@ func (ph *ProductHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
 	}
 
+	// Drafts and archived products are only visible to admins
+	if product.Status != StatusActive && !auth.IsAdmin(ctx) {
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		return
+	}
+
 	httpx.HTTPResponse(w, http.StatusOK, &product)
 }
 
@@ -150,9 +286,377 @@ func (ph *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	err = ph.productService.Delete(ctx, id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
 		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
 		return
 	}
 
 	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
 }
+
+func (ph *ProductHandler) CreateOption(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := CreateOptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := ph.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	option, err := ph.productService.CreateOption(ctx, id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrProductHasVariants):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusConflict, httpx.ConflictError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, option)
+}
+
+func (ph *ProductHandler) DeleteOption(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	optionID, err := strconv.Atoi(chi.URLParam(r, "optionID"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := ph.productService.DeleteOption(ctx, id, optionID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrProductHasVariants):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+func (ph *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := CreateVariantRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := ph.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	variant, err := ph.productService.CreateVariant(ctx, id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrInvalidVariantOptions):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrDuplicateVariant):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			// SKU and barcode are unique
+			httpx.HTTPError(w, http.StatusConflict, httpx.ConflictError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, variant)
+}
+
+func (ph *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	variantID, err := strconv.ParseInt(chi.URLParam(r, "variantID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateVariantRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdateVariantRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := ph.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	variant, err := ph.productService.UpdateVariant(ctx, id, variantID, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusConflict, httpx.ConflictError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, variant)
+}
+
+func (ph *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	variantID, err := strconv.ParseInt(chi.URLParam(r, "variantID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := ph.productService.DeleteVariant(ctx, id, variantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+func (ph *ProductHandler) SetCategories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := SetCategoriesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CategoryIDs == nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := ph.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	product, err := ph.productService.SetCategories(ctx, id, req.CategoryIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrUnknownCategory):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, product)
+}
+
+// Import creates and updates products and variants from a CSV or NDJSON file sent as the request
+// body. The format is taken from ?format or the Content-Type. With ?dry_run=true nothing is saved.
+func (ph *ProductHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format, err := ParseFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"dry_run": "the dry_run field must be a boolean"})
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, ph.config.ImportMaxBytes)
+	rows, err := NewRowReader(body, format, ph.validate)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	report, err := ph.productService.Import(ctx, rows, dryRun)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, report)
+}
+
+func writeImportError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		httpx.HTTPError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the file must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, ErrInvalidCSVHeader), errors.Is(err, ErrMalformedImport):
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("error importing products: %v\n", err)
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
+
+// Export streams every product and variant as CSV, or as NDJSON with ?format=ndjson, in the
+// layout accepted by Import.
+func (ph *ProductHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatCSV
+	}
+	if _, ok := ContentTypes[format]; !ok {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"format": ErrUnsupportedFormat.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	// The status is sent with the first row, so a failure past this point can only cut the file short
+	rw := NewRowWriter(w, format)
+	if err := ph.productService.Export(ctx, rw.Write); err != nil {
+		log.Printf("error exporting products: %v\n", err)
+		return
+	}
+	if err := rw.Flush(); err != nil {
+		log.Printf("error exporting products: %v\n", err)
+	}
+}
+
+// SchedulePrice adds a regular price change or a sale to the price history of the product.
+func (ph *ProductHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := SchedulePriceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := ph.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	price, err := ph.productService.SchedulePrice(ctx, id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrPriceInPast), errors.Is(err, ErrInvalidPriceRange):
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, price)
+}
+
+// CancelPrice removes a price that has not started yet, or ends a running sale.
+func (ph *ProductHandler) CancelPrice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	priceID, err := strconv.ParseInt(chi.URLParam(r, "priceID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := ph.productService.CancelPrice(ctx, id, priceID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrPriceStarted):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+// PriceHistory lists the prices of the product, latest first. Admins also see the prices that
+// have not started yet.
+func (ph *ProductHandler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	prices, err := ph.productService.PriceHistory(ctx, id, auth.IsAdmin(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, prices)
+}
@@ -5,26 +5,232 @@ import (
 	"time"
 )
 
+// Product statuses. Only active products are shown outside the admin; drafts become active at
+// their publish_at date and active products are archived at their unpublish_at date.
+const (
+	StatusDraft    = "draft"
+	StatusActive   = "active"
+	StatusArchived = "archived"
+)
+
 type Product struct {
-	ID          int32      `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description,omitempty"`
-	Price       float64    `json:"price"`
-	Stock       int        `json:"stock,omitempty"`
-	CreatedAt   *time.Time `json:"created_at,omitempty"`
-	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	ID           int32      `json:"id"`
+	Name         string     `json:"name"`
+	Status       string     `json:"status"`
+	PublishAt    *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt  *time.Time `json:"unpublish_at,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Price        float64    `json:"price"`                   // Effective price, the sale price during a sale
+	RegularPrice *float64   `json:"regular_price,omitempty"` // Price before the running sale
+	Rating       Rating     `json:"rating"`
+	Stock        int        `json:"stock,omitempty"`
+	TaxCategory  string     `json:"tax_category"`
+	WeightGrams  int        `json:"weight_grams"`
+	LengthCm     float64    `json:"length_cm"`
+	WidthCm      float64    `json:"width_cm"`
+	HeightCm     float64    `json:"height_cm"`
+	Categories   []Category `json:"categories"`
+	Images       []Image    `json:"images"`
+	Options      []Option   `json:"options,omitempty"`
+	Variants     []Variant  `json:"variants,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+
+	rank float64 // Relevance to the full-text query of a search
+}
+
+// Rating sums up the approved reviews of a product.
+type Rating struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
 }
 
 type CreateProductRequest struct {
-	Name        string  `json:"name" validate:"required,min=3"`
-	Description string  `json:"description" validate:"required"`
-	Price       float64 `json:"price" validate:"required,gt=0"`
-	Stock       int     `json:"stock" validate:"required,gte=0"`
+	Name        string     `json:"name" validate:"required,min=3"`
+	Description string     `json:"description" validate:"required"`
+	Price       float64    `json:"price" validate:"required,gt=0"`
+	Stock       int        `json:"stock" validate:"required,gte=0"`
+	TaxCategory string     `json:"tax_category"`
+	WeightGrams int        `json:"weight_grams" validate:"gte=0"`
+	LengthCm    float64    `json:"length_cm" validate:"gte=0"`
+	WidthCm     float64    `json:"width_cm" validate:"gte=0"`
+	HeightCm    float64    `json:"height_cm" validate:"gte=0"`
+	Status      string     `json:"status" validate:"omitempty,oneof=draft active archived"` // Active by default
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at" validate:"omitempty,gtfield=PublishAt"`
 }
 
 type UpdateProductRequest struct {
-	Name        *string  `json:"name"`
+	Name        *string    `json:"name"`
+	Description *string    `json:"description,omitempty"`
+	Price       *float64   `json:"price"`
+	Stock       *int       `json:"stock,omitempty"`
+	TaxCategory *string    `json:"tax_category,omitempty"`
+	WeightGrams *int       `json:"weight_grams,omitempty" validate:"omitempty,gte=0"`
+	LengthCm    *float64   `json:"length_cm,omitempty" validate:"omitempty,gte=0"`
+	WidthCm     *float64   `json:"width_cm,omitempty" validate:"omitempty,gte=0"`
+	HeightCm    *float64   `json:"height_cm,omitempty" validate:"omitempty,gte=0"`
+	Status      *string    `json:"status,omitempty" validate:"omitempty,oneof=draft active archived"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+}
+
+// Category is a category the product is assigned to.
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// SetCategoriesRequest replaces the categories of a product; an empty list removes them all.
+type SetCategoriesRequest struct {
+	CategoryIDs []int `json:"category_ids" validate:"dive,gt=0"`
+}
+
+// Image is an image of the product, listed in display order.
+type Image struct {
+	ID           int64  `json:"id"`
+	URL          string `json:"url"`
+	MediumURL    string `json:"medium_url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	AltText      string `json:"alt_text"`
+}
+
+// Option is an option type of a product, such as size or colour, with its possible values.
+type Option struct {
+	ID     int           `json:"id"`
+	Name   string        `json:"name"`
+	Values []OptionValue `json:"values"`
+}
+
+type OptionValue struct {
+	ID    int    `json:"id"`
+	Value string `json:"value"`
+}
+
+// Variant is a sellable combination of option values with its own SKU and stock.
+type Variant struct {
+	ID            int64             `json:"id"`
+	ProductID     int32             `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Barcode       *string           `json:"barcode,omitempty"`
+	PriceOverride *float64          `json:"price_override,omitempty"`
+	Price         float64           `json:"price"` // PriceOverride or the product price
+	Stock         int               `json:"stock"`
+	Options       map[string]string `json:"options"` // Option name to value, e.g. {"size": "M"}
+	CreatedAt     *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time        `json:"updated_at,omitempty"`
+}
+
+type CreateOptionRequest struct {
+	Name   string   `json:"name" validate:"required,max=50"`
+	Values []string `json:"values" validate:"required,min=1,dive,required,max=100"`
+}
+
+// CreateVariantRequest must give a value for every option of the product.
+type CreateVariantRequest struct {
+	SKU           string            `json:"sku" validate:"required,max=64"`
+	Barcode       *string           `json:"barcode" validate:"omitempty,max=64"`
+	PriceOverride *float64          `json:"price_override" validate:"omitempty,gt=0"`
+	Stock         int               `json:"stock" validate:"gte=0"`
+	Options       map[string]string `json:"options" validate:"required"`
+}
+
+type UpdateVariantRequest struct {
+	SKU           *string  `json:"sku,omitempty" validate:"omitempty,max=64"`
+	Barcode       *string  `json:"barcode,omitempty" validate:"omitempty,max=64"`
+	PriceOverride *float64 `json:"price_override,omitempty" validate:"omitempty,gt=0"`
+	Stock         *int     `json:"stock,omitempty" validate:"omitempty,gte=0"`
+}
+
+// SearchParams filters and sorts the catalog; zero values disable a filter.
+type SearchParams struct {
+	Query      string   `validate:"max=200"`
+	MinPrice   *float64 `validate:"omitempty,gte=0"`
+	MaxPrice   *float64 `validate:"omitempty,gte=0"`
+	CategoryID *int     `validate:"omitempty,gt=0"` // Includes the products of its subcategories
+	InStock    bool
+	Sort       string `validate:"omitempty,oneof=price -price created_at -created_at name -name"`
+	Status     string `validate:"omitempty,oneof=draft active archived"` // Empty for any status, only for admins
+}
+
+// Facets counts the products matching a search by category and by price bucket. Each facet ignores
+// its own filter, so the counts show what selecting another category or price range would return.
+type Facets struct {
+	Categories   []CategoryFacet `json:"categories"`
+	PriceBuckets []PriceBucket   `json:"price_buckets"`
+}
+
+type CategoryFacet struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket counts the products priced from Min (inclusive) to Max (exclusive, open-ended when nil).
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+// CatalogRow is a line of a catalog import or export. A row with a SKU updates that variant;
+// any other row creates or updates the product with its name. Nil fields are left unchanged.
+type CatalogRow struct {
+	Line        int      `json:"-"` // Line of the row in the imported file
+	Name        string   `json:"name,omitempty" validate:"omitempty,min=3"`
+	SKU         string   `json:"sku,omitempty" validate:"omitempty,max=64"`
 	Description *string  `json:"description,omitempty"`
-	Price       *float64 `json:"price"`
-	Stock       *int     `json:"stock,omitempty"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty,gt=0"` // Price override for variants
+	Stock       *int     `json:"stock,omitempty" validate:"omitempty,gte=0"`
+	TaxCategory *string  `json:"tax_category,omitempty"`
+	WeightGrams *int     `json:"weight_grams,omitempty" validate:"omitempty,gte=0"`
+	LengthCm    *float64 `json:"length_cm,omitempty" validate:"omitempty,gte=0"`
+	WidthCm     *float64 `json:"width_cm,omitempty" validate:"omitempty,gte=0"`
+	HeightCm    *float64 `json:"height_cm,omitempty" validate:"omitempty,gte=0"`
+	Barcode     *string  `json:"barcode,omitempty" validate:"omitempty,max=64"`
+	Status      *string  `json:"status,omitempty" validate:"omitempty,oneof=draft active archived"`
+}
+
+// ImportReport summarises an import. On a dry run nothing is saved, but the counts and errors are
+// those the import would produce.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Rows    int           `json:"rows"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError lists the problems of a row that was skipped, by field.
+type ImportError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+// Price kinds. A regular price applies from its valid_from until the next regular price starts; a
+// sale price applies between valid_from and valid_to and takes precedence over the regular price.
+const (
+	PriceRegular = "regular"
+	PriceSale    = "sale"
+)
+
+// Price is an entry of the price history of a product.
+type Price struct {
+	ID        int64      `json:"id"`
+	ProductID int32      `json:"product_id"`
+	Price     float64    `json:"price"`
+	Kind      string     `json:"kind"`
+	ValidFrom time.Time  `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"` // End of a sale, or start of the next regular price
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// SchedulePriceRequest schedules a regular price change or a sale, starting now unless ValidFrom is
+// given. Sales must end, regular prices last until the next one.
+type SchedulePriceRequest struct {
+	Price     float64    `json:"price" validate:"required,gt=0"`
+	Kind      string     `json:"kind" validate:"required,oneof=regular sale"`
+	ValidFrom *time.Time `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"`
 }
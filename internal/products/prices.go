@@ -0,0 +1,128 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+var (
+	ErrPriceInPast       = errors.New("prices cannot be scheduled in the past")
+	ErrInvalidPriceRange = errors.New("a sale needs a valid_to after its valid_from, and a regular price cannot have one")
+	ErrPriceStarted      = errors.New("the price has already started and can no longer be cancelled")
+)
+
+// pricedProducts joins the products p with their price at the time of the query in ep.price: the
+// lowest running sale price or else the latest regular price that has started, falling back to the
+// list price of products without price history. ep.regular_price is set during a sale.
+const pricedProducts = `products p
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(s.price, r.price) AS price, CASE WHEN s.price IS NOT NULL THEN r.price END AS regular_price
+		FROM (
+			SELECT COALESCE((
+				SELECT pp.price FROM product_prices pp
+				WHERE pp.product_id = p.id AND pp.kind = 'regular' AND pp.valid_from <= NOW()
+				ORDER BY pp.valid_from DESC, pp.id DESC
+				LIMIT 1
+			), p.price) AS price
+		) r, (
+			SELECT MIN(pp.price) AS price FROM product_prices pp
+			WHERE pp.product_id = p.id AND pp.kind = 'sale' AND pp.valid_from <= NOW() AND pp.valid_to > NOW()
+		) s
+	) ep`
+
+// recordRegularPrice starts a regular price ($2) for the product ($1) now, unless it is already
+// the regular price in effect.
+const recordRegularPrice = `INSERT INTO product_prices (product_id, price, kind, valid_from)
+	SELECT $1, $2, 'regular', NOW()
+	WHERE $2::NUMERIC IS DISTINCT FROM (
+		SELECT price FROM product_prices
+		WHERE product_id = $1 AND kind = 'regular' AND valid_from <= NOW()
+		ORDER BY valid_from DESC, id DESC
+		LIMIT 1
+	)`
+
+// CreatePrice schedules a price for the product.
+func (pr *ProductRepository) CreatePrice(ctx context.Context, productID int, data SchedulePriceRequest) (*Price, error) {
+	query := `INSERT INTO product_prices (product_id, price, kind, valid_from, valid_to)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()), $5)
+		RETURNING id, product_id, price, kind, valid_from, valid_to, created_at`
+	row := pr.db.QueryRowContext(ctx, query, productID, data.Price, data.Kind, data.ValidFrom, data.ValidTo)
+	return scanPrice(row)
+}
+
+// CancelPrice deletes a price that has not started yet, or ends a running sale now. It returns
+// sql.ErrNoRows when the product has no such price and ErrPriceStarted for any other started price.
+func (pr *ProductRepository) CancelPrice(ctx context.Context, productID int, id int64) error {
+	query := "DELETE FROM product_prices WHERE id = $1 AND product_id = $2 AND valid_from > NOW()"
+	res, err := pr.db.ExecContext(ctx, query, id, productID)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+
+	query = "UPDATE product_prices SET valid_to = NOW() WHERE id = $1 AND product_id = $2 AND kind = 'sale' AND valid_to > NOW()"
+	res, err = pr.db.ExecContext(ctx, query, id, productID)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+
+	var exists bool
+	query = "SELECT EXISTS (SELECT 1 FROM product_prices WHERE id = $1 AND product_id = $2)"
+	if err := pr.db.QueryRowContext(ctx, query, id, productID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrPriceStarted
+}
+
+// FindPriceHistory returns the prices of the product, latest first. Unless scheduled is set, the
+// prices that have not started are left out, also as the end of the regular price before them.
+func (pr *ProductRepository) FindPriceHistory(ctx context.Context, productID int, scheduled bool) ([]Price, error) {
+	query := `SELECT id, product_id, price, kind, valid_from,
+			CASE WHEN kind = 'regular' THEN LEAD(valid_from) OVER (PARTITION BY kind ORDER BY valid_from, id) ELSE valid_to END,
+			created_at
+		FROM product_prices
+		WHERE product_id = $1 AND ($2 OR valid_from <= NOW())
+		ORDER BY valid_from DESC, id DESC`
+	rows, err := pr.db.QueryContext(ctx, query, productID, scheduled)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	prices := []Price{}
+	for rows.Next() {
+		p, err := scanPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, *p)
+	}
+
+	return prices, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPrice(s scanner) (*Price, error) {
+	var p Price
+	if err := s.Scan(&p.ID, &p.ProductID, &p.Price, &p.Kind, &p.ValidFrom, &p.ValidTo, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -3,23 +3,64 @@ package products
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
+
+	"ecommerce-service/internal/events"
+
+	"github.com/lib/pq"
 )
 
 type ProductRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	lowStock int // Stock at or below which a StockLow event is recorded
+}
+
+func NewProductRepository(db *sql.DB, lowStockThreshold int) *ProductRepository {
+	return &ProductRepository{db: db, lowStock: lowStockThreshold}
 }
 
-func NewProductRepository(db *sql.DB) *ProductRepository {
-	return &ProductRepository{db: db}
+// productColumns are the columns of a product read from pricedProducts, with its current price and rating.
+const productColumns = "p.id, p.name, p.status, p.publish_at, p.unpublish_at, ep.price, ep.regular_price, COALESCE(ROUND(p.rating_sum::NUMERIC / NULLIF(p.rating_count, 0), 2), 0), p.rating_count, p.description, p.stock, p.tax_category, p.weight_grams, p.length_cm, p.width_cm, p.height_cm, p.created_at, p.updated_at"
+
+// productDest returns the scan destinations of productColumns.
+func productDest(p *Product) []any {
+	return []any{&p.ID, &p.Name, &p.Status, &p.PublishAt, &p.UnpublishAt, &p.Price, &p.RegularPrice, &p.Rating.Average, &p.Rating.Count, &p.Description, &p.Stock, &p.TaxCategory, &p.WeightGrams, &p.LengthCm, &p.WidthCm, &p.HeightCm, &p.CreatedAt, &p.UpdatedAt}
+}
+
+func scanProduct(s scanner) (*Product, error) {
+	var p Product
+	if err := s.Scan(productDest(&p)...); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// recordStockLow records a StockLow event when the stock of the product, or of its variant, falls
+// from above the threshold to it or below it.
+func recordStockLow(ctx context.Context, tx *sql.Tx, threshold int, productID int64, variantID *int64, previous, current int) error {
+	if previous <= threshold || current > threshold {
+		return nil
+	}
+	data := events.StockLowData{ProductID: productID, VariantID: variantID, Stock: current, Threshold: threshold}
+	if err := events.Record(ctx, tx, events.AggregateProduct, productID, events.StockLow, data); err != nil {
+		return fmt.Errorf("error recording stock event: %w", err)
+	}
+	return nil
 }
 
 func (pr *ProductRepository) Create(ctx context.Context, data CreateProductRequest) error {
-	query := "INSERT INTO products (name, price, description, stock) VALUES ($1, $2, $3, $4) RETURNING name, price, description, stock"
-	_, err := pr.db.ExecContext(ctx, query, data.Name, data.Price, data.Description, data.Stock)
+	// The price also starts the price history of the product
+	query := `WITH created AS (
+			INSERT INTO products (name, price, description, stock, tax_category, weight_grams, length_cm, width_cm, height_cm, status, publish_at, unpublish_at)
+			VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'standard'), $6, $7, $8, $9, COALESCE(NULLIF($10, ''), 'active'), $11, $12)
+			RETURNING id, price
+		)
+		INSERT INTO product_prices (product_id, price, kind, valid_from) SELECT id, price, 'regular', NOW() FROM created`
+	_, err := pr.db.ExecContext(ctx, query, data.Name, data.Price, data.Description, data.Stock, data.TaxCategory, data.WeightGrams, data.LengthCm, data.WidthCm, data.HeightCm, data.Status, data.PublishAt, data.UnpublishAt)
 	if err != nil {
 		return err
 	}
@@ -28,24 +69,20 @@ func (pr *ProductRepository) Create(ctx context.Context, data CreateProductReque
 }
 
 func (pr *ProductRepository) FindByID(ctx context.Context, id int) (*Product, error) {
-	query := "SELECT id, name, price, description, stock, created_at, updated_at FROM products WHERE id = $1"
-	row := pr.db.QueryRowContext(ctx, query, id)
-	var product Product
-	err := row.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &product.Stock, &product.CreatedAt, &product.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return &product, nil
+	query := "SELECT " + productColumns + " FROM " + pricedProducts + " WHERE p.id = $1 AND p.deleted_at IS NULL"
+	return scanProduct(pr.db.QueryRowContext(ctx, query, id))
 }
 
-func (pr *ProductRepository) FindAll(ctx context.Context, limit, offset int) ([]Product, error) {
-	query := "SELECT id, name, price, description, stock, created_at, updated_at FROM products ORDER BY id LIMIT $1 OFFSET $2"
+// FindAllByCategories returns the active products assigned to any of the categories.
+func (pr *ProductRepository) FindAllByCategories(ctx context.Context, categoryIDs []int, limit, offset int) ([]Product, error) {
+	query := `SELECT ` + productColumns + ` FROM ` + pricedProducts + `
+		WHERE p.id IN (SELECT product_id FROM product_category WHERE category_id = ANY($1)) AND p.status = 'active' AND p.deleted_at IS NULL
+		ORDER BY p.id LIMIT $2 OFFSET $3`
 
-	rows, err := pr.db.QueryContext(ctx, query, limit, offset)
+	rows, err := pr.db.QueryContext(ctx, query, pq.Array(categoryIDs), limit, offset)
 	if err != nil {
 		return nil, err
 	}
-
 	defer func() {
 		if err := rows.Close(); err != nil {
 			log.Printf("error closing rows: %v\n", err)
@@ -53,21 +90,27 @@ func (pr *ProductRepository) FindAll(ctx context.Context, limit, offset int) ([]
 	}()
 
 	var products []Product
-
 	for rows.Next() {
-		var product Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &product.Stock, &product.CreatedAt, &product.UpdatedAt); err != nil {
-			log.Printf("error scanning product: %v\n", err)
+		product, err := scanProduct(rows)
+		if err != nil {
 			return nil, err
 		}
-		products = append(products, product)
+		products = append(products, *product)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+	return products, rows.Err()
+}
 
-	return products, nil
+func (pr *ProductRepository) CountByCategories(ctx context.Context, categoryIDs []int) (int, error) {
+	query := `SELECT COUNT(DISTINCT pc.product_id)
+		FROM product_category pc
+		JOIN products p ON p.id = pc.product_id
+		WHERE pc.category_id = ANY($1) AND p.status = 'active' AND p.deleted_at IS NULL`
+	var count int
+	if err := pr.db.QueryRowContext(ctx, query, pq.Array(categoryIDs)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error scanning product count: %v", err)
+	}
+	return count, nil
 }
 
 func (pr *ProductRepository) Update(ctx context.Context, id int, p UpdateProductRequest) error {
@@ -95,6 +138,46 @@ func (pr *ProductRepository) Update(ctx context.Context, id int, p UpdateProduct
 		args = append(args, p.Stock)
 		i++
 	}
+	if p.TaxCategory != nil {
+		fields = append(fields, fmt.Sprintf("tax_category = $%d", i))
+		args = append(args, p.TaxCategory)
+		i++
+	}
+	if p.WeightGrams != nil {
+		fields = append(fields, fmt.Sprintf("weight_grams = $%d", i))
+		args = append(args, p.WeightGrams)
+		i++
+	}
+	if p.LengthCm != nil {
+		fields = append(fields, fmt.Sprintf("length_cm = $%d", i))
+		args = append(args, p.LengthCm)
+		i++
+	}
+	if p.WidthCm != nil {
+		fields = append(fields, fmt.Sprintf("width_cm = $%d", i))
+		args = append(args, p.WidthCm)
+		i++
+	}
+	if p.HeightCm != nil {
+		fields = append(fields, fmt.Sprintf("height_cm = $%d", i))
+		args = append(args, p.HeightCm)
+		i++
+	}
+	if p.Status != nil {
+		fields = append(fields, fmt.Sprintf("status = $%d", i))
+		args = append(args, p.Status)
+		i++
+	}
+	if p.PublishAt != nil {
+		fields = append(fields, fmt.Sprintf("publish_at = $%d", i))
+		args = append(args, p.PublishAt)
+		i++
+	}
+	if p.UnpublishAt != nil {
+		fields = append(fields, fmt.Sprintf("unpublish_at = $%d", i))
+		args = append(args, p.UnpublishAt)
+		i++
+	}
 
 	// Siempre actualizamos updated_at
 	now := time.Now()
@@ -107,14 +190,35 @@ func (pr *ProductRepository) Update(ctx context.Context, id int, p UpdateProduct
 	}
 
 	// Query final
-	query := fmt.Sprintf("UPDATE products SET %s WHERE id = $%d",
+	query := fmt.Sprintf("UPDATE products SET %s WHERE id = $%d AND deleted_at IS NULL",
 		strings.Join(fields, ", "),
 		i, // placeholder para id
 	)
 
-	// Ejecutar
+	// Ejecutar, registrando el nuevo precio en el historial
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var previousStock int
+	if p.Stock != nil {
+		err = tx.QueryRowContext(ctx, "SELECT stock FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&previousStock)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("no product updated with id %d", id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	args = append(args, id)
-	res, err := pr.db.ExecContext(ctx, query, args...)
+	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -125,29 +229,629 @@ func (pr *ProductRepository) Update(ctx context.Context, id int, p UpdateProduct
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("no product updated with id %d", id)
+		err = fmt.Errorf("no product updated with id %d", id)
+		return err
+	}
+
+	if p.Stock != nil {
+		if err = recordStockLow(ctx, tx, pr.lowStock, int64(id), nil, previousStock, *p.Stock); err != nil {
+			return err
+		}
+	}
+
+	if p.Price != nil {
+		if _, err = tx.ExecContext(ctx, recordRegularPrice, id, p.Price); err != nil {
+			return fmt.Errorf("error recording the product price: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
 	return nil
 }
 
+// Delete soft-deletes the product, so the carts and orders that reference it keep their rows.
+// Deleted products are archived and left out of every query.
 func (pr *ProductRepository) Delete(ctx context.Context, id int) error {
-	query := "DELETE FROM products where id=$1"
-	_, err := pr.db.ExecContext(ctx, query, id)
+	query := "UPDATE products SET status = 'archived', deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
+	res, err := pr.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PublishScheduled activates the drafts whose publish_at has passed and archives the active
+// products whose unpublish_at has passed, clearing the applied dates. It returns how many products
+// were published and unpublished.
+func (pr *ProductRepository) PublishScheduled(ctx context.Context) (int64, int64, error) {
+	query := `UPDATE products SET status = 'active', publish_at = NULL, updated_at = NOW()
+		WHERE status = 'draft' AND publish_at <= NOW() AND deleted_at IS NULL`
+	res, err := pr.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error publishing products: %w", err)
+	}
+	published, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `UPDATE products SET status = 'archived', unpublish_at = NULL, updated_at = NOW()
+		WHERE status = 'active' AND unpublish_at <= NOW() AND deleted_at IS NULL`
+	res, err = pr.db.ExecContext(ctx, query)
+	if err != nil {
+		return published, 0, fmt.Errorf("error unpublishing products: %w", err)
+	}
+	unpublished, err := res.RowsAffected()
+	if err != nil {
+		return published, 0, err
+	}
+
+	return published, unpublished, nil
+}
+
+// FindOptions returns the options of the given products with their values, in position order.
+func (pr *ProductRepository) FindOptions(ctx context.Context, productIDs []int32) (map[int32][]Option, error) {
+	query := `SELECT ot.product_id, ot.id, ot.name, ov.id, ov.value
+		FROM product_option_types ot
+		JOIN product_option_values ov ON ov.option_type_id = ot.id
+		WHERE ot.product_id = ANY($1)
+		ORDER BY ot.product_id, ot.position, ot.id, ov.position, ov.id`
+	rows, err := pr.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	options := make(map[int32][]Option)
+	for rows.Next() {
+		var productID int32
+		var o Option
+		var v OptionValue
+		if err := rows.Scan(&productID, &o.ID, &o.Name, &v.ID, &v.Value); err != nil {
+			return nil, err
+		}
+
+		list := options[productID]
+		if n := len(list); n > 0 && list[n-1].ID == o.ID {
+			list[n-1].Values = append(list[n-1].Values, v)
+			continue
+		}
+		o.Values = []OptionValue{v}
+		options[productID] = append(list, o)
+	}
+
+	return options, rows.Err()
+}
+
+// FindVariants returns the variants of the given products with their option values.
+func (pr *ProductRepository) FindVariants(ctx context.Context, productIDs []int32) (map[int32][]Variant, error) {
+	query := `SELECT v.id, v.product_id, v.sku, v.barcode, v.price, COALESCE(v.price, vp.price), v.stock, v.created_at, v.updated_at, ot.name, ov.value
+		FROM product_variants v
+		JOIN LATERAL (SELECT ep.price FROM ` + pricedProducts + ` WHERE p.id = v.product_id) vp ON TRUE
+		LEFT JOIN product_variant_options vo ON vo.variant_id = v.id
+		LEFT JOIN product_option_values ov ON ov.id = vo.option_value_id
+		LEFT JOIN product_option_types ot ON ot.id = ov.option_type_id
+		WHERE v.product_id = ANY($1)
+		ORDER BY v.product_id, v.id`
+	rows, err := pr.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	variants := make(map[int32][]Variant)
+	for rows.Next() {
+		var v Variant
+		var name, value sql.NullString
+		if err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &v.Barcode, &v.PriceOverride, &v.Price, &v.Stock, &v.CreatedAt, &v.UpdatedAt, &name, &value); err != nil {
+			return nil, err
+		}
+
+		list := variants[v.ProductID]
+		if n := len(list); n > 0 && list[n-1].ID == v.ID {
+			if name.Valid {
+				list[n-1].Options[name.String] = value.String
+			}
+			continue
+		}
+		v.Options = map[string]string{}
+		if name.Valid {
+			v.Options[name.String] = value.String
+		}
+		variants[v.ProductID] = append(list, v)
+	}
+
+	return variants, rows.Err()
+}
+
+// CreateOption adds an option type with its values to the product.
+func (pr *ProductRepository) CreateOption(ctx context.Context, productID int, data CreateOptionRequest) (*Option, error) {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	option := &Option{Name: data.Name}
+	query := `INSERT INTO product_option_types (product_id, name, position)
+		VALUES ($1, $2, (SELECT COUNT(*) FROM product_option_types WHERE product_id = $1))
+		RETURNING id`
+	if err = tx.QueryRowContext(ctx, query, productID, data.Name).Scan(&option.ID); err != nil {
+		return nil, fmt.Errorf("error inserting option: %w", err)
+	}
+
+	for i, value := range data.Values {
+		v := OptionValue{Value: value}
+		query := "INSERT INTO product_option_values (option_type_id, value, position) VALUES ($1, $2, $3) RETURNING id"
+		if err = tx.QueryRowContext(ctx, query, option.ID, value, i).Scan(&v.ID); err != nil {
+			return nil, fmt.Errorf("error inserting option value %q: %w", value, err)
+		}
+		option.Values = append(option.Values, v)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return option, nil
+}
+
+func (pr *ProductRepository) DeleteOption(ctx context.Context, productID, optionID int) error {
+	query := "DELETE FROM product_option_types WHERE id = $1 AND product_id = $2"
+	res, err := pr.db.ExecContext(ctx, query, optionID, productID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CreateVariant inserts the variant and links it to its option values.
+func (pr *ProductRepository) CreateVariant(ctx context.Context, productID int, data CreateVariantRequest, optionValueIDs []int) (int64, error) {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int64
+	query := "INSERT INTO product_variants (product_id, sku, barcode, price, stock) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	if err = tx.QueryRowContext(ctx, query, productID, data.SKU, data.Barcode, data.PriceOverride, data.Stock).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error inserting variant: %w", err)
+	}
+
+	for _, valueID := range optionValueIDs {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO product_variant_options (variant_id, option_value_id) VALUES ($1, $2)", id, valueID); err != nil {
+			return 0, fmt.Errorf("error linking variant option: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+func (pr *ProductRepository) UpdateVariant(ctx context.Context, productID int, variantID int64, v UpdateVariantRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	if v.SKU != nil {
+		fields = append(fields, fmt.Sprintf("sku = $%d", i))
+		args = append(args, v.SKU)
+		i++
+	}
+	if v.Barcode != nil {
+		fields = append(fields, fmt.Sprintf("barcode = $%d", i))
+		args = append(args, v.Barcode)
+		i++
+	}
+	if v.PriceOverride != nil {
+		fields = append(fields, fmt.Sprintf("price = $%d", i))
+		args = append(args, v.PriceOverride)
+		i++
+	}
+	if v.Stock != nil {
+		fields = append(fields, fmt.Sprintf("stock = $%d", i))
+		args = append(args, v.Stock)
+		i++
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	query := fmt.Sprintf("UPDATE product_variants SET %s WHERE id = $%d AND product_id = $%d", strings.Join(fields, ", "), i, i+1)
+	args = append(args, variantID, productID)
+
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var previousStock int
+	if err = tx.QueryRowContext(ctx, "SELECT stock FROM product_variants WHERE id = $1 AND product_id = $2 FOR UPDATE", variantID, productID).Scan(&previousStock); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	if v.Stock != nil {
+		if err = recordStockLow(ctx, tx, pr.lowStock, int64(productID), &variantID, previousStock, *v.Stock); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (pr *ProductRepository) DeleteVariant(ctx context.Context, productID int, variantID int64) error {
+	query := "DELETE FROM product_variants WHERE id = $1 AND product_id = $2"
+	res, err := pr.db.ExecContext(ctx, query, variantID, productID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
 
-func (pr *ProductRepository) Count(ctx context.Context) (int, error) {
-	query := "SELECT COUNT(*) FROM products"
-	row := pr.db.QueryRowContext(ctx, query)
+// FindCategories returns the categories the given products are assigned to, ordered by name.
+func (pr *ProductRepository) FindCategories(ctx context.Context, productIDs []int32) (map[int32][]Category, error) {
+	query := `SELECT pc.product_id, c.id, c.name
+		FROM product_category pc
+		JOIN categories c ON c.id = pc.category_id
+		WHERE pc.product_id = ANY($1)
+		ORDER BY pc.product_id, c.name`
+	rows, err := pr.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	categories := make(map[int32][]Category)
+	for rows.Next() {
+		var productID int32
+		var c Category
+		if err := rows.Scan(&productID, &c.ID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories[productID] = append(categories[productID], c)
+	}
+
+	return categories, rows.Err()
+}
+
+// FindImages returns the images of the given products in display order. The URL fields hold the
+// storage keys of the files.
+func (pr *ProductRepository) FindImages(ctx context.Context, productIDs []int32) (map[int32][]Image, error) {
+	query := `SELECT product_id, id, original_key, medium_key, thumbnail_key, alt_text
+		FROM product_images
+		WHERE product_id = ANY($1)
+		ORDER BY product_id, position, id`
+	rows, err := pr.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	images := make(map[int32][]Image)
+	for rows.Next() {
+		var productID int32
+		var img Image
+		if err := rows.Scan(&productID, &img.ID, &img.URL, &img.MediumURL, &img.ThumbnailURL, &img.AltText); err != nil {
+			return nil, err
+		}
+		images[productID] = append(images[productID], img)
+	}
+
+	return images, rows.Err()
+}
 
+// CountCategories returns how many of the given category IDs exist.
+func (pr *ProductRepository) CountCategories(ctx context.Context, categoryIDs []int) (int, error) {
+	query := "SELECT COUNT(*) FROM categories WHERE id = ANY($1)"
 	var count int
-	if err := row.Scan(&count); err != nil {
-		return 0, fmt.Errorf("error scanning product count: %v", err)
+	if err := pr.db.QueryRowContext(ctx, query, pq.Array(categoryIDs)).Scan(&count); err != nil {
+		return 0, err
 	}
 	return count, nil
 }
+
+// SetCategories replaces the categories of the product.
+func (pr *ProductRepository) SetCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_category WHERE product_id = $1", productID); err != nil {
+		return fmt.Errorf("error removing product categories: %w", err)
+	}
+
+	query := "INSERT INTO product_category (product_id, category_id) SELECT $1, UNNEST($2::INT[]) ON CONFLICT (product_id, category_id) DO NOTHING"
+	if _, err = tx.ExecContext(ctx, query, productID, pq.Array(categoryIDs)); err != nil {
+		return fmt.Errorf("error assigning product categories: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// importOutcome is the result of an imported row: created or updated, or skipped with one of the
+// errors of importErrorFields.
+type importOutcome struct {
+	created bool
+	err     error
+}
+
+// ImportBatch saves the rows in one transaction, each behind a savepoint so a failing row is
+// skipped without losing the others. On a dry run the transaction is rolled back.
+func (pr *ProductRepository) ImportBatch(ctx context.Context, rows []CatalogRow, dryRun bool) ([]importOutcome, error) {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	outcomes := make([]importOutcome, len(rows))
+	for i, row := range rows {
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %w", err)
+		}
+
+		var created bool
+		var rowErr error
+		if row.SKU != "" {
+			rowErr = importVariant(ctx, tx, row, pr.lowStock)
+		} else {
+			created, rowErr = importProduct(ctx, tx, row, pr.lowStock)
+		}
+
+		if isImportRowError(rowErr) {
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, fmt.Errorf("error rolling back row: %w", err)
+			}
+			outcomes[i].err = rowErr
+			continue
+		}
+		if rowErr != nil {
+			err = rowErr
+			return nil, fmt.Errorf("error importing the row on line %d: %w", row.Line, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("error releasing savepoint: %w", err)
+		}
+		outcomes[i].created = created
+	}
+
+	if dryRun {
+		if err = tx.Rollback(); err != nil {
+			return nil, fmt.Errorf("error rolling back dry run: %w", err)
+		}
+		return outcomes, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return outcomes, nil
+}
+
+func isImportRowError(err error) bool {
+	for rowErr := range importErrorFields {
+		if errors.Is(err, rowErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// importProduct creates the product with the name of the row, or updates the fields of the row.
+func importProduct(ctx context.Context, tx *sql.Tx, row CatalogRow, lowStock int) (bool, error) {
+	var id, previousStock int
+	err := tx.QueryRowContext(ctx, "SELECT id, stock FROM products WHERE name = $1 AND deleted_at IS NULL FOR UPDATE", row.Name).Scan(&id, &previousStock)
+	if errors.Is(err, sql.ErrNoRows) {
+		if row.Price == nil {
+			return false, ErrPriceRequired
+		}
+
+		query := `INSERT INTO products (name, price, description, stock, tax_category, weight_grams, length_cm, width_cm, height_cm, status)
+			VALUES ($1, $2, COALESCE($3, ''), COALESCE($4, 0), COALESCE(NULLIF($5, ''), 'standard'), COALESCE($6, 0), COALESCE($7, 0), COALESCE($8, 0), COALESCE($9, 0), COALESCE($10, 'active'))
+			RETURNING id`
+		if err = tx.QueryRowContext(ctx, query, row.Name, row.Price, row.Description, row.Stock, row.TaxCategory, row.WeightGrams, row.LengthCm, row.WidthCm, row.HeightCm, row.Status).Scan(&id); err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, recordRegularPrice, id, row.Price)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE products SET
+			price = COALESCE($2, price),
+			description = COALESCE($3, description),
+			stock = COALESCE($4, stock),
+			tax_category = COALESCE(NULLIF($5, ''), tax_category),
+			weight_grams = COALESCE($6, weight_grams),
+			length_cm = COALESCE($7, length_cm),
+			width_cm = COALESCE($8, width_cm),
+			height_cm = COALESCE($9, height_cm),
+			status = COALESCE($10, status),
+			updated_at = NOW()
+		WHERE id = $1`
+	if _, err = tx.ExecContext(ctx, query, id, row.Price, row.Description, row.Stock, row.TaxCategory, row.WeightGrams, row.LengthCm, row.WidthCm, row.HeightCm, row.Status); err != nil {
+		return false, err
+	}
+	if row.Stock != nil {
+		if err = recordStockLow(ctx, tx, lowStock, int64(id), nil, previousStock, *row.Stock); err != nil {
+			return false, err
+		}
+	}
+	if row.Price != nil {
+		_, err = tx.ExecContext(ctx, recordRegularPrice, id, row.Price)
+	}
+	return false, err
+}
+
+// importVariant updates the price override, stock and barcode of the variant with the SKU of the
+// row. Variants are not created by imports, as they need option values.
+func importVariant(ctx context.Context, tx *sql.Tx, row CatalogRow, lowStock int) error {
+	var id, productID int64
+	var productName string
+	var previousStock int
+	query := "SELECT v.id, p.id, p.name, v.stock FROM product_variants v JOIN products p ON p.id = v.product_id WHERE v.sku = $1 AND p.deleted_at IS NULL FOR UPDATE OF v"
+	err := tx.QueryRowContext(ctx, query, row.SKU).Scan(&id, &productID, &productName, &previousStock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownSKU
+	}
+	if err != nil {
+		return err
+	}
+	if row.Name != "" && row.Name != productName {
+		return ErrSKUOfOtherProduct
+	}
+
+	query = `UPDATE product_variants SET
+			price = COALESCE($2, price),
+			stock = COALESCE($3, stock),
+			barcode = COALESCE($4, barcode),
+			updated_at = NOW()
+		WHERE id = $1`
+	_, err = tx.ExecContext(ctx, query, id, row.Price, row.Stock, row.Barcode)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return ErrBarcodeInUse
+	}
+	if err != nil || row.Stock == nil {
+		return err
+	}
+	return recordStockLow(ctx, tx, lowStock, productID, &id, previousStock, *row.Stock)
+}
+
+// Export calls fn with every product followed by its variants, in the order of their IDs. Product
+// rows leave the variant fields empty and carry the regular price, leaving out running sales;
+// variant rows carry only the SKU, price override, stock and barcode. An export can be imported
+// back as is.
+func (pr *ProductRepository) Export(ctx context.Context, fn func(*CatalogRow) error) error {
+	query := `SELECT name, sku, description, price, stock, tax_category, weight_grams, length_cm, width_cm, height_cm, barcode, status
+		FROM (
+			SELECT p.id AS product_id, 0::BIGINT AS variant_id, p.name, NULL::VARCHAR AS sku, p.description,
+				COALESCE(ep.regular_price, ep.price) AS price, p.stock, p.tax_category, p.weight_grams, p.length_cm, p.width_cm, p.height_cm,
+				NULL::VARCHAR AS barcode, p.status
+			FROM ` + pricedProducts + `
+			WHERE p.deleted_at IS NULL
+			UNION ALL
+			SELECT p.id, v.id, p.name, v.sku, NULL, v.price, v.stock, NULL, NULL, NULL, NULL, NULL, v.barcode, NULL
+			FROM product_variants v
+			JOIN products p ON p.id = v.product_id
+			WHERE p.deleted_at IS NULL
+		) catalog
+		ORDER BY product_id, variant_id`
+	rows, err := pr.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	for rows.Next() {
+		var row CatalogRow
+		var sku *string
+		if err := rows.Scan(&row.Name, &sku, &row.Description, &row.Price, &row.Stock, &row.TaxCategory, &row.WeightGrams, &row.LengthCm, &row.WidthCm, &row.HeightCm, &row.Barcode, &row.Status); err != nil {
+			return err
+		}
+		if sku != nil {
+			row.SKU = *sku
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -1,16 +1,39 @@
 package products
 
-import "github.com/go-chi/chi/v5"
+import (
+	"ecommerce-service/internal/auth"
 
-func RegisterRoutes(r chi.Router, ph *ProductHandler) {
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, ph *ProductHandler, am *auth.AuthMiddleware) {
 	r.Route("/products", func(r chi.Router) {
-		r.Get("/", ph.FindAll)
-		r.Post("/", ph.Create)
+		r.With(am.OptionalToken, am.IdentifyAdmin).Get("/", ph.FindAll)
+		r.With(am.VerifyToken, am.RequireAdmin).Post("/", ph.Create)
+		r.With(am.VerifyToken, am.RequireAdmin).Post("/import", ph.Import)
+		r.With(am.VerifyToken, am.RequireAdmin).Get("/export", ph.Export)
 
 		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", ph.FindByID)
-			r.Patch("/", ph.Update)
-			r.Delete("/", ph.Delete)
+			r.With(am.OptionalToken, am.IdentifyAdmin).Get("/", ph.FindByID)
+			r.With(am.OptionalToken, am.IdentifyAdmin).Get("/price-history", ph.PriceHistory)
+
+			r.Group(func(r chi.Router) {
+				r.Use(am.VerifyToken, am.RequireAdmin)
+
+				r.Patch("/", ph.Update)
+				r.Delete("/", ph.Delete)
+
+				r.Put("/categories", ph.SetCategories)
+
+				r.Post("/prices", ph.SchedulePrice)
+				r.Delete("/prices/{priceID}", ph.CancelPrice)
+
+				r.Post("/options", ph.CreateOption)
+				r.Delete("/options/{optionID}", ph.DeleteOption)
+				r.Post("/variants", ph.CreateVariant)
+				r.Patch("/variants/{variantID}", ph.UpdateVariant)
+				r.Delete("/variants/{variantID}", ph.DeleteVariant)
+			})
 		})
 	})
 }
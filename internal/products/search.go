@@ -0,0 +1,302 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ecommerce-service/internal/utils"
+)
+
+// priceBucketBounds are the upper bounds of the price facet buckets; the last bucket is open-ended.
+var priceBucketBounds = []float64{25, 50, 100, 250, 500}
+
+// sortColumns maps the sort parameter to its ORDER BY clause; a leading "-" sorts descending.
+var sortColumns = map[string]string{
+	"price":       "ep.price ASC",
+	"-price":      "ep.price DESC",
+	"created_at":  "p.created_at ASC",
+	"-created_at": "p.created_at DESC",
+	"name":        "p.name ASC",
+	"-name":       "p.name DESC",
+}
+
+// searchFilter builds the WHERE clause of a catalog search with numbered placeholders.
+type searchFilter struct {
+	conds []string
+	args  []any
+}
+
+// add appends a condition whose "?" placeholder is bound to arg.
+func (f *searchFilter) add(cond string, arg any) {
+	f.conds = append(f.conds, strings.ReplaceAll(cond, "?", f.bind(arg)))
+}
+
+// bind adds an argument and returns its placeholder.
+func (f *searchFilter) bind(arg any) string {
+	f.args = append(f.args, arg)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *searchFilter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+const (
+	withoutCategory = "category"
+	withoutPrice    = "price"
+)
+
+// newSearchFilter translates the search parameters, leaving out the filter named by skip.
+func newSearchFilter(p SearchParams, skip string) *searchFilter {
+	f := &searchFilter{}
+
+	if p.Query != "" {
+		f.add("p.search_vector @@ websearch_to_tsquery('english', ?)", p.Query)
+	}
+	if skip != withoutPrice {
+		if p.MinPrice != nil {
+			f.add("ep.price >= ?", *p.MinPrice)
+		}
+		if p.MaxPrice != nil {
+			f.add("ep.price <= ?", *p.MaxPrice)
+		}
+	}
+	if skip != withoutCategory && p.CategoryID != nil {
+		f.add(`p.id IN (
+			WITH RECURSIVE descendants AS (
+				SELECT id FROM categories WHERE id = ?
+				UNION ALL
+				SELECT c.id FROM categories c JOIN descendants d ON c.parent_id = d.id
+			)
+			SELECT pc.product_id FROM product_category pc JOIN descendants d ON d.id = pc.category_id
+		)`, *p.CategoryID)
+	}
+	if p.InStock {
+		f.conds = append(f.conds, "(p.stock > 0 OR EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.stock > 0))")
+	}
+	if p.Status != "" {
+		f.add("p.status = ?", p.Status)
+	}
+	f.conds = append(f.conds, "p.deleted_at IS NULL")
+
+	return f
+}
+
+// relevance ranks the products against the full-text query, which is always the first argument.
+const relevance = "ts_rank(p.search_vector, websearch_to_tsquery('english', $1))"
+
+// sortRelevance is the implicit sort of full-text searches without an explicit sort.
+const sortRelevance = "relevance"
+
+// effectiveSort returns the sort applied to the search.
+func effectiveSort(p SearchParams) string {
+	if p.Sort == "" && p.Query != "" {
+		return sortRelevance
+	}
+	return p.Sort
+}
+
+// searchKeyset returns the keyset of the sort of the search. Sorting by relevance reads the ties
+// by descending ID, as the keyset columns share a direction.
+func searchKeyset(p SearchParams) utils.Keyset {
+	sort := effectiveSort(p)
+	desc := strings.HasPrefix(sort, "-")
+	switch strings.TrimPrefix(sort, "-") {
+	case "price":
+		return utils.Keyset{Columns: []string{"ep.price", "p.id"}, Desc: desc}
+	case "created_at":
+		return utils.Keyset{Columns: []string{"p.created_at", "p.id"}, Desc: desc}
+	case "name":
+		return utils.Keyset{Columns: []string{"p.name", "p.id"}, Desc: desc}
+	case sortRelevance:
+		return utils.Keyset{Columns: []string{relevance, "p.id"}, Desc: true}
+	}
+	return utils.Keyset{Columns: []string{"p.id"}}
+}
+
+// searchKeys returns the keyset values of a product for the sort of the search.
+func searchKeys(p SearchParams, product Product) []string {
+	switch strings.TrimPrefix(effectiveSort(p), "-") {
+	case "price":
+		return []string{utils.FormatCursorFloat(product.Price, 64)}
+	case "created_at":
+		var createdAt time.Time
+		if product.CreatedAt != nil {
+			createdAt = *product.CreatedAt
+		}
+		return []string{utils.FormatCursorTime(createdAt)}
+	case "name":
+		return []string{product.Name}
+	case sortRelevance:
+		return []string{utils.FormatCursorFloat(product.rank, 32)}
+	}
+	return nil
+}
+
+func searchColumns(p SearchParams) string {
+	rank := "0"
+	if p.Query != "" {
+		rank = relevance
+	}
+	return productColumns + ", " + rank
+}
+
+// Search returns a page of the products matching the search. Without a sort, full-text searches are
+// ordered by relevance and the rest by ID.
+func (pr *ProductRepository) Search(ctx context.Context, p SearchParams, limit, offset int) ([]Product, error) {
+	f := newSearchFilter(p, "")
+
+	order := "p.id"
+	if clause, ok := sortColumns[p.Sort]; ok {
+		order = clause + ", p.id"
+	} else if p.Query != "" {
+		order = relevance + " DESC, p.id"
+	}
+
+	query := "SELECT " + searchColumns(p) + " FROM " + pricedProducts + f.where() + " ORDER BY " + order
+	query += " LIMIT " + f.bind(limit) + " OFFSET " + f.bind(offset)
+	return pr.querySearch(ctx, query, f.args...)
+}
+
+// SearchPage returns up to limit products matching the search past the cursor, in the direction of the cursor.
+func (pr *ProductRepository) SearchPage(ctx context.Context, p SearchParams, c *utils.Cursor, limit int) ([]Product, error) {
+	f := newSearchFilter(p, "")
+
+	cond, order, err := searchKeyset(p).Clause(c, f.bind)
+	if err != nil {
+		return nil, err
+	}
+	if cond != "" {
+		f.conds = append(f.conds, cond)
+	}
+
+	query := "SELECT " + searchColumns(p) + " FROM " + pricedProducts + f.where() + " ORDER BY " + order + " LIMIT " + f.bind(limit)
+	return pr.querySearch(ctx, query, f.args...)
+}
+
+func (pr *ProductRepository) querySearch(ctx context.Context, query string, args ...any) ([]Product, error) {
+	rows, err := pr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var products []Product
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(append(productDest(&product), &product.rank)...); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, rows.Err()
+}
+
+func (pr *ProductRepository) CountSearch(ctx context.Context, p SearchParams) (int, error) {
+	f := newSearchFilter(p, "")
+	query := "SELECT COUNT(*) FROM " + pricedProducts + f.where()
+
+	var count int
+	if err := pr.db.QueryRowContext(ctx, query, f.args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error scanning product count: %v", err)
+	}
+	return count, nil
+}
+
+// FindFacets counts the products matching the search per category and per price bucket.
+func (pr *ProductRepository) FindFacets(ctx context.Context, p SearchParams) (*Facets, error) {
+	categories, err := pr.findCategoryFacets(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := pr.findPriceBuckets(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Facets{Categories: categories, PriceBuckets: buckets}, nil
+}
+
+func (pr *ProductRepository) findCategoryFacets(ctx context.Context, p SearchParams) ([]CategoryFacet, error) {
+	f := newSearchFilter(p, withoutCategory)
+	query := `SELECT c.id, c.name, COUNT(*)
+		FROM ` + pricedProducts + `
+		JOIN product_category pc ON pc.product_id = p.id
+		JOIN categories c ON c.id = pc.category_id` + f.where() + `
+		GROUP BY c.id, c.name
+		ORDER BY COUNT(*) DESC, c.name`
+	rows, err := pr.db.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	facets := []CategoryFacet{}
+	for rows.Next() {
+		var facet CategoryFacet
+		if err := rows.Scan(&facet.ID, &facet.Name, &facet.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+
+	return facets, rows.Err()
+}
+
+func (pr *ProductRepository) findPriceBuckets(ctx context.Context, p SearchParams) ([]PriceBucket, error) {
+	f := newSearchFilter(p, withoutPrice)
+	bounds := make([]string, len(priceBucketBounds))
+	for i, bound := range priceBucketBounds {
+		bounds[i] = fmt.Sprintf("%g", bound)
+	}
+
+	// width_bucket returns 0 below the first bound and len(bounds) at or above the last one
+	query := "SELECT width_bucket(ep.price, ARRAY[" + strings.Join(bounds, ", ") + "]::NUMERIC[]), COUNT(*) FROM " + pricedProducts +
+		f.where() + " GROUP BY 1"
+	rows, err := pr.db.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	buckets := make([]PriceBucket, len(priceBucketBounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].Min = priceBucketBounds[i-1]
+		}
+		if i < len(priceBucketBounds) {
+			bound := priceBucketBounds[i]
+			buckets[i].Max = &bound
+		}
+	}
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		buckets[bucket].Count = count
+	}
+
+	return buckets, rows.Err()
+}
@@ -2,27 +2,83 @@ package products
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"maps"
+	"slices"
+	"time"
 
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/utils"
 )
 
 type Repository interface {
 	Create(ctx context.Context, data CreateProductRequest) error
 	FindByID(ctx context.Context, id int) (*Product, error)
-	FindAll(ctx context.Context, limit, offset int) ([]Product, error)
+	Search(ctx context.Context, params SearchParams, limit, offset int) ([]Product, error)
+	SearchPage(ctx context.Context, params SearchParams, c *utils.Cursor, limit int) ([]Product, error)
+	CountSearch(ctx context.Context, params SearchParams) (int, error)
+	FindFacets(ctx context.Context, params SearchParams) (*Facets, error)
 	Update(ctx context.Context, id int, data UpdateProductRequest) error
 	Delete(ctx context.Context, id int) error
-	Count(ctx context.Context) (int, error)
+	FindOptions(ctx context.Context, productIDs []int32) (map[int32][]Option, error)
+	FindVariants(ctx context.Context, productIDs []int32) (map[int32][]Variant, error)
+	CreateOption(ctx context.Context, productID int, data CreateOptionRequest) (*Option, error)
+	DeleteOption(ctx context.Context, productID, optionID int) error
+	CreateVariant(ctx context.Context, productID int, data CreateVariantRequest, optionValueIDs []int) (int64, error)
+	UpdateVariant(ctx context.Context, productID int, variantID int64, data UpdateVariantRequest) error
+	DeleteVariant(ctx context.Context, productID int, variantID int64) error
+	FindAllByCategories(ctx context.Context, categoryIDs []int, limit, offset int) ([]Product, error)
+	CountByCategories(ctx context.Context, categoryIDs []int) (int, error)
+	FindCategories(ctx context.Context, productIDs []int32) (map[int32][]Category, error)
+	FindImages(ctx context.Context, productIDs []int32) (map[int32][]Image, error)
+	CountCategories(ctx context.Context, categoryIDs []int) (int, error)
+	SetCategories(ctx context.Context, productID int, categoryIDs []int) error
+	ImportBatch(ctx context.Context, rows []CatalogRow, dryRun bool) ([]importOutcome, error)
+	Export(ctx context.Context, fn func(*CatalogRow) error) error
+	PublishScheduled(ctx context.Context) (int64, int64, error)
+	CreatePrice(ctx context.Context, productID int, data SchedulePriceRequest) (*Price, error)
+	CancelPrice(ctx context.Context, productID int, id int64) error
+	FindPriceHistory(ctx context.Context, productID int, scheduled bool) ([]Price, error)
+}
+
+var (
+	ErrProductHasVariants    = errors.New("the options of a product cannot change while it has variants")
+	ErrInvalidVariantOptions = errors.New("the variant must have exactly one existing value for every option of the product")
+	ErrDuplicateVariant      = errors.New("a variant with the same options already exists")
+	ErrUnknownCategory       = errors.New("one or more categories do not exist")
+
+	// Errors of a single imported row, which is skipped.
+	ErrUnknownSKU        = errors.New("no variant has this SKU")
+	ErrSKUOfOtherProduct = errors.New("the SKU belongs to a product with another name")
+	ErrPriceRequired     = errors.New("a price is required to create a product")
+	ErrBarcodeInUse      = errors.New("the barcode is used by another variant")
+)
+
+// importErrorFields are the fields the errors of an imported row are reported under.
+var importErrorFields = map[error]string{
+	ErrUnknownSKU:        "sku",
+	ErrSKUOfOtherProduct: "name",
+	ErrPriceRequired:     "price",
+	ErrBarcodeInUse:      "barcode",
+}
+
+// ImageURLs resolves the storage keys of the product images to public URLs.
+type ImageURLs interface {
+	URL(key string) string
 }
 
 type ProductService struct {
 	productRepo Repository
+	imageURLs   ImageURLs
 	config      *config.Config
 }
 
-func NewProductService(productRepo Repository, c *config.Config) *ProductService {
-	return &ProductService{productRepo: productRepo, config: c}
+func NewProductService(productRepo Repository, imageURLs ImageURLs, c *config.Config) *ProductService {
+	return &ProductService{productRepo: productRepo, imageURLs: imageURLs, config: c}
 }
 
 func (ps *ProductService) Create(ctx context.Context, p *CreateProductRequest) error {
@@ -35,12 +91,90 @@ func (ps *ProductService) Create(ctx context.Context, p *CreateProductRequest) e
 }
 
 func (ps *ProductService) FindByID(ctx context.Context, id int) (*Product, error) {
-	return ps.productRepo.FindByID(ctx, id)
+	product, err := ps.productRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []Product{*product}
+	if err := ps.loadDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
+}
+
+// FindAll returns a page of the products matching the search parameters.
+func (ps *ProductService) FindAll(ctx context.Context, params SearchParams, limit, page int) ([]Product, error) {
+	offset := (page - 1) * limit
+	products, err := ps.productRepo.Search(ctx, params, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ps.loadDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return products, nil
 }
 
-func (ps *ProductService) FindAll(ctx context.Context, limit, page int) ([]Product, error) {
+// FindPage returns a keyset-paginated page of the products matching the search parameters with
+// the cursors of the next and previous pages.
+func (ps *ProductService) FindPage(ctx context.Context, params SearchParams, c *utils.Cursor, limit int) ([]Product, *string, *string, error) {
+	products, err := ps.productRepo.SearchPage(ctx, params, c, limit+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	products, next, prev := utils.CursorPage(products, limit, c, effectiveSort(params), func(p Product) ([]string, int64) {
+		return searchKeys(params, p), int64(p.ID)
+	})
+
+	if err := ps.loadDetails(ctx, products); err != nil {
+		return nil, nil, nil, err
+	}
+	return products, next, prev, nil
+}
+
+// FindAllByCategories lists the products assigned to any of the categories.
+func (ps *ProductService) FindAllByCategories(ctx context.Context, categoryIDs []int, limit, page int) ([]Product, error) {
 	offset := (page - 1) * limit
-	return ps.productRepo.FindAll(ctx, limit, offset)
+	products, err := ps.productRepo.FindAllByCategories(ctx, categoryIDs, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ps.loadDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (ps *ProductService) CountByCategories(ctx context.Context, categoryIDs []int) (int, error) {
+	return ps.productRepo.CountByCategories(ctx, categoryIDs)
+}
+
+// SetCategories replaces the categories of the product and returns the updated product.
+func (ps *ProductService) SetCategories(ctx context.Context, id int, categoryIDs []int) (*Product, error) {
+	if _, err := ps.productRepo.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	categoryIDs = slices.Compact(slices.Sorted(slices.Values(categoryIDs)))
+	if len(categoryIDs) > 0 {
+		found, err := ps.productRepo.CountCategories(ctx, categoryIDs)
+		if err != nil {
+			return nil, err
+		}
+		if found != len(categoryIDs) {
+			return nil, ErrUnknownCategory
+		}
+	}
+
+	if err := ps.productRepo.SetCategories(ctx, id, categoryIDs); err != nil {
+		return nil, err
+	}
+
+	return ps.FindByID(ctx, id)
 }
 
 func (ps *ProductService) Update(ctx context.Context, id int, p UpdateProductRequest) error {
@@ -51,6 +185,316 @@ func (ps *ProductService) Delete(ctx context.Context, id int) error {
 	return ps.productRepo.Delete(ctx, id)
 }
 
-func (ps *ProductService) Count(ctx context.Context) (int, error) {
-	return ps.productRepo.Count(ctx)
+func (ps *ProductService) Count(ctx context.Context, params SearchParams) (int, error) {
+	return ps.productRepo.CountSearch(ctx, params)
+}
+
+func (ps *ProductService) Facets(ctx context.Context, params SearchParams) (*Facets, error) {
+	return ps.productRepo.FindFacets(ctx, params)
+}
+
+// CreateOption adds an option type to a product that has no variants yet.
+func (ps *ProductService) CreateOption(ctx context.Context, productID int, data *CreateOptionRequest) (*Option, error) {
+	if err := ps.checkNoVariants(ctx, productID); err != nil {
+		return nil, err
+	}
+	return ps.productRepo.CreateOption(ctx, productID, *data)
+}
+
+// DeleteOption removes an option type from a product that has no variants.
+func (ps *ProductService) DeleteOption(ctx context.Context, productID, optionID int) error {
+	if err := ps.checkNoVariants(ctx, productID); err != nil {
+		return err
+	}
+	return ps.productRepo.DeleteOption(ctx, productID, optionID)
+}
+
+// CreateVariant adds a variant for a combination of option values not used by any other variant.
+func (ps *ProductService) CreateVariant(ctx context.Context, productID int, data *CreateVariantRequest) (*Variant, error) {
+	product, err := ps.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Options) != len(product.Options) {
+		return nil, ErrInvalidVariantOptions
+	}
+
+	valueIDs := make([]int, 0, len(product.Options))
+	for _, o := range product.Options {
+		value, ok := data.Options[o.Name]
+		if !ok {
+			return nil, ErrInvalidVariantOptions
+		}
+
+		valueID := 0
+		for _, v := range o.Values {
+			if v.Value == value {
+				valueID = v.ID
+				break
+			}
+		}
+		if valueID == 0 {
+			return nil, ErrInvalidVariantOptions
+		}
+		valueIDs = append(valueIDs, valueID)
+	}
+
+	for _, v := range product.Variants {
+		if maps.Equal(v.Options, data.Options) {
+			return nil, ErrDuplicateVariant
+		}
+	}
+
+	id, err := ps.productRepo.CreateVariant(ctx, productID, *data, valueIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.findVariant(ctx, productID, id)
+}
+
+func (ps *ProductService) UpdateVariant(ctx context.Context, productID int, variantID int64, data UpdateVariantRequest) (*Variant, error) {
+	if err := ps.productRepo.UpdateVariant(ctx, productID, variantID, data); err != nil {
+		return nil, err
+	}
+	return ps.findVariant(ctx, productID, variantID)
+}
+
+func (ps *ProductService) DeleteVariant(ctx context.Context, productID int, variantID int64) error {
+	return ps.productRepo.DeleteVariant(ctx, productID, variantID)
+}
+
+func (ps *ProductService) findVariant(ctx context.Context, productID int, variantID int64) (*Variant, error) {
+	variants, err := ps.productRepo.FindVariants(ctx, []int32{int32(productID)})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range variants[int32(productID)] {
+		if v.ID == variantID {
+			return &v, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (ps *ProductService) checkNoVariants(ctx context.Context, productID int) error {
+	if _, err := ps.productRepo.FindByID(ctx, productID); err != nil {
+		return err
+	}
+
+	variants, err := ps.productRepo.FindVariants(ctx, []int32{int32(productID)})
+	if err != nil {
+		return err
+	}
+	if len(variants[int32(productID)]) > 0 {
+		return ErrProductHasVariants
+	}
+	return nil
+}
+
+// loadDetails attaches the categories, the images, the options and the variant matrix to the products.
+func (ps *ProductService) loadDetails(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+
+	categories, err := ps.productRepo.FindCategories(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	images, err := ps.productRepo.FindImages(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	options, err := ps.productRepo.FindOptions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	variants, err := ps.productRepo.FindVariants(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Categories = categories[products[i].ID]
+		if products[i].Categories == nil {
+			products[i].Categories = []Category{}
+		}
+		products[i].Images = images[products[i].ID]
+		if products[i].Images == nil {
+			products[i].Images = []Image{}
+		}
+		for j := range products[i].Images {
+			img := &products[i].Images[j]
+			img.URL = ps.imageURLs.URL(img.URL)
+			img.MediumURL = ps.imageURLs.URL(img.MediumURL)
+			img.ThumbnailURL = ps.imageURLs.URL(img.ThumbnailURL)
+		}
+		products[i].Options = options[products[i].ID]
+		products[i].Variants = variants[products[i].ID]
+	}
+	return nil
+}
+
+// Import creates and updates products and variants from the rows, in batches of ImportBatchSize
+// rows that are each saved in one transaction. Rows with errors are skipped and listed in the
+// report. On a dry run every batch is rolled back.
+//
+// A row may not repeat the name or SKU of an earlier row, so a dry run reports the same as a real
+// import even though the batches do not see each other.
+func (ps *ProductService) Import(ctx context.Context, rows *RowReader, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	fail := func(line int, errs map[string]string) {
+		report.Failed++
+		report.Errors = append(report.Errors, ImportError{Line: line, Errors: errs})
+	}
+
+	batchSize := max(ps.config.ImportBatchSize, 1)
+	batch := make([]CatalogRow, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		outcomes, err := ps.productRepo.ImportBatch(ctx, batch, dryRun)
+		if err != nil {
+			return err
+		}
+		for i, outcome := range outcomes {
+			switch {
+			case outcome.err != nil:
+				fail(batch[i].Line, map[string]string{importErrorFields[outcome.err]: outcome.err.Error()})
+			case outcome.created:
+				report.Created++
+			default:
+				report.Updated++
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	seen := make(map[string]int) // Name or SKU to the line of its row
+	for {
+		row, errs, err := rows.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows++
+		if len(errs) > 0 {
+			fail(row.Line, errs)
+			continue
+		}
+
+		field, key := "name", row.Name
+		if row.SKU != "" {
+			field, key = "sku", row.SKU
+		}
+		if line, ok := seen[field+":"+key]; ok {
+			fail(row.Line, map[string]string{field: fmt.Sprintf("the %s repeats the row on line %d", field, line)})
+			continue
+		}
+		seen[field+":"+key] = row.Line
+
+		batch = append(batch, *row)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Export is a pass-through to the repository.
+func (ps *ProductService) Export(ctx context.Context, fn func(*CatalogRow) error) error {
+	return ps.productRepo.Export(ctx, fn)
+}
+
+// RunPublishSchedule applies the publish_at and unpublish_at dates of the products every interval
+// until ctx is done.
+func (ps *ProductService) RunPublishSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		published, unpublished, err := ps.productRepo.PublishScheduled(ctx)
+		if err != nil {
+			log.Printf("error applying the product publishing schedule: %v\n", err)
+		} else if published > 0 || unpublished > 0 {
+			log.Printf("published %d and unpublished %d scheduled products\n", published, unpublished)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SchedulePrice adds a regular price or a sale to the price history of the product. Prices can
+// start now or later, never in the past.
+func (ps *ProductService) SchedulePrice(ctx context.Context, productID int, data *SchedulePriceRequest) (*Price, error) {
+	if _, err := ps.productRepo.FindByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if data.ValidFrom != nil && data.ValidFrom.Before(now) {
+		return nil, ErrPriceInPast
+	}
+
+	from := now
+	if data.ValidFrom != nil {
+		from = *data.ValidFrom
+	}
+	switch data.Kind {
+	case PriceSale:
+		if data.ValidTo == nil || !data.ValidTo.After(from) {
+			return nil, ErrInvalidPriceRange
+		}
+	case PriceRegular:
+		if data.ValidTo != nil {
+			return nil, ErrInvalidPriceRange
+		}
+	}
+
+	return ps.productRepo.CreatePrice(ctx, productID, *data)
+}
+
+// CancelPrice is a pass-through to the repository.
+func (ps *ProductService) CancelPrice(ctx context.Context, productID int, id int64) error {
+	return ps.productRepo.CancelPrice(ctx, productID, id)
+}
+
+// PriceHistory returns the price history of the product. Admins also get the prices that have not
+// started yet and the history of products that are not active.
+func (ps *ProductService) PriceHistory(ctx context.Context, productID int, admin bool) ([]Price, error) {
+	product, err := ps.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Status != StatusActive && !admin {
+		return nil, sql.ErrNoRows
+	}
+	return ps.productRepo.FindPriceHistory(ctx, productID, admin)
 }
@@ -0,0 +1,337 @@
+package products
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Formats of catalog imports and exports.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("the format must be csv or ndjson")
+	ErrInvalidCSVHeader  = errors.New("the CSV header must name known columns once each and include name or sku")
+	ErrMalformedImport   = errors.New("the file could not be parsed")
+)
+
+// ContentTypes are the content types of the export formats.
+var ContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// catalogColumns are the columns of the CSV format, in export order.
+var catalogColumns = []string{"name", "sku", "description", "price", "stock", "tax_category", "weight_grams", "length_cm", "width_cm", "height_cm", "barcode", "status"}
+
+// maxLineBytes caps the length of a line of an NDJSON import.
+const maxLineBytes = 1 << 20
+
+// ParseFormat returns the import format given by the format parameter or, when empty, by the
+// content type of the request.
+func ParseFormat(format, contentType string) (string, error) {
+	if format != "" {
+		if _, ok := ContentTypes[format]; !ok {
+			return "", ErrUnsupportedFormat
+		}
+		return format, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// RowReader reads and validates the rows of an imported file. A row with problems is returned
+// with them by field; io.EOF ends the file and any other error aborts the import.
+type RowReader struct {
+	next     func() (*CatalogRow, map[string]string, error)
+	validate *validator.Validate
+}
+
+// NewRowReader reads r in the given format. A CSV file must start with a header naming its columns.
+func NewRowReader(r io.Reader, format string, validate *validator.Validate) (*RowReader, error) {
+	rr := &RowReader{validate: validate}
+	switch format {
+	case FormatCSV:
+		cr, err := newCSVReader(r)
+		if err != nil {
+			return nil, err
+		}
+		rr.next = cr.read
+	case FormatNDJSON:
+		nr := &ndjsonReader{scanner: bufio.NewScanner(r)}
+		nr.scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+		rr.next = nr.read
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	return rr, nil
+}
+
+func (rr *RowReader) Read() (*CatalogRow, map[string]string, error) {
+	row, errs, err := rr.next()
+	if err != nil || len(errs) > 0 {
+		return row, errs, err
+	}
+
+	if row.Name == "" && row.SKU == "" {
+		return row, map[string]string{"name": "the name or sku field is required"}, nil
+	}
+	if err := rr.validate.Struct(row); err != nil {
+		return row, httpx.FormatValidatorErrors(err), nil
+	}
+	return row, nil, nil
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // Reported per row instead of aborting the import
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.Is(err, io.EOF) || errors.As(err, &parseErr) {
+			return nil, ErrInvalidCSVHeader
+		}
+		return nil, err
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(catalogColumns, name) || slices.Contains(columns[:i], name) {
+			return nil, ErrInvalidCSVHeader
+		}
+		columns[i] = name
+	}
+	if !slices.Contains(columns, "name") && !slices.Contains(columns, "sku") {
+		return nil, ErrInvalidCSVHeader
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+// read parses a record. Empty cells leave the field unset.
+func (cr *csvReader) read() (*CatalogRow, map[string]string, error) {
+	record, err := cr.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, nil, fmt.Errorf("%w: %v", ErrMalformedImport, err)
+		}
+		return nil, nil, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	row := &CatalogRow{Line: line}
+	if len(record) != len(cr.columns) {
+		return row, map[string]string{"row": fmt.Sprintf("the row has %d fields but the header has %d", len(record), len(cr.columns))}, nil
+	}
+
+	errs := make(map[string]string)
+	for i, column := range cr.columns {
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+
+		switch column {
+		case "name":
+			row.Name = value
+		case "sku":
+			row.SKU = value
+		case "description":
+			row.Description = &value
+		case "tax_category":
+			row.TaxCategory = &value
+		case "barcode":
+			row.Barcode = &value
+		case "status":
+			row.Status = &value
+		case "price":
+			row.Price = parseFloatCell(value, column, errs)
+		case "length_cm":
+			row.LengthCm = parseFloatCell(value, column, errs)
+		case "width_cm":
+			row.WidthCm = parseFloatCell(value, column, errs)
+		case "height_cm":
+			row.HeightCm = parseFloatCell(value, column, errs)
+		case "stock":
+			row.Stock = parseIntCell(value, column, errs)
+		case "weight_grams":
+			row.WeightGrams = parseIntCell(value, column, errs)
+		}
+	}
+	return row, errs, nil
+}
+
+func parseFloatCell(value, column string, errs map[string]string) *float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		errs[column] = fmt.Sprintf("the %s field must be a number", column)
+		return nil
+	}
+	return &f
+}
+
+func parseIntCell(value, column string, errs map[string]string) *int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		errs[column] = fmt.Sprintf("the %s field must be an integer", column)
+		return nil
+	}
+	return &n
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// read decodes the next non-blank line as a JSON object with the fields of a CatalogRow.
+func (nr *ndjsonReader) read() (*CatalogRow, map[string]string, error) {
+	for nr.scanner.Scan() {
+		nr.line++
+		data := bytes.TrimSpace(nr.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := &CatalogRow{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(row); err != nil {
+			return &CatalogRow{Line: nr.line}, map[string]string{"row": fmt.Sprintf("the line is not a valid product object: %v", err)}, nil
+		}
+		row.Line = nr.line
+		return row, nil, nil
+	}
+
+	if err := nr.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, nil, fmt.Errorf("%w: line %d is longer than %d bytes", ErrMalformedImport, nr.line+1, maxLineBytes)
+		}
+		return nil, nil, err
+	}
+	return nil, nil, io.EOF
+}
+
+// RowWriter writes the rows of an export in the given format.
+type RowWriter interface {
+	Write(row *CatalogRow) error
+	Flush() error
+}
+
+// NewRowWriter returns a writer of the format, which must be one of ContentTypes. CSV output starts
+// with a header row.
+func NewRowWriter(w io.Writer, format string) RowWriter {
+	if format == FormatNDJSON {
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}
+	}
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvWriter) Write(row *CatalogRow) error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(catalogColumns); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+
+	return cw.w.Write([]string{
+		row.Name,
+		row.SKU,
+		formatStringCell(row.Description),
+		formatFloatCell(row.Price),
+		formatIntCell(row.Stock),
+		formatStringCell(row.TaxCategory),
+		formatIntCell(row.WeightGrams),
+		formatFloatCell(row.LengthCm),
+		formatFloatCell(row.WidthCm),
+		formatFloatCell(row.HeightCm),
+		formatStringCell(row.Barcode),
+		formatStringCell(row.Status),
+	})
+}
+
+func (cw *csvWriter) Flush() error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(catalogColumns); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func formatStringCell(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatFloatCell(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func formatIntCell(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(row *CatalogRow) error {
+	return nw.enc.Encode(row)
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nw.w.Flush()
+}
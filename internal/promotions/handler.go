@@ -0,0 +1,163 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/utils"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		Create(ctx context.Context, p *CreatePromotionRequest) error
+		FindByID(ctx context.Context, id int) (*Promotion, error)
+		FindAll(ctx context.Context, page, limit int) ([]Promotion, error)
+		Update(ctx context.Context, id int, p UpdatePromotionRequest) error
+		Delete(ctx context.Context, id int) error
+		Count(ctx context.Context) (int, error)
+	}
+
+	PromotionHandler struct {
+		promotionService Service
+		validate         *validator.Validate
+		config           *config.Config
+	}
+)
+
+func NewPromotionHandler(promotionService Service, validate *validator.Validate, config *config.Config) *PromotionHandler {
+	return &PromotionHandler{promotionService: promotionService, validate: validate, config: config}
+}
+
+func (h *PromotionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := CreatePromotionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.promotionService.Create(ctx, &req); err != nil {
+		switch {
+		case errors.Is(err, ErrPercentageTooHigh):
+			httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"value": err.Error()})
+		case errors.Is(err, ErrCodeTaken):
+			httpx.HTTPErrors(w, http.StatusConflict, map[string]string{"code": err.Error()})
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, map[string]string{"message": httpx.CreatedResponse})
+}
+
+func (h *PromotionHandler) FindAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pageStr := r.URL.Query().Get("page")
+	limitStr := r.URL.Query().Get("limit")
+
+	page, limit := utils.ParsePaginationParams(pageStr, limitStr, h.config.Limit, h.config.MaxLimit)
+	total, err := h.promotionService.Count(ctx)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	promotions, err := h.promotionService.FindAll(ctx, page, limit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if promotions == nil {
+		promotions = []Promotion{}
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, promotions, page, limit, total)
+}
+
+func (h *PromotionHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	promotion, err := h.promotionService.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, promotion)
+}
+
+func (h *PromotionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdatePromotionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdatePromotionRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.promotionService.Update(ctx, id, req); err != nil {
+		if errors.Is(err, ErrPercentageTooHigh) {
+			httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"value": err.Error()})
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.UpdatedResponse})
+}
+
+func (h *PromotionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.promotionService.Delete(ctx, id); err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
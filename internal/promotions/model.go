@@ -0,0 +1,85 @@
+// Package promotions defines coupon codes and automatic promotions applied to carts.
+package promotions
+
+import "time"
+
+const (
+	TypePercentage   = "percentage"    // Value is a percentage off the eligible subtotal
+	TypeFixedAmount  = "fixed_amount"  // Value is an amount off the eligible subtotal
+	TypeBuyXGetY     = "buy_x_get_y"   // Every BuyQuantity units of a product, GetQuantity more are free
+	TypeFreeShipping = "free_shipping" // Waives shipping costs, no discount on items
+)
+
+// Promotion is either a coupon (Code set) or an automatic promotion (Code nil).
+// Amounts are expressed in the same unit as carts.Cart (cents).
+type Promotion struct {
+	ID                int        `json:"id"`
+	Code              *string    `json:"code,omitempty"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description,omitempty"`
+	Type              string     `json:"type"`
+	Value             float64    `json:"value"`
+	BuyQuantity       int        `json:"buy_quantity,omitempty"`
+	GetQuantity       int        `json:"get_quantity,omitempty"`
+	CategoryID        *int       `json:"category_id,omitempty"` // Restricts the promotion to products in this category
+	MinSubtotal       int64      `json:"min_subtotal"`
+	UsageLimit        *int       `json:"usage_limit,omitempty"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user,omitempty"`
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	Active            bool       `json:"active"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+type CreatePromotionRequest struct {
+	Code              *string    `json:"code" validate:"omitempty,min=3,max=50"`
+	Name              string     `json:"name" validate:"required"`
+	Description       string     `json:"description"`
+	Type              string     `json:"type" validate:"required,oneof=percentage fixed_amount buy_x_get_y free_shipping"`
+	Value             float64    `json:"value" validate:"gte=0"`
+	BuyQuantity       int        `json:"buy_quantity" validate:"required_if=Type buy_x_get_y,gte=0"`
+	GetQuantity       int        `json:"get_quantity" validate:"required_if=Type buy_x_get_y,gte=0"`
+	CategoryID        *int       `json:"category_id"`
+	MinSubtotal       int64      `json:"min_subtotal" validate:"gte=0"`
+	UsageLimit        *int       `json:"usage_limit" validate:"omitempty,gt=0"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" validate:"omitempty,gt=0"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+}
+
+type UpdatePromotionRequest struct {
+	Name              *string    `json:"name,omitempty"`
+	Description       *string    `json:"description,omitempty"`
+	Value             *float64   `json:"value,omitempty" validate:"omitempty,gte=0"`
+	MinSubtotal       *int64     `json:"min_subtotal,omitempty" validate:"omitempty,gte=0"`
+	UsageLimit        *int       `json:"usage_limit,omitempty" validate:"omitempty,gt=0"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user,omitempty" validate:"omitempty,gt=0"`
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	Active            *bool      `json:"active,omitempty"`
+}
+
+// Line is a cart line as seen by the promotion engine.
+type Line struct {
+	ProductID int64
+	Quantity  int64
+	UnitPrice int64
+	Total     int64
+}
+
+// AppliedDiscount describes a promotion that was applied to a cart.
+type AppliedDiscount struct {
+	PromotionID  int     `json:"promotion_id"`
+	Code         *string `json:"code,omitempty"`
+	Name         string  `json:"name"`
+	Amount       int64   `json:"amount"`
+	FreeShipping bool    `json:"free_shipping,omitempty"`
+}
+
+// Evaluation is the result of running every applicable promotion against a cart.
+type Evaluation struct {
+	Discounts    []AppliedDiscount
+	Discount     int64
+	FreeShipping bool
+}
@@ -0,0 +1,228 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const promotionColumns = "id, code, name, description, type, value, buy_quantity, get_quantity, category_id, min_subtotal, usage_limit, usage_limit_per_user, starts_at, ends_at, active, created_at, updated_at"
+
+type PromotionRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionRepository(db *sql.DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPromotion(s scanner) (*Promotion, error) {
+	var p Promotion
+	var description sql.NullString
+	err := s.Scan(&p.ID, &p.Code, &p.Name, &description, &p.Type, &p.Value, &p.BuyQuantity, &p.GetQuantity, &p.CategoryID, &p.MinSubtotal, &p.UsageLimit, &p.UsageLimitPerUser, &p.StartsAt, &p.EndsAt, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Description = description.String
+	return &p, nil
+}
+
+func (r *PromotionRepository) queryPromotions(ctx context.Context, query string, args ...any) ([]Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var promotions []Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promotions, nil
+}
+
+func (r *PromotionRepository) Create(ctx context.Context, data CreatePromotionRequest) error {
+	query := "INSERT INTO promotions (code, name, description, type, value, buy_quantity, get_quantity, category_id, min_subtotal, usage_limit, usage_limit_per_user, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)"
+	_, err := r.db.ExecContext(ctx, query, data.Code, data.Name, data.Description, data.Type, data.Value, data.BuyQuantity, data.GetQuantity, data.CategoryID, data.MinSubtotal, data.UsageLimit, data.UsageLimitPerUser, data.StartsAt, data.EndsAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation, codes are unique regardless of case
+		return ErrCodeTaken
+	}
+	return err
+}
+
+func (r *PromotionRepository) FindByID(ctx context.Context, id int) (*Promotion, error) {
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE id = $1"
+	return scanPromotion(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *PromotionRepository) FindByCode(ctx context.Context, code string) (*Promotion, error) {
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE UPPER(code) = UPPER($1)"
+	return scanPromotion(r.db.QueryRowContext(ctx, query, code))
+}
+
+func (r *PromotionRepository) FindAll(ctx context.Context, limit, offset int) ([]Promotion, error) {
+	query := "SELECT " + promotionColumns + " FROM promotions ORDER BY id LIMIT $1 OFFSET $2"
+	return r.queryPromotions(ctx, query, limit, offset)
+}
+
+// FindAutomatic returns the active promotions that apply without a coupon code.
+func (r *PromotionRepository) FindAutomatic(ctx context.Context) ([]Promotion, error) {
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE code IS NULL AND active = TRUE ORDER BY id"
+	return r.queryPromotions(ctx, query)
+}
+
+func (r *PromotionRepository) Update(ctx context.Context, id int, p UpdatePromotionRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	if p.Name != nil {
+		fields = append(fields, fmt.Sprintf("name = $%d", i))
+		args = append(args, p.Name)
+		i++
+	}
+	if p.Description != nil {
+		fields = append(fields, fmt.Sprintf("description = $%d", i))
+		args = append(args, p.Description)
+		i++
+	}
+	if p.Value != nil {
+		fields = append(fields, fmt.Sprintf("value = $%d", i))
+		args = append(args, p.Value)
+		i++
+	}
+	if p.MinSubtotal != nil {
+		fields = append(fields, fmt.Sprintf("min_subtotal = $%d", i))
+		args = append(args, p.MinSubtotal)
+		i++
+	}
+	if p.UsageLimit != nil {
+		fields = append(fields, fmt.Sprintf("usage_limit = $%d", i))
+		args = append(args, p.UsageLimit)
+		i++
+	}
+	if p.UsageLimitPerUser != nil {
+		fields = append(fields, fmt.Sprintf("usage_limit_per_user = $%d", i))
+		args = append(args, p.UsageLimitPerUser)
+		i++
+	}
+	if p.StartsAt != nil {
+		fields = append(fields, fmt.Sprintf("starts_at = $%d", i))
+		args = append(args, p.StartsAt)
+		i++
+	}
+	if p.EndsAt != nil {
+		fields = append(fields, fmt.Sprintf("ends_at = $%d", i))
+		args = append(args, p.EndsAt)
+		i++
+	}
+	if p.Active != nil {
+		fields = append(fields, fmt.Sprintf("active = $%d", i))
+		args = append(args, p.Active)
+		i++
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	query := fmt.Sprintf("UPDATE promotions SET %s WHERE id = $%d", strings.Join(fields, ", "), i)
+	args = append(args, id)
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *PromotionRepository) Delete(ctx context.Context, id int) error {
+	query := "DELETE FROM promotions WHERE id = $1"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *PromotionRepository) Count(ctx context.Context) (int, error) {
+	query := "SELECT COUNT(*) FROM promotions"
+	var count int
+	if err := r.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error scanning promotion count: %v", err)
+	}
+	return count, nil
+}
+
+// CountRedemptions returns how many times the promotion has been redeemed, optionally restricted to a user.
+func (r *PromotionRepository) CountRedemptions(ctx context.Context, promotionID int, userID *int64) (int, error) {
+	query := "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND ($2::BIGINT IS NULL OR user_id = $2)"
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, promotionID, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindProductsInCategory returns which of the given products are assigned to the category or to any
+// of its descendants.
+func (r *PromotionRepository) FindProductsInCategory(ctx context.Context, categoryID int, productIDs []int64) (map[int64]bool, error) {
+	query := `WITH RECURSIVE descendants AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN descendants d ON c.parent_id = d.id
+		)
+		SELECT DISTINCT pc.product_id FROM product_category pc JOIN descendants d ON d.id = pc.category_id
+		WHERE pc.product_id = ANY($2)`
+	rows, err := r.db.QueryContext(ctx, query, categoryID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	inCategory := make(map[int64]bool)
+	for rows.Next() {
+		var productID int64
+		if err := rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		inCategory[productID] = true
+	}
+
+	return inCategory, rows.Err()
+}
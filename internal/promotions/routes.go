@@ -0,0 +1,22 @@
+package promotions
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *PromotionHandler, am *auth.AuthMiddleware) {
+	r.Route("/promotions", func(r chi.Router) {
+		r.Use(am.VerifyToken, am.RequireAdmin)
+
+		r.Get("/", h.FindAll)
+		r.Post("/", h.Create)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", h.FindByID)
+			r.Patch("/", h.Update)
+			r.Delete("/", h.Delete)
+		})
+	})
+}
@@ -0,0 +1,264 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrCouponNotFound          = errors.New("coupon code not found")
+	ErrCouponInactive          = errors.New("coupon code is not active")
+	ErrCouponExpired           = errors.New("coupon code is outside its validity window")
+	ErrCouponUsageLimitReached = errors.New("coupon code has reached its usage limit")
+	ErrMinimumSpendNotMet      = errors.New("cart subtotal does not meet the coupon minimum spend")
+	ErrCouponNotApplicable     = errors.New("coupon code does not apply to any item in the cart")
+	ErrPercentageTooHigh       = errors.New("the value of a percentage promotion cannot be over 100")
+	ErrCodeTaken               = errors.New("another promotion already uses this code")
+)
+
+type (
+	Repository interface {
+		Create(ctx context.Context, data CreatePromotionRequest) error
+		FindByID(ctx context.Context, id int) (*Promotion, error)
+		FindByCode(ctx context.Context, code string) (*Promotion, error)
+		FindAll(ctx context.Context, limit, offset int) ([]Promotion, error)
+		FindAutomatic(ctx context.Context) ([]Promotion, error)
+		Update(ctx context.Context, id int, data UpdatePromotionRequest) error
+		Delete(ctx context.Context, id int) error
+		Count(ctx context.Context) (int, error)
+		CountRedemptions(ctx context.Context, promotionID int, userID *int64) (int, error)
+		FindProductsInCategory(ctx context.Context, categoryID int, productIDs []int64) (map[int64]bool, error)
+	}
+
+	PromotionService struct {
+		promotionRepo Repository
+	}
+)
+
+func NewPromotionService(promotionRepo Repository) *PromotionService {
+	return &PromotionService{promotionRepo: promotionRepo}
+}
+
+func (s *PromotionService) Create(ctx context.Context, p *CreatePromotionRequest) error {
+	if p.Type == TypePercentage && p.Value > 100 {
+		return ErrPercentageTooHigh
+	}
+	return s.promotionRepo.Create(ctx, *p)
+}
+
+func (s *PromotionService) FindByID(ctx context.Context, id int) (*Promotion, error) {
+	return s.promotionRepo.FindByID(ctx, id)
+}
+
+func (s *PromotionService) FindAll(ctx context.Context, page, limit int) ([]Promotion, error) {
+	offset := (page - 1) * limit
+	return s.promotionRepo.FindAll(ctx, limit, offset)
+}
+
+func (s *PromotionService) Update(ctx context.Context, id int, p UpdatePromotionRequest) error {
+	if p.Value != nil && *p.Value > 100 {
+		current, err := s.promotionRepo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if current.Type == TypePercentage {
+			return ErrPercentageTooHigh
+		}
+	}
+	return s.promotionRepo.Update(ctx, id, p)
+}
+
+func (s *PromotionService) Delete(ctx context.Context, id int) error {
+	return s.promotionRepo.Delete(ctx, id)
+}
+
+func (s *PromotionService) Count(ctx context.Context) (int, error) {
+	return s.promotionRepo.Count(ctx)
+}
+
+// ValidateCoupon checks that a coupon code can be applied by the user to the given lines.
+func (s *PromotionService) ValidateCoupon(ctx context.Context, userID int64, code string, lines []Line) (*Promotion, error) {
+	p, err := s.promotionRepo.FindByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.checkEligibility(ctx, p, userID, lines, time.Now()); err != nil {
+		return nil, err
+	}
+
+	amount, _, err := s.discountFor(ctx, p, lines)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 && p.Type != TypeFreeShipping {
+		return nil, ErrCouponNotApplicable
+	}
+
+	return p, nil
+}
+
+// Evaluate runs every automatic promotion plus the given coupon codes against the lines.
+// Coupons that are no longer eligible (e.g. the cart dropped below the minimum spend) are skipped.
+func (s *PromotionService) Evaluate(ctx context.Context, userID int64, codes []string, lines []Line) (*Evaluation, error) {
+	candidates, err := s.promotionRepo.FindAutomatic(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		p, err := s.promotionRepo.FindByCode(ctx, code)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+		candidates = append(candidates, *p)
+	}
+
+	var subtotal int64
+	for _, l := range lines {
+		subtotal += l.Total
+	}
+
+	now := time.Now()
+	evaluation := &Evaluation{Discounts: []AppliedDiscount{}}
+	for i := range candidates {
+		p := &candidates[i]
+		if err := s.checkEligibility(ctx, p, userID, lines, now); err != nil {
+			if isIneligible(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		amount, freeShipping, err := s.discountFor(ctx, p, lines)
+		if err != nil {
+			return nil, err
+		}
+		if amount == 0 && !freeShipping {
+			continue
+		}
+
+		// Never discount more than what is left of the cart
+		if remaining := subtotal - evaluation.Discount; amount > remaining {
+			amount = remaining
+		}
+
+		evaluation.Discounts = append(evaluation.Discounts, AppliedDiscount{
+			PromotionID:  p.ID,
+			Code:         p.Code,
+			Name:         p.Name,
+			Amount:       amount,
+			FreeShipping: freeShipping,
+		})
+		evaluation.Discount += amount
+		evaluation.FreeShipping = evaluation.FreeShipping || freeShipping
+	}
+
+	return evaluation, nil
+}
+
+func isIneligible(err error) bool {
+	return errors.Is(err, ErrCouponInactive) ||
+		errors.Is(err, ErrCouponExpired) ||
+		errors.Is(err, ErrCouponUsageLimitReached) ||
+		errors.Is(err, ErrMinimumSpendNotMet)
+}
+
+func (s *PromotionService) checkEligibility(ctx context.Context, p *Promotion, userID int64, lines []Line, now time.Time) error {
+	if !p.Active {
+		return ErrCouponInactive
+	}
+	if (p.StartsAt != nil && now.Before(*p.StartsAt)) || (p.EndsAt != nil && now.After(*p.EndsAt)) {
+		return ErrCouponExpired
+	}
+
+	var subtotal int64
+	for _, l := range lines {
+		subtotal += l.Total
+	}
+	if subtotal < p.MinSubtotal {
+		return ErrMinimumSpendNotMet
+	}
+
+	if p.UsageLimit != nil {
+		used, err := s.promotionRepo.CountRedemptions(ctx, p.ID, nil)
+		if err != nil {
+			return err
+		}
+		if used >= *p.UsageLimit {
+			return ErrCouponUsageLimitReached
+		}
+	}
+
+	// Guests have no email until checkout, where their uses are counted by the guest customer
+	if p.UsageLimitPerUser != nil && userID != 0 {
+		used, err := s.promotionRepo.CountRedemptions(ctx, p.ID, &userID)
+		if err != nil {
+			return err
+		}
+		if used >= *p.UsageLimitPerUser {
+			return ErrCouponUsageLimitReached
+		}
+	}
+
+	return nil
+}
+
+// discountFor computes the amount a promotion takes off the lines it is scoped to.
+func (s *PromotionService) discountFor(ctx context.Context, p *Promotion, lines []Line) (int64, bool, error) {
+	eligible := lines
+	if p.CategoryID != nil {
+		productIDs := make([]int64, 0, len(lines))
+		for _, l := range lines {
+			productIDs = append(productIDs, l.ProductID)
+		}
+
+		inCategory, err := s.promotionRepo.FindProductsInCategory(ctx, *p.CategoryID, productIDs)
+		if err != nil {
+			return 0, false, err
+		}
+
+		eligible = make([]Line, 0, len(lines))
+		for _, l := range lines {
+			if inCategory[l.ProductID] {
+				eligible = append(eligible, l)
+			}
+		}
+	}
+
+	var base int64
+	for _, l := range eligible {
+		base += l.Total
+	}
+
+	switch p.Type {
+	case TypePercentage:
+		return int64(math.Round(float64(base) * p.Value / 100)), false, nil
+	case TypeFixedAmount:
+		return min(int64(math.Round(p.Value)), base), false, nil
+	case TypeBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return 0, false, nil
+		}
+		var amount int64
+		group := int64(p.BuyQuantity + p.GetQuantity)
+		for _, l := range eligible {
+			free := (l.Quantity / group) * int64(p.GetQuantity)
+			amount += free * l.UnitPrice
+		}
+		return min(amount, base), false, nil
+	case TypeFreeShipping:
+		return 0, len(eligible) > 0, nil
+	}
+
+	return 0, false, nil
+}
@@ -0,0 +1,249 @@
+package reviews
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/utils"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		Create(ctx context.Context, productID, userID int64, data CreateReviewRequest) (*Review, error)
+		FindByProductID(ctx context.Context, productID int64, page, limit int) ([]Review, error)
+		CountByProductID(ctx context.Context, productID int64) (int, error)
+		FindByStatus(ctx context.Context, status string, page, limit int) ([]Review, error)
+		CountByStatus(ctx context.Context, status string) (int, error)
+		Update(ctx context.Context, id, userID int64, data UpdateReviewRequest) (*Review, error)
+		Moderate(ctx context.Context, id int64, status string, moderatorID int64) (*Review, error)
+		Delete(ctx context.Context, id int64, userID *int64) error
+	}
+
+	ReviewHandler struct {
+		reviewService Service
+		validate      *validator.Validate
+		config        *config.Config
+	}
+)
+
+func NewReviewHandler(reviewService Service, validate *validator.Validate, config *config.Config) *ReviewHandler {
+	return &ReviewHandler{reviewService: reviewService, validate: validate, config: config}
+}
+
+func (h *ReviewHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	req := CreateReviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	review, err := h.reviewService.Create(ctx, productID, userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+		case errors.Is(err, ErrAlreadyReviewed):
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+		default:
+			httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		}
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, review)
+}
+
+// FindByProductID lists the approved reviews of a product, newest first.
+func (h *ReviewHandler) FindByProductID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	page, limit := utils.ParsePaginationParams(r.URL.Query().Get("page"), r.URL.Query().Get("limit"), h.config.Limit, h.config.MaxLimit)
+
+	reviews, err := h.reviewService.FindByProductID(ctx, productID, page, limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+	total, err := h.reviewService.CountByProductID(ctx, productID)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, reviews, page, limit, total)
+}
+
+// FindByStatus lists the reviews with the status of ?status, pending by default, oldest first.
+func (h *ReviewHandler) FindByStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = StatusPending
+	}
+	if err := h.validate.Var(status, "oneof=pending approved rejected"); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"status": "the status field must be one of pending, approved or rejected"})
+		return
+	}
+
+	page, limit := utils.ParsePaginationParams(r.URL.Query().Get("page"), r.URL.Query().Get("limit"), h.config.Limit, h.config.MaxLimit)
+
+	total, err := h.reviewService.CountByStatus(ctx, status)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+	reviews, err := h.reviewService.FindByStatus(ctx, status, page, limit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, reviews, page, limit, total)
+}
+
+// Update edits a review of the authenticated user.
+func (h *ReviewHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	req := UpdateReviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	review, err := h.reviewService.Update(ctx, id, userID, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, review)
+}
+
+// Moderate approves or rejects a review.
+func (h *ReviewHandler) Moderate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	moderatorID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	req := ModerateReviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	review, err := h.reviewService.Moderate(ctx, id, req.Status, moderatorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, review)
+}
+
+// Delete removes a review of the authenticated user, or any review when the user is an admin.
+func (h *ReviewHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	owner := &userID
+	if auth.IsAdmin(ctx) {
+		owner = nil
+	}
+
+	if err := h.reviewService.Delete(ctx, id, owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
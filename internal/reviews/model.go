@@ -0,0 +1,44 @@
+// Package reviews lets customers rate and review the products they buy, with admin moderation.
+package reviews
+
+import "time"
+
+// Review statuses. Reviews wait in pending until an admin approves or rejects them.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Review is the rating and opinion of a user about a product. Only approved reviews are public and
+// count towards the rating of the product.
+type Review struct {
+	ID          int64      `json:"id"`
+	ProductID   int64      `json:"product_id"`
+	UserID      int64      `json:"user_id"`
+	Rating      int        `json:"rating"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Verified    bool       `json:"verified"` // The user has an order with the product
+	Status      string     `json:"status"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" validate:"required,gte=1,lte=5"`
+	Title  string `json:"title" validate:"max=150"`
+	Body   string `json:"body" validate:"required,max=5000"`
+}
+
+// UpdateReviewRequest edits a review, which goes back to moderation.
+type UpdateReviewRequest struct {
+	Rating *int    `json:"rating" validate:"omitempty,gte=1,lte=5"`
+	Title  *string `json:"title" validate:"omitempty,max=150"`
+	Body   *string `json:"body" validate:"omitempty,min=1,max=5000"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved rejected"`
+}
@@ -0,0 +1,261 @@
+package reviews
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+type ReviewRepository struct {
+	db *sql.DB
+}
+
+func NewReviewRepository(db *sql.DB) *ReviewRepository {
+	return &ReviewRepository{db: db}
+}
+
+const reviewColumns = "id, product_id, user_id, rating, title, body, verified, status, moderated_at, created_at, updated_at"
+
+// purchased tells whether the user ($2) has an order with the product ($1) that was not cancelled
+// or left unprocessed.
+const purchased = `EXISTS (
+	SELECT 1 FROM order_items oi
+	JOIN orders o ON o.id = oi.order_id
+	WHERE oi.product_id = $1::BIGINT AND o.user_id = $2::INT AND o.status NOT IN ('pending', 'cancelled')
+)`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReview(s scanner) (*Review, error) {
+	var r Review
+	err := s.Scan(&r.ID, &r.ProductID, &r.UserID, &r.Rating, &r.Title, &r.Body, &r.Verified, &r.Status, &r.ModeratedAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Create saves a pending review, flagged verified when the user bought the product.
+func (rr *ReviewRepository) Create(ctx context.Context, productID, userID int64, data CreateReviewRequest) (*Review, error) {
+	query := `INSERT INTO reviews (product_id, user_id, rating, title, body, verified)
+		VALUES ($1, $2, $3, $4, $5, ` + purchased + `)
+		RETURNING ` + reviewColumns
+	review, err := scanReview(rr.db.QueryRowContext(ctx, query, productID, userID, data.Rating, data.Title, data.Body))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return nil, ErrAlreadyReviewed
+	}
+	return review, err
+}
+
+func (rr *ReviewRepository) FindByID(ctx context.Context, id int64) (*Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE id = $1"
+	return scanReview(rr.db.QueryRowContext(ctx, query, id))
+}
+
+// FindByProductID returns a page of the reviews of the product with the status, newest first.
+func (rr *ReviewRepository) FindByProductID(ctx context.Context, productID int64, status string, limit, offset int) ([]Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE product_id = $1 AND status = $2 ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4"
+	return rr.queryReviews(ctx, query, productID, status, limit, offset)
+}
+
+func (rr *ReviewRepository) CountByProductID(ctx context.Context, productID int64, status string) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM reviews WHERE product_id = $1 AND status = $2"
+	if err := rr.db.QueryRowContext(ctx, query, productID, status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error scanning review count: %v", err)
+	}
+	return count, nil
+}
+
+// FindByStatus returns a page of the reviews with the status, oldest first, as a moderation queue.
+func (rr *ReviewRepository) FindByStatus(ctx context.Context, status string, limit, offset int) ([]Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE status = $1 ORDER BY created_at, id LIMIT $2 OFFSET $3"
+	return rr.queryReviews(ctx, query, status, limit, offset)
+}
+
+func (rr *ReviewRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	var count int
+	if err := rr.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM reviews WHERE status = $1", status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error scanning review count: %v", err)
+	}
+	return count, nil
+}
+
+func (rr *ReviewRepository) queryReviews(ctx context.Context, query string, args ...any) ([]Review, error) {
+	rows, err := rr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	reviews := []Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// Update edits the review of the user and sends it back to moderation, taking it out of the rating
+// of the product if it was approved. The verified flag is checked again.
+func (rr *ReviewRepository) Update(ctx context.Context, id, userID int64, data UpdateReviewRequest) (*Review, error) {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := lockReview(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.UserID != userID {
+		err = sql.ErrNoRows
+		return nil, err
+	}
+
+	if current.Status == StatusApproved {
+		if err = adjustRating(ctx, tx, current.ProductID, -current.Rating, -1); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `UPDATE reviews SET
+			rating = COALESCE($3, rating),
+			title = COALESCE($4, title),
+			body = COALESCE($5, body),
+			verified = ` + purchased + `,
+			status = 'pending',
+			moderated_by = NULL,
+			moderated_at = NULL,
+			updated_at = NOW()
+		WHERE product_id = $1 AND user_id = $2
+		RETURNING ` + reviewColumns
+	review, err := scanReview(tx.QueryRowContext(ctx, query, current.ProductID, userID, data.Rating, data.Title, data.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return review, nil
+}
+
+// SetStatus moderates the review, adding it to or taking it out of the rating of the product when
+// it enters or leaves the approved status.
+func (rr *ReviewRepository) SetStatus(ctx context.Context, id int64, status string, moderatorID int64) (*Review, error) {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := lockReview(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case current.Status != StatusApproved && status == StatusApproved:
+		err = adjustRating(ctx, tx, current.ProductID, current.Rating, 1)
+	case current.Status == StatusApproved && status != StatusApproved:
+		err = adjustRating(ctx, tx, current.ProductID, -current.Rating, -1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE reviews SET status = $2, moderated_by = $3, moderated_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + reviewColumns
+	review, err := scanReview(tx.QueryRowContext(ctx, query, id, status, moderatorID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return review, nil
+}
+
+// Delete removes the review, taking it out of the rating of the product if it was approved. When
+// userID is given, only a review of that user is deleted.
+func (rr *ReviewRepository) Delete(ctx context.Context, id int64, userID *int64) error {
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := lockReview(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if userID != nil && current.UserID != *userID {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	if current.Status == StatusApproved {
+		if err = adjustRating(ctx, tx, current.ProductID, -current.Rating, -1); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM reviews WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockReview reads the review and locks it until the end of the transaction, so concurrent
+// moderation cannot count it twice.
+func lockReview(ctx context.Context, tx *sql.Tx, id int64) (*Review, error) {
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE id = $1 FOR UPDATE"
+	return scanReview(tx.QueryRowContext(ctx, query, id))
+}
+
+// adjustRating adds to the totals of the approved reviews of the product.
+func adjustRating(ctx context.Context, tx *sql.Tx, productID int64, sum, count int) error {
+	query := "UPDATE products SET rating_sum = rating_sum + $2, rating_count = rating_count + $3 WHERE id = $1"
+	if _, err := tx.ExecContext(ctx, query, productID, sum, count); err != nil {
+		return fmt.Errorf("error updating the product rating: %w", err)
+	}
+	return nil
+}
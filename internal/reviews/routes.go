@@ -0,0 +1,22 @@
+package reviews
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *ReviewHandler, am *auth.AuthMiddleware) {
+	r.Get("/products/{id}/reviews", h.FindByProductID)
+	r.With(am.VerifyToken).Post("/products/{id}/reviews", h.Create)
+
+	r.Route("/reviews", func(r chi.Router) {
+		r.With(am.VerifyToken, am.RequireAdmin).Get("/", h.FindByStatus)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.With(am.VerifyToken).Patch("/", h.Update)
+			r.With(am.VerifyToken, am.IdentifyAdmin).Delete("/", h.Delete)
+			r.With(am.VerifyToken, am.RequireAdmin).Put("/status", h.Moderate)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package reviews
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"ecommerce-service/internal/products"
+)
+
+var ErrAlreadyReviewed = errors.New("the user has already reviewed this product")
+
+type (
+	Repository interface {
+		Create(ctx context.Context, productID, userID int64, data CreateReviewRequest) (*Review, error)
+		FindByID(ctx context.Context, id int64) (*Review, error)
+		FindByProductID(ctx context.Context, productID int64, status string, limit, offset int) ([]Review, error)
+		CountByProductID(ctx context.Context, productID int64, status string) (int, error)
+		FindByStatus(ctx context.Context, status string, limit, offset int) ([]Review, error)
+		CountByStatus(ctx context.Context, status string) (int, error)
+		Update(ctx context.Context, id, userID int64, data UpdateReviewRequest) (*Review, error)
+		SetStatus(ctx context.Context, id int64, status string, moderatorID int64) (*Review, error)
+		Delete(ctx context.Context, id int64, userID *int64) error
+	}
+
+	// ProductFinder checks that the reviewed product exists and is on sale.
+	ProductFinder interface {
+		FindByID(ctx context.Context, id int) (*products.Product, error)
+	}
+
+	ReviewService struct {
+		reviewRepo    Repository
+		productFinder ProductFinder
+	}
+)
+
+func NewReviewService(reviewRepo Repository, productFinder ProductFinder) *ReviewService {
+	return &ReviewService{reviewRepo: reviewRepo, productFinder: productFinder}
+}
+
+// Create saves the review of the user, which waits for moderation. Only active products can be
+// reviewed.
+func (s *ReviewService) Create(ctx context.Context, productID, userID int64, data CreateReviewRequest) (*Review, error) {
+	if err := s.findActiveProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+	return s.reviewRepo.Create(ctx, productID, userID, data)
+}
+
+// FindByProductID returns a page of the approved reviews of an active product.
+func (s *ReviewService) FindByProductID(ctx context.Context, productID int64, page, limit int) ([]Review, error) {
+	if err := s.findActiveProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+	offset := (page - 1) * limit
+	return s.reviewRepo.FindByProductID(ctx, productID, StatusApproved, limit, offset)
+}
+
+func (s *ReviewService) CountByProductID(ctx context.Context, productID int64) (int, error) {
+	return s.reviewRepo.CountByProductID(ctx, productID, StatusApproved)
+}
+
+// FindByStatus returns a page of the moderation queue of the status.
+func (s *ReviewService) FindByStatus(ctx context.Context, status string, page, limit int) ([]Review, error) {
+	offset := (page - 1) * limit
+	return s.reviewRepo.FindByStatus(ctx, status, limit, offset)
+}
+
+func (s *ReviewService) CountByStatus(ctx context.Context, status string) (int, error) {
+	return s.reviewRepo.CountByStatus(ctx, status)
+}
+
+// Update edits a review of the user, which goes back to moderation.
+func (s *ReviewService) Update(ctx context.Context, id, userID int64, data UpdateReviewRequest) (*Review, error) {
+	return s.reviewRepo.Update(ctx, id, userID, data)
+}
+
+// Moderate approves or rejects a review on behalf of the admin.
+func (s *ReviewService) Moderate(ctx context.Context, id int64, status string, moderatorID int64) (*Review, error) {
+	return s.reviewRepo.SetStatus(ctx, id, status, moderatorID)
+}
+
+// Delete removes a review. A nil userID lets an admin delete the review of any user.
+func (s *ReviewService) Delete(ctx context.Context, id int64, userID *int64) error {
+	return s.reviewRepo.Delete(ctx, id, userID)
+}
+
+func (s *ReviewService) findActiveProduct(ctx context.Context, productID int64) error {
+	product, err := s.productFinder.FindByID(ctx, int(productID))
+	if err != nil {
+		return err
+	}
+	if product.Status != products.StatusActive {
+		return sql.ErrNoRows
+	}
+	return nil
+}
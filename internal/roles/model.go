@@ -1,6 +1,13 @@
 // Package roles defines the data models related to user roles and permissions.
 package roles
 
+// Names of the roles seeded by the roles migration.
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+)
+
 type Role struct {
 	ID   int
 	Name string
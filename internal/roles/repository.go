@@ -36,6 +36,18 @@ func (r *RoleRepository) FindByName(ctx context.Context, name string) (*Role, er
 	return &role, nil
 }
 
+// FindByUserID returns the role assigned to the user.
+func (r *RoleRepository) FindByUserID(ctx context.Context, userID int64) (*Role, error) {
+	query := "SELECT r.id, r.name FROM roles r JOIN users u ON u.role_id = r.id WHERE u.id = $1"
+	row := r.db.QueryRowContext(ctx, query, userID)
+	var role Role
+	if err := row.Scan(&role.ID, &role.Name); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
 func (r *RoleRepository) FindAll(ctx context.Context) ([]Role, error) {
 	query := "SELECT id, name FROM roles"
 	rows, err := r.db.QueryContext(ctx, query)
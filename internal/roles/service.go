@@ -6,6 +6,7 @@ type (
 	Repository interface {
 		FindByID(ctx context.Context, id int) (*Role, error)
 		FindByName(ctx context.Context, name string) (*Role, error)
+		FindByUserID(ctx context.Context, userID int64) (*Role, error)
 		FindAll(ctx context.Context) ([]Role, error)
 	}
 
@@ -26,6 +27,10 @@ func (s *RoleService) FindByName(ctx context.Context, name string) (*Role, error
 	return s.roleRepo.FindByName(ctx, name)
 }
 
+func (s *RoleService) FindByUserID(ctx context.Context, userID int64) (*Role, error) {
+	return s.roleRepo.FindByUserID(ctx, userID)
+}
+
 func (s *RoleService) FindAll(ctx context.Context) ([]Role, error) {
 	return s.roleRepo.FindAll(ctx)
 }
@@ -0,0 +1,164 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		CreateZone(ctx context.Context, z *CreateZoneRequest) error
+		FindAllZones(ctx context.Context) ([]Zone, error)
+		DeleteZone(ctx context.Context, id int) error
+		FindAllMethods(ctx context.Context) ([]Method, error)
+		CreateMethod(ctx context.Context, m *CreateMethodRequest) error
+		UpdateMethod(ctx context.Context, id int, m UpdateMethodRequest) error
+		DeleteMethod(ctx context.Context, id int) error
+	}
+
+	ShippingHandler struct {
+		shippingService Service
+		validate        *validator.Validate
+	}
+)
+
+func NewShippingHandler(shippingService Service, validate *validator.Validate) *ShippingHandler {
+	return &ShippingHandler{shippingService: shippingService, validate: validate}
+}
+
+func (h *ShippingHandler) FindAllZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.shippingService.FindAllZones(r.Context())
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if zones == nil {
+		zones = []Zone{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, zones)
+}
+
+func (h *ShippingHandler) CreateZone(w http.ResponseWriter, r *http.Request) {
+	req := CreateZoneRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.shippingService.CreateZone(r.Context(), &req); err != nil {
+		httpx.HTTPError(w, http.StatusConflict, httpx.ConflictError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, map[string]string{"message": httpx.CreatedResponse})
+}
+
+func (h *ShippingHandler) DeleteZone(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.shippingService.DeleteZone(r.Context(), id); err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+func (h *ShippingHandler) FindAllMethods(w http.ResponseWriter, r *http.Request) {
+	methods, err := h.shippingService.FindAllMethods(r.Context())
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	if methods == nil {
+		methods = []Method{}
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, methods)
+}
+
+func (h *ShippingHandler) CreateMethod(w http.ResponseWriter, r *http.Request) {
+	req := CreateMethodRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.shippingService.CreateMethod(r.Context(), &req); err != nil {
+		httpx.HTTPError(w, http.StatusConflict, httpx.ConflictError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, map[string]string{"message": httpx.CreatedResponse})
+}
+
+func (h *ShippingHandler) UpdateMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateMethodRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req == (UpdateMethodRequest{}) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	if err := h.shippingService.UpdateMethod(r.Context(), id, req); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.UpdatedResponse})
+}
+
+func (h *ShippingHandler) DeleteMethod(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.shippingService.DeleteMethod(r.Context(), id); err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
@@ -0,0 +1,97 @@
+// Package shipping defines shipping zones, methods and the rate quotes offered at checkout.
+package shipping
+
+import "time"
+
+const (
+	TypeFlatRate          = "flat_rate"           // BaseRate regardless of the parcel
+	TypeWeightBased       = "weight_based"        // BaseRate plus PerKgRate for every started kilogram
+	TypeFreeOverThreshold = "free_over_threshold" // BaseRate, free when the subtotal reaches FreeThreshold
+)
+
+// VolumetricDivisor converts cm³ into volumetric grams (L x W x H / 5000 kg).
+const VolumetricDivisor = 5
+
+// Zone groups the destination countries that share shipping methods.
+type Zone struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Countries []string   `json:"countries"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Method is a way of delivering a parcel to a zone. Rates are in cents.
+type Method struct {
+	ID              int        `json:"id"`
+	ZoneID          int        `json:"zone_id"`
+	Name            string     `json:"name"`
+	Carrier         string     `json:"carrier,omitempty"`
+	Type            string     `json:"type"`
+	BaseRate        int64      `json:"base_rate"`
+	PerKgRate       int64      `json:"per_kg_rate,omitempty"`
+	FreeThreshold   int64      `json:"free_threshold,omitempty"`
+	MaxWeightGrams  *int       `json:"max_weight_grams,omitempty"`
+	MinDeliveryDays int        `json:"min_delivery_days"`
+	MaxDeliveryDays int        `json:"max_delivery_days"`
+	Active          bool       `json:"active"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+type CreateZoneRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	Countries []string `json:"countries" validate:"required,min=1,dive,len=2,uppercase"`
+}
+
+type CreateMethodRequest struct {
+	ZoneID          int    `json:"zone_id" validate:"required"`
+	Name            string `json:"name" validate:"required"`
+	Carrier         string `json:"carrier"`
+	Type            string `json:"type" validate:"required,oneof=flat_rate weight_based free_over_threshold"`
+	BaseRate        int64  `json:"base_rate" validate:"gte=0"`
+	PerKgRate       int64  `json:"per_kg_rate" validate:"gte=0"`
+	FreeThreshold   int64  `json:"free_threshold" validate:"required_if=Type free_over_threshold,gte=0"`
+	MaxWeightGrams  *int   `json:"max_weight_grams" validate:"omitempty,gt=0"`
+	MinDeliveryDays int    `json:"min_delivery_days" validate:"gte=0"`
+	MaxDeliveryDays int    `json:"max_delivery_days" validate:"gtefield=MinDeliveryDays"`
+}
+
+type UpdateMethodRequest struct {
+	Name            *string `json:"name,omitempty"`
+	Carrier         *string `json:"carrier,omitempty"`
+	BaseRate        *int64  `json:"base_rate,omitempty" validate:"omitempty,gte=0"`
+	PerKgRate       *int64  `json:"per_kg_rate,omitempty" validate:"omitempty,gte=0"`
+	FreeThreshold   *int64  `json:"free_threshold,omitempty" validate:"omitempty,gte=0"`
+	MaxWeightGrams  *int    `json:"max_weight_grams,omitempty" validate:"omitempty,gt=0"`
+	MinDeliveryDays *int    `json:"min_delivery_days,omitempty" validate:"omitempty,gte=0"`
+	MaxDeliveryDays *int    `json:"max_delivery_days,omitempty" validate:"omitempty,gte=0"`
+	Active          *bool   `json:"active,omitempty"`
+}
+
+// Item is a cart line to be shipped.
+type Item struct {
+	ProductID int64
+	Quantity  int64
+}
+
+// Parcel is the physical description of the items of a cart.
+type Parcel struct {
+	WeightGrams           int64
+	VolumetricWeightGrams int64
+}
+
+// ChargeableWeight is the greater of the actual and the volumetric weight.
+func (p Parcel) ChargeableWeight() int64 {
+	return max(p.WeightGrams, p.VolumetricWeightGrams)
+}
+
+// Quote is the price of shipping a cart with a given method.
+type Quote struct {
+	MethodID        int    `json:"method_id"`
+	Name            string `json:"name"`
+	Carrier         string `json:"carrier,omitempty"`
+	Cost            int64  `json:"cost"`
+	MinDeliveryDays int    `json:"min_delivery_days"`
+	MaxDeliveryDays int    `json:"max_delivery_days"`
+}
@@ -0,0 +1,186 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const methodColumns = "id, zone_id, name, carrier, type, base_rate, per_kg_rate, free_threshold, max_weight_grams, min_delivery_days, max_delivery_days, active, created_at, updated_at"
+
+type ShippingRepository struct {
+	db *sql.DB
+}
+
+func NewShippingRepository(db *sql.DB) *ShippingRepository {
+	return &ShippingRepository{db: db}
+}
+
+func (r *ShippingRepository) CreateZone(ctx context.Context, data CreateZoneRequest) error {
+	countries := make([]string, 0, len(data.Countries))
+	for _, c := range data.Countries {
+		countries = append(countries, strings.ToUpper(c))
+	}
+
+	query := "INSERT INTO shipping_zones (name, countries) VALUES ($1, $2)"
+	_, err := r.db.ExecContext(ctx, query, data.Name, pq.Array(countries))
+	return err
+}
+
+func (r *ShippingRepository) FindAllZones(ctx context.Context) ([]Zone, error) {
+	query := "SELECT id, name, countries, created_at, updated_at FROM shipping_zones ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var zones []Zone
+	for rows.Next() {
+		var z Zone
+		if err := rows.Scan(&z.ID, &z.Name, pq.Array(&z.Countries), &z.CreatedAt, &z.UpdatedAt); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+
+	return zones, rows.Err()
+}
+
+func (r *ShippingRepository) DeleteZone(ctx context.Context, id int) error {
+	query := "DELETE FROM shipping_zones WHERE id = $1"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *ShippingRepository) queryMethods(ctx context.Context, query string, args ...any) ([]Method, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var methods []Method
+	for rows.Next() {
+		var m Method
+		if err := rows.Scan(&m.ID, &m.ZoneID, &m.Name, &m.Carrier, &m.Type, &m.BaseRate, &m.PerKgRate, &m.FreeThreshold, &m.MaxWeightGrams, &m.MinDeliveryDays, &m.MaxDeliveryDays, &m.Active, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+
+	return methods, rows.Err()
+}
+
+func (r *ShippingRepository) FindAllMethods(ctx context.Context) ([]Method, error) {
+	query := "SELECT " + methodColumns + " FROM shipping_methods ORDER BY zone_id, id"
+	return r.queryMethods(ctx, query)
+}
+
+// FindMethodsByCountry returns the active methods of every zone that contains the country.
+func (r *ShippingRepository) FindMethodsByCountry(ctx context.Context, country string) ([]Method, error) {
+	query := "SELECT m." + strings.ReplaceAll(methodColumns, ", ", ", m.") + " FROM shipping_methods m JOIN shipping_zones z ON z.id = m.zone_id WHERE m.active AND UPPER($1) = ANY(z.countries) ORDER BY m.base_rate, m.id"
+	return r.queryMethods(ctx, query, country)
+}
+
+func (r *ShippingRepository) CreateMethod(ctx context.Context, data CreateMethodRequest) error {
+	query := "INSERT INTO shipping_methods (zone_id, name, carrier, type, base_rate, per_kg_rate, free_threshold, max_weight_grams, min_delivery_days, max_delivery_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	_, err := r.db.ExecContext(ctx, query, data.ZoneID, data.Name, data.Carrier, data.Type, data.BaseRate, data.PerKgRate, data.FreeThreshold, data.MaxWeightGrams, data.MinDeliveryDays, data.MaxDeliveryDays)
+	return err
+}
+
+func (r *ShippingRepository) UpdateMethod(ctx context.Context, id int, m UpdateMethodRequest) error {
+	fields := []string{}
+	args := []any{}
+	i := 1
+
+	for _, c := range []struct {
+		name  string
+		value any
+		set   bool
+	}{
+		{"name", m.Name, m.Name != nil},
+		{"carrier", m.Carrier, m.Carrier != nil},
+		{"base_rate", m.BaseRate, m.BaseRate != nil},
+		{"per_kg_rate", m.PerKgRate, m.PerKgRate != nil},
+		{"free_threshold", m.FreeThreshold, m.FreeThreshold != nil},
+		{"max_weight_grams", m.MaxWeightGrams, m.MaxWeightGrams != nil},
+		{"min_delivery_days", m.MinDeliveryDays, m.MinDeliveryDays != nil},
+		{"max_delivery_days", m.MaxDeliveryDays, m.MaxDeliveryDays != nil},
+		{"active", m.Active, m.Active != nil},
+	} {
+		if c.set {
+			fields = append(fields, fmt.Sprintf("%s = $%d", c.name, i))
+			args = append(args, c.value)
+			i++
+		}
+	}
+
+	fields = append(fields, fmt.Sprintf("updated_at = $%d", i))
+	args = append(args, time.Now())
+	i++
+
+	query := fmt.Sprintf("UPDATE shipping_methods SET %s WHERE id = $%d", strings.Join(fields, ", "), i)
+	args = append(args, id)
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *ShippingRepository) DeleteMethod(ctx context.Context, id int) error {
+	query := "DELETE FROM shipping_methods WHERE id = $1"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// FindParcel adds up the weight and volume of the products in the given quantities.
+func (r *ShippingRepository) FindParcel(ctx context.Context, items []Item) (*Parcel, error) {
+	productIDs := make([]int64, 0, len(items))
+	quantities := make([]int64, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+		quantities = append(quantities, item.Quantity)
+	}
+
+	query := `SELECT COALESCE(SUM(p.weight_grams * i.quantity), 0), COALESCE(SUM(p.length_cm * p.width_cm * p.height_cm * i.quantity), 0)
+		FROM UNNEST($1::BIGINT[], $2::BIGINT[]) AS i(product_id, quantity)
+		JOIN products p ON p.id = i.product_id`
+
+	var weight int64
+	var volume float64
+	if err := r.db.QueryRowContext(ctx, query, pq.Array(productIDs), pq.Array(quantities)).Scan(&weight, &volume); err != nil {
+		return nil, err
+	}
+
+	return &Parcel{
+		WeightGrams:           weight,
+		VolumetricWeightGrams: int64(math.Ceil(volume / VolumetricDivisor)),
+	}, nil
+}
@@ -0,0 +1,25 @@
+package shipping
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *ShippingHandler, am *auth.AuthMiddleware) {
+	r.Route("/shipping", func(r chi.Router) {
+		r.Get("/zones", h.FindAllZones)
+		r.Get("/methods", h.FindAllMethods)
+
+		r.Group(func(r chi.Router) {
+			r.Use(am.VerifyToken, am.RequireAdmin)
+
+			r.Post("/zones", h.CreateZone)
+			r.Delete("/zones/{id}", h.DeleteZone)
+
+			r.Post("/methods", h.CreateMethod)
+			r.Patch("/methods/{id}", h.UpdateMethod)
+			r.Delete("/methods/{id}", h.DeleteMethod)
+		})
+	})
+}
@@ -0,0 +1,128 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+var ErrMethodUnavailable = errors.New("the shipping method is not available for this destination")
+
+type (
+	Repository interface {
+		CreateZone(ctx context.Context, data CreateZoneRequest) error
+		FindAllZones(ctx context.Context) ([]Zone, error)
+		DeleteZone(ctx context.Context, id int) error
+		FindAllMethods(ctx context.Context) ([]Method, error)
+		FindMethodsByCountry(ctx context.Context, country string) ([]Method, error)
+		CreateMethod(ctx context.Context, data CreateMethodRequest) error
+		UpdateMethod(ctx context.Context, id int, data UpdateMethodRequest) error
+		DeleteMethod(ctx context.Context, id int) error
+		FindParcel(ctx context.Context, items []Item) (*Parcel, error)
+	}
+
+	ShippingService struct {
+		shippingRepo Repository
+	}
+)
+
+func NewShippingService(shippingRepo Repository) *ShippingService {
+	return &ShippingService{shippingRepo: shippingRepo}
+}
+
+func (s *ShippingService) CreateZone(ctx context.Context, z *CreateZoneRequest) error {
+	return s.shippingRepo.CreateZone(ctx, *z)
+}
+
+func (s *ShippingService) FindAllZones(ctx context.Context) ([]Zone, error) {
+	return s.shippingRepo.FindAllZones(ctx)
+}
+
+func (s *ShippingService) DeleteZone(ctx context.Context, id int) error {
+	return s.shippingRepo.DeleteZone(ctx, id)
+}
+
+func (s *ShippingService) FindAllMethods(ctx context.Context) ([]Method, error) {
+	return s.shippingRepo.FindAllMethods(ctx)
+}
+
+func (s *ShippingService) CreateMethod(ctx context.Context, m *CreateMethodRequest) error {
+	return s.shippingRepo.CreateMethod(ctx, *m)
+}
+
+func (s *ShippingService) UpdateMethod(ctx context.Context, id int, m UpdateMethodRequest) error {
+	return s.shippingRepo.UpdateMethod(ctx, id, m)
+}
+
+func (s *ShippingService) DeleteMethod(ctx context.Context, id int) error {
+	return s.shippingRepo.DeleteMethod(ctx, id)
+}
+
+// Quote returns the cost of every method that can deliver the items to the country, cheapest first.
+// Subtotal is the amount compared against free shipping thresholds; freeShipping comes from promotions.
+func (s *ShippingService) Quote(ctx context.Context, country string, items []Item, subtotal int64, freeShipping bool) ([]Quote, error) {
+	methods, err := s.shippingRepo.FindMethodsByCountry(ctx, country)
+	if err != nil {
+		return nil, err
+	}
+
+	parcel, err := s.shippingRepo.FindParcel(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	weight := parcel.ChargeableWeight()
+
+	quotes := []Quote{}
+	for _, m := range methods {
+		if m.MaxWeightGrams != nil && weight > int64(*m.MaxWeightGrams) {
+			continue
+		}
+
+		cost := rate(m, weight, subtotal)
+		if freeShipping {
+			cost = 0
+		}
+
+		quotes = append(quotes, Quote{
+			MethodID:        m.ID,
+			Name:            m.Name,
+			Carrier:         m.Carrier,
+			Cost:            cost,
+			MinDeliveryDays: m.MinDeliveryDays,
+			MaxDeliveryDays: m.MaxDeliveryDays,
+		})
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Cost < quotes[j].Cost })
+
+	return quotes, nil
+}
+
+// QuoteMethod returns the quote of a specific method, or ErrMethodUnavailable.
+func (s *ShippingService) QuoteMethod(ctx context.Context, methodID int, country string, items []Item, subtotal int64, freeShipping bool) (*Quote, error) {
+	quotes, err := s.Quote(ctx, country, items, subtotal, freeShipping)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range quotes {
+		if q.MethodID == methodID {
+			return &q, nil
+		}
+	}
+	return nil, ErrMethodUnavailable
+}
+
+func rate(m Method, weightGrams, subtotal int64) int64 {
+	switch m.Type {
+	case TypeWeightBased:
+		kilograms := (weightGrams + 999) / 1000
+		return m.BaseRate + kilograms*m.PerKgRate
+	case TypeFreeOverThreshold:
+		if subtotal >= m.FreeThreshold {
+			return 0
+		}
+		return m.BaseRate
+	}
+	return m.BaseRate
+}
@@ -0,0 +1,54 @@
+// Package taxes calculates the taxes owed on cart and order lines.
+package taxes
+
+import "time"
+
+// DefaultTaxCategory is the tax category assigned to products unless stated otherwise.
+const DefaultTaxCategory = "standard"
+
+// TaxRate is a row of the rates table. An empty Region or TaxCategory matches any value.
+type TaxRate struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Country     string     `json:"country"`
+	Region      string     `json:"region"`
+	TaxCategory string     `json:"tax_category"`
+	Rate        float64    `json:"rate"`      // e.g. 0.21 for 21%
+	Inclusive   bool       `json:"inclusive"` // Prices already include the tax
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Jurisdiction identifies where the goods are delivered.
+type Jurisdiction struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+// Line is a taxable line. Amount is in cents and already net of discounts.
+type Line struct {
+	ProductID   int64
+	VariantID   *int64
+	TaxCategory string
+	Amount      int64
+}
+
+// TaxLine is the tax calculated for a single line.
+type TaxLine struct {
+	ProductID     int64   `json:"product_id"`
+	VariantID     *int64  `json:"variant_id,omitempty"`
+	Name          string  `json:"name"`
+	Country       string  `json:"country"`
+	Region        string  `json:"region,omitempty"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount int64   `json:"taxable_amount"`
+	Amount        int64   `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+}
+
+// Result aggregates the tax lines of a calculation.
+type Result struct {
+	Lines []TaxLine
+	Total int64 // Every tax amount, inclusive and exclusive
+	Added int64 // Exclusive tax amounts, to be added on top of prices
+}
@@ -0,0 +1,40 @@
+package taxes
+
+import (
+	"context"
+	"database/sql"
+	"log"
+)
+
+type TaxRateRepository struct {
+	db *sql.DB
+}
+
+func NewTaxRateRepository(db *sql.DB) *TaxRateRepository {
+	return &TaxRateRepository{db: db}
+}
+
+// FindByCountry returns every rate defined for the country.
+func (r *TaxRateRepository) FindByCountry(ctx context.Context, country string) ([]TaxRate, error) {
+	query := "SELECT id, name, country, region, tax_category, rate, inclusive, created_at, updated_at FROM tax_rates WHERE country = UPPER($1)"
+	rows, err := r.db.QueryContext(ctx, query, country)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var rates []TaxRate
+	for rows.Next() {
+		var t TaxRate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Country, &t.Region, &t.TaxCategory, &t.Rate, &t.Inclusive, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, t)
+	}
+
+	return rates, rows.Err()
+}
@@ -0,0 +1,102 @@
+package taxes
+
+import (
+	"context"
+	"math"
+	"strings"
+)
+
+type (
+	// Calculator is implemented by every tax provider, built-in or external.
+	Calculator interface {
+		Calculate(ctx context.Context, jurisdiction Jurisdiction, lines []Line) (*Result, error)
+	}
+
+	Repository interface {
+		FindByCountry(ctx context.Context, country string) ([]TaxRate, error)
+	}
+
+	// TableCalculator resolves rates from the tax_rates table.
+	TableCalculator struct {
+		taxRateRepo Repository
+	}
+)
+
+func NewTableCalculator(taxRateRepo Repository) *TableCalculator {
+	return &TableCalculator{taxRateRepo: taxRateRepo}
+}
+
+// Calculate applies the most specific rate of the jurisdiction to every line.
+// Lines without a matching rate are not taxed.
+func (c *TableCalculator) Calculate(ctx context.Context, jurisdiction Jurisdiction, lines []Line) (*Result, error) {
+	result := &Result{Lines: []TaxLine{}}
+	if jurisdiction.Country == "" || len(lines) == 0 {
+		return result, nil
+	}
+
+	rates, err := c.taxRateRepo.FindByCountry(ctx, jurisdiction.Country)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		category := l.TaxCategory
+		if category == "" {
+			category = DefaultTaxCategory
+		}
+
+		rate := matchRate(rates, jurisdiction.Region, category)
+		if rate == nil {
+			continue
+		}
+
+		var amount int64
+		if rate.Inclusive {
+			amount = l.Amount - int64(math.Round(float64(l.Amount)/(1+rate.Rate)))
+		} else {
+			amount = int64(math.Round(float64(l.Amount) * rate.Rate))
+			result.Added += amount
+		}
+		result.Total += amount
+
+		result.Lines = append(result.Lines, TaxLine{
+			ProductID:     l.ProductID,
+			VariantID:     l.VariantID,
+			Name:          rate.Name,
+			Country:       rate.Country,
+			Region:        rate.Region,
+			Rate:          rate.Rate,
+			TaxableAmount: l.Amount,
+			Amount:        amount,
+			Inclusive:     rate.Inclusive,
+		})
+	}
+
+	return result, nil
+}
+
+// matchRate picks the rate matching region and category, preferring the most specific one.
+func matchRate(rates []TaxRate, region, category string) *TaxRate {
+	var best *TaxRate
+	bestScore := -1
+	for i := range rates {
+		r := &rates[i]
+		score := 0
+		if r.Region != "" {
+			if !strings.EqualFold(r.Region, region) {
+				continue
+			}
+			score += 2
+		}
+		if r.TaxCategory != "" {
+			if !strings.EqualFold(r.TaxCategory, category) {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
@@ -56,6 +56,16 @@ func (ts *TokenService) GenerateTokens(userID int) (accessToken, refreshToken st
 	return accessToken, refreshToken, nil
 }
 
+// VerifyToken is the method form of VerifyToken, so TokenService can be injected where a verifier is expected.
+func (ts *TokenService) VerifyToken(tokenStr string, secret string) (*jwt.Token, error) {
+	return VerifyToken(tokenStr, secret)
+}
+
+// ExtractClaims is the method form of ExtractClaims.
+func (ts *TokenService) ExtractClaims(token *jwt.Token) (jwt.MapClaims, error) {
+	return ExtractClaims(token)
+}
+
 func VerifyToken(tokenStr string, secret string) (*jwt.Token, error) {
 	return jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		return []byte(secret), nil
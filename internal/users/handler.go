@@ -3,6 +3,7 @@ package users
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -18,6 +19,7 @@ type Service interface {
 	Create(ctx context.Context, u *CreateUserRequest) error
 	FindByID(ctx context.Context, id int) (*User, error)
 	FindAll(ctx context.Context, page, limit int) ([]User, error)
+	FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]User, *string, *string, error)
 	Update(ctx context.Context, id int, u UpdateUserRequest) error
 	Delete(ctx context.Context, id int) error
 	Count(ctx context.Context) (int, error)
@@ -79,8 +81,15 @@ func (uh *UserHandler) FindByID(w http.ResponseWriter, r *http.Request) {
 	httpx.HTTPResponse(w, http.StatusOK, &pu)
 }
 
+// FindAll lists users with page/limit, or with keyset pagination when ?cursor is present
+// (empty for the first page).
 func (uh *UserHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if r.URL.Query().Has("cursor") {
+		uh.findPage(w, r)
+		return
+	}
+
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
 
@@ -104,6 +113,32 @@ func (uh *UserHandler) FindAll(w http.ResponseWriter, r *http.Request) {
 	httpx.HTTPPaginatedResponse(w, http.StatusOK, &publicUsers, page, limit, total)
 }
 
+func (uh *UserHandler) findPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	c, limit, err := utils.ParseCursorParams(r.URL.Query().Get("cursor"), r.URL.Query().Get("limit"), "", uh.config.Limit, uh.config.MaxLimit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, next, prev, err := uh.userService.FindPage(ctx, c, limit)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	publicUsers := []PublicUser{}
+	for _, u := range users {
+		publicUsers = append(publicUsers, PublicUser{u.ID, u.Email, u.CreatedAt, u.UpdatedAt})
+	}
+
+	httpx.HTTPCursorResponse(w, http.StatusOK, publicUsers, next, prev)
+}
+
 func (uh *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	idStr := chi.URLParam(r, "id")
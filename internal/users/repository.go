@@ -7,6 +7,9 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"ecommerce-service/internal/events"
+	"ecommerce-service/internal/utils"
 )
 
 type UserRepository struct {
@@ -17,12 +20,31 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// Create inserts the user and records the UserRegistered event with it.
 func (r *UserRepository) Create(ctx context.Context, u *CreateUserRequest) error {
-	query := "INSERT INTO users (email, password, role_id) VALUES ($1, $2)"
-	_, err := r.db.ExecContext(ctx, query, u.Email, u.Password, u.RoleID)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int64
+	query := "INSERT INTO users (email, password, role_id) VALUES ($1, $2, $3) RETURNING id"
+	if err = tx.QueryRowContext(ctx, query, u.Email, u.Password, u.RoleID).Scan(&id); err != nil {
 		return err
 	}
+
+	if err = events.Record(ctx, tx, events.AggregateUser, id, events.UserRegistered, events.UserRegisteredData{UserID: id, Email: u.Email}); err != nil {
+		return fmt.Errorf("error recording user event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
 	return nil
 }
 
@@ -76,6 +98,50 @@ func (r *UserRepository) FindAll(ctx context.Context, limit, offset int) ([]User
 	return users, nil
 }
 
+// userKeyset orders users by ID for keyset pagination, like the offset listing.
+var userKeyset = utils.Keyset{Columns: []string{"id"}}
+
+// FindPage returns up to limit users past the cursor, in the direction of the cursor.
+func (r *UserRepository) FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]User, error) {
+	args := []any{}
+	bind := func(arg any) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	cond, order, err := userKeyset.Clause(c, bind)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, email, password, role_id, created_at, updated_at FROM users"
+	if cond != "" {
+		query += " WHERE " + cond
+	}
+	query += " ORDER BY " + order + " LIMIT " + bind(limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Password, &u.RoleID, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
 func (r *UserRepository) Update(ctx context.Context, id int, u UpdateUserRequest) error {
 	fields := []string{}
 	args := []any{}
@@ -1,10 +1,14 @@
 package users
 
-import "github.com/go-chi/chi/v5"
+import (
+	"ecommerce-service/internal/idempotency"
 
-func RegisterRoutes(r chi.Router, uh *UserHandler) {
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, uh *UserHandler, im *idempotency.IdempotencyMiddleware) {
 	r.Route("/users", func(r chi.Router) {
-		r.Post("/", uh.Create)
+		r.With(im.Handle).Post("/", uh.Create)
 		r.Get("/", uh.FindAll)
 		r.Get("/{id}", uh.FindByID)
 		r.Patch("/{id}", uh.Update)
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/utils"
 	"ecommerce-service/pkg/cryptox"
 )
 
@@ -11,6 +12,7 @@ type Repository interface {
 	Create(ctx context.Context, u *CreateUserRequest) error
 	FindByID(ctx context.Context, id int) (*User, error)
 	FindAll(ctx context.Context, page, offset int) ([]User, error)
+	FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	Update(ctx context.Context, id int, u UpdateUserRequest) error
 	Delete(ctx context.Context, id int) error
@@ -49,6 +51,19 @@ func (us *UserService) FindAll(ctx context.Context, page, limit int) ([]User, er
 	return us.userRepo.FindAll(ctx, limit, offset)
 }
 
+// FindPage returns a keyset-paginated page of users with the cursors of the next and previous pages.
+func (us *UserService) FindPage(ctx context.Context, c *utils.Cursor, limit int) ([]User, *string, *string, error) {
+	users, err := us.userRepo.FindPage(ctx, c, limit+1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	users, next, prev := utils.CursorPage(users, limit, c, "", func(u User) ([]string, int64) {
+		return nil, int64(u.ID)
+	})
+	return users, next, prev, nil
+}
+
 func (us *UserService) Update(ctx context.Context, id int, u UpdateUserRequest) error {
 	if u.Password != nil {
 		hashPassword, err := cryptox.HashPassword(*u.Password, us.config.BcryptCost)
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("the cursor is invalid or was issued for another sort order")
+
+// Cursor is the decoded form of the opaque cursors of keyset pagination. It holds the sort key of
+// the row the page starts after (or before, when Prev is set) and the sort it was issued for.
+type Cursor struct {
+	Sort string   `json:"s,omitempty"`
+	Keys []string `json:"k,omitempty"` // Values of the sort columns of the row, except the ID
+	ID   int64    `json:"id"`
+	Prev bool     `json:"p,omitempty"`
+}
+
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ParseCursorParams parses the cursor and limit of a keyset-paginated request. An empty cursor
+// requests the first page and returns a nil cursor. The cursor must have been issued for sort.
+func ParseCursorParams(cursorStr, limitStr, sort string, defaultLimit, maxLimit int) (*Cursor, int, error) {
+	_, limit := ParsePaginationParams("", limitStr, defaultLimit, maxLimit)
+	if cursorStr == "" {
+		return nil, limit, nil
+	}
+
+	c, err := DecodeCursor(cursorStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Sort != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+	return c, limit, nil
+}
+
+// Keyset describes the order of a keyset-paginated query. Columns are SQL expressions, the last
+// one being a unique ID that breaks ties; all of them are sorted in the same direction.
+type Keyset struct {
+	Columns []string
+	Desc    bool
+}
+
+// Clause returns the condition selecting the rows past the cursor and the ORDER BY that reads them
+// in the direction of the cursor. bind adds a query argument and returns its placeholder.
+// Without a cursor the condition is empty and the rows are read from the start.
+func (k Keyset) Clause(c *Cursor, bind func(arg any) string) (cond, order string, err error) {
+	desc := k.Desc
+	if c != nil && c.Prev {
+		desc = !desc
+	}
+
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	order = strings.Join(k.Columns, dir+", ") + dir
+
+	if c == nil {
+		return "", order, nil
+	}
+	if len(c.Keys) != len(k.Columns)-1 {
+		return "", "", ErrInvalidCursor
+	}
+
+	values := make([]string, 0, len(k.Columns))
+	for _, key := range c.Keys {
+		values = append(values, bind(key))
+	}
+	values = append(values, bind(c.ID))
+
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	cond = fmt.Sprintf("(%s) %s (%s)", strings.Join(k.Columns, ", "), op, strings.Join(values, ", "))
+	return cond, order, nil
+}
+
+// CursorPage trims rows fetched with a limit of limit+1 to a page in display order, and returns the
+// cursors of the next and previous pages, nil at either end. key returns the sort keys and ID of a row.
+func CursorPage[T any](rows []T, limit int, c *Cursor, sort string, key func(T) ([]string, int64)) ([]T, *string, *string) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	backward := c != nil && c.Prev
+	if backward {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, nil, nil
+	}
+
+	cursorAt := func(row T, prev bool) *string {
+		keys, id := key(row)
+		s := EncodeCursor(Cursor{Sort: sort, Keys: keys, ID: id, Prev: prev})
+		return &s
+	}
+
+	var next, prev *string
+	if hasMore || backward {
+		next = cursorAt(rows[len(rows)-1], false)
+	}
+	if (hasMore && backward) || (c != nil && !backward) {
+		prev = cursorAt(rows[0], true)
+	}
+	return rows, next, prev
+}
+
+// FormatCursorFloat encodes a numeric sort key; bitSize is 32 for REAL columns such as ts_rank.
+func FormatCursorFloat(f float64, bitSize int) string {
+	return strconv.FormatFloat(f, 'f', -1, bitSize)
+}
+
+// FormatCursorTime encodes a timestamp sort key with the microsecond precision of Postgres.
+func FormatCursorTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.999999Z07:00")
+}
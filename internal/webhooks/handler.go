@@ -0,0 +1,235 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/utils"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		Create(ctx context.Context, data SubscriptionRequest) (*Subscription, error)
+		FindByID(ctx context.Context, id int64) (*Subscription, error)
+		FindAll(ctx context.Context, page, limit int) ([]Subscription, error)
+		Count(ctx context.Context) (int, error)
+		Update(ctx context.Context, id int64, data UpdateSubscriptionRequest) (*Subscription, error)
+		Delete(ctx context.Context, id int64) error
+		FindDeliveries(ctx context.Context, subscriptionID int64, status string, page, limit int) ([]Delivery, error)
+		CountDeliveries(ctx context.Context, subscriptionID int64, status string) (int, error)
+		FindDelivery(ctx context.Context, subscriptionID, id int64) (*Delivery, error)
+		Redeliver(ctx context.Context, subscriptionID, id int64) (*Delivery, error)
+	}
+
+	WebhookHandler struct {
+		webhookService Service
+		validate       *validator.Validate
+		config         *config.Config
+	}
+)
+
+func NewWebhookHandler(webhookService Service, validate *validator.Validate, config *config.Config) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService, validate: validate, config: config}
+}
+
+// Create adds a subscription. The response carries its secret, which is not shown again.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := SubscriptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	sub, err := h.webhookService.Create(ctx, req)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) FindAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	page, limit := utils.ParsePaginationParams(r.URL.Query().Get("page"), r.URL.Query().Get("limit"), h.config.Limit, h.config.MaxLimit)
+
+	total, err := h.webhookService.Count(ctx)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+	subs, err := h.webhookService.FindAll(ctx, page, limit)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, subs, page, limit, total)
+}
+
+func (h *WebhookHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	sub, err := h.webhookService.FindByID(ctx, id)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, sub)
+}
+
+// Update changes the URL, event types or secret of a subscription, or pauses and resumes it.
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := UpdateSubscriptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.URL == nil && req.EventTypes == nil && req.Secret == nil && req.Active == nil) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	sub, err := h.webhookService.Update(ctx, id, req)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.webhookService.Delete(ctx, id); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+// FindDeliveries lists the deliveries of a subscription, newest first, optionally with the status of ?status.
+func (h *WebhookHandler) FindDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if err := h.validate.Var(status, "omitempty,oneof=pending delivered dead"); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, map[string]string{"status": "the status field must be one of pending, delivered or dead"})
+		return
+	}
+
+	page, limit := utils.ParsePaginationParams(r.URL.Query().Get("page"), r.URL.Query().Get("limit"), h.config.Limit, h.config.MaxLimit)
+
+	deliveries, err := h.webhookService.FindDeliveries(ctx, id, status, page, limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	total, err := h.webhookService.CountDeliveries(ctx, id, status)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPPaginatedResponse(w, http.StatusOK, deliveries, page, limit, total)
+}
+
+// FindDelivery returns a delivery with the log of its attempts.
+func (h *WebhookHandler) FindDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, deliveryID, ok := deliveryParams(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.FindDelivery(ctx, id, deliveryID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, delivery)
+}
+
+// Redeliver sends a delivery again right away, including dead ones, and returns it with its log.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, deliveryID, ok := deliveryParams(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, delivery)
+}
+
+func deliveryParams(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return 0, 0, false
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return 0, 0, false
+	}
+	return id, deliveryID, true
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+	case errors.Is(err, ErrSubscriptionInactive):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	default:
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
@@ -0,0 +1,69 @@
+// Package webhooks notifies partners of domain events: they subscribe a URL to event types and get
+// signed deliveries, retried with backoff until they succeed or are dead-lettered.
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Delivery statuses. Pending deliveries are retried until they are delivered or run out of attempts
+// and go dead, from where only a manual redelivery brings them back.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// AllEvents subscribes to every event type.
+const AllEvents = "*"
+
+type Subscription struct {
+	ID         int64      `json:"id"`
+	URL        string     `json:"url"`
+	EventTypes []string   `json:"event_types"`
+	Secret     string     `json:"secret,omitempty"` // Only shown when the subscription is created
+	Active     bool       `json:"active"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+type Delivery struct {
+	ID               int64           `json:"id"`
+	SubscriptionID   int64           `json:"subscription_id"`
+	EventID          int64           `json:"event_id"`
+	EventType        string          `json:"event_type"`
+	Payload          json.RawMessage `json:"payload"` // The body that is posted
+	Status           string          `json:"status"`
+	Attempts         int             `json:"attempts"`
+	NextAttemptAt    *time.Time      `json:"next_attempt_at,omitempty"`
+	LastResponseCode *int            `json:"last_response_code,omitempty"`
+	DeliveredAt      *time.Time      `json:"delivered_at,omitempty"`
+	Log              []Attempt       `json:"log,omitempty"`
+	CreatedAt        *time.Time      `json:"created_at,omitempty"`
+}
+
+// Attempt is an entry of the delivery log.
+type Attempt struct {
+	ID           int64     `json:"id"`
+	DeliveryID   int64     `json:"delivery_id"`
+	ResponseCode *int      `json:"response_code,omitempty"` // Unset when no response came back
+	ResponseBody string    `json:"response_body,omitempty"` // Truncated
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int       `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+// SubscriptionRequest creates a subscription. Without a secret one is generated.
+type SubscriptionRequest struct {
+	URL        string   `json:"url" validate:"required,url,max=2000"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=* order.created order.status_changed user.registered product.stock_low"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=128"`
+}
+
+type UpdateSubscriptionRequest struct {
+	URL        *string  `json:"url" validate:"omitempty,url,max=2000"`
+	EventTypes []string `json:"event_types" validate:"omitempty,min=1,dive,oneof=* order.created order.status_changed user.registered product.stock_low"`
+	Secret     *string  `json:"secret" validate:"omitempty,min=16,max=128"`
+	Active     *bool    `json:"active"`
+}
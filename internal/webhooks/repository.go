@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"ecommerce-service/internal/events"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const subscriptionColumns = "id, url, event_types, secret, active, created_at, updated_at"
+
+func scanSubscription(s scanner) (*Subscription, error) {
+	var sub Subscription
+	if err := s.Scan(&sub.ID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, sub *Subscription) error {
+	query := "INSERT INTO webhook_subscriptions (url, event_types, secret) VALUES ($1, $2, $3) RETURNING id, active, created_at, updated_at"
+	return r.db.QueryRowContext(ctx, query, sub.URL, pq.Array(sub.EventTypes), sub.Secret).Scan(&sub.ID, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+}
+
+func (r *WebhookRepository) FindByID(ctx context.Context, id int64) (*Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM webhook_subscriptions WHERE id = $1"
+	return scanSubscription(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *WebhookRepository) FindAll(ctx context.Context, limit, offset int) ([]Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM webhook_subscriptions ORDER BY id LIMIT $1 OFFSET $2"
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r *WebhookRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_subscriptions").Scan(&count)
+	return count, err
+}
+
+// Update changes the fields set in the request.
+func (r *WebhookRepository) Update(ctx context.Context, id int64, data UpdateSubscriptionRequest) error {
+	query := `UPDATE webhook_subscriptions SET
+			url = COALESCE($2, url),
+			event_types = COALESCE($3, event_types),
+			secret = COALESCE($4, secret),
+			active = COALESCE($5, active),
+			updated_at = NOW()
+		WHERE id = $1`
+	res, err := r.db.ExecContext(ctx, query, id, data.URL, pq.Array(data.EventTypes), data.Secret, data.Active)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Delete removes the subscription with its deliveries and their log.
+func (r *WebhookRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Enqueue adds a pending delivery of the event for every active subscription to its type. An event
+// enqueued again adds nothing.
+func (r *WebhookRepository) Enqueue(ctx context.Context, e events.Event, body []byte) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2::TEXT, $3 FROM webhook_subscriptions
+		WHERE active AND ($2 = ANY(event_types) OR '*' = ANY(event_types))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	res, err := r.db.ExecContext(ctx, query, e.ID, e.Type, body)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+const deliveryColumns = "id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_response_code, delivered_at, created_at"
+
+func scanDelivery(s scanner) (*Delivery, error) {
+	var d Delivery
+	if err := s.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastResponseCode, &d.DeliveredAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *WebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Claim leases up to limit pending deliveries of active subscriptions that are due, so that other
+// workers skip them until the lease ends.
+func (r *WebhookRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+	return r.queryDeliveries(ctx, query, limit, lease.Seconds())
+}
+
+// FindDeliveries lists the deliveries of the subscription, newest first, with the status unless it
+// is empty.
+func (r *WebhookRepository) FindDeliveries(ctx context.Context, subscriptionID int64, status string, limit, offset int) ([]Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE subscription_id = $1 AND ($2 = '' OR status = $2) ORDER BY id DESC LIMIT $3 OFFSET $4"
+	return r.queryDeliveries(ctx, query, subscriptionID, status, limit, offset)
+}
+
+func (r *WebhookRepository) CountDeliveries(ctx context.Context, subscriptionID int64, status string) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = $1 AND ($2 = '' OR status = $2)"
+	err := r.db.QueryRowContext(ctx, query, subscriptionID, status).Scan(&count)
+	return count, err
+}
+
+// FindDelivery returns the delivery of the subscription with its log.
+func (r *WebhookRepository) FindDelivery(ctx context.Context, subscriptionID, id int64) (*Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2"
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, query, id, subscriptionID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, delivery_id, response_code, COALESCE(response_body, ''), COALESCE(error, ''), duration_ms, attempted_at FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	d.Log = []Attempt{}
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.ResponseCode, &a.ResponseBody, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		d.Log = append(d.Log, a)
+	}
+	return d, rows.Err()
+}
+
+// RecordAttempt adds the attempt to the log of the delivery and moves the delivery to the status,
+// to be retried at retryAt while it is pending.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, a *Attempt, status string, retryAt *time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := "INSERT INTO webhook_delivery_attempts (delivery_id, response_code, response_body, error, duration_ms) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5) RETURNING id, attempted_at"
+	if err = tx.QueryRowContext(ctx, query, a.DeliveryID, a.ResponseCode, a.ResponseBody, a.Error, a.DurationMs).Scan(&a.ID, &a.AttemptedAt); err != nil {
+		return err
+	}
+
+	query = `UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			last_response_code = $4,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $1`
+	if _, err = tx.ExecContext(ctx, query, a.DeliveryID, status, retryAt, a.ResponseCode); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// Reset puts the delivery back to pending with no attempts, leased for lease so that workers leave
+// it to the manual redelivery.
+func (r *WebhookRepository) Reset(ctx context.Context, subscriptionID, id int64, lease time.Duration) error {
+	query := "UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW() + $3 * INTERVAL '1 second', updated_at = NOW() WHERE id = $1 AND subscription_id = $2"
+	res, err := r.db.ExecContext(ctx, query, id, subscriptionID, lease.Seconds())
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// requireAffected returns sql.ErrNoRows when the statement changed no row.
+func requireAffected(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
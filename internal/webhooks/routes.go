@@ -0,0 +1,26 @@
+package webhooks
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *WebhookHandler, am *auth.AuthMiddleware) {
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(am.VerifyToken, am.RequireAdmin)
+
+		r.Get("/", h.FindAll)
+		r.Post("/", h.Create)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", h.FindByID)
+			r.Patch("/", h.Update)
+			r.Delete("/", h.Delete)
+
+			r.Get("/deliveries", h.FindDeliveries)
+			r.Get("/deliveries/{deliveryID}", h.FindDelivery)
+			r.Post("/deliveries/{deliveryID}/redeliver", h.Redeliver)
+		})
+	})
+}
@@ -0,0 +1,284 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-service/internal/config"
+	"ecommerce-service/internal/events"
+)
+
+var ErrSubscriptionInactive = errors.New("the subscription is paused, activate it to redeliver")
+
+const (
+	// batchSize is how many due deliveries a worker claims at a time.
+	batchSize = 50
+	// firstRetry and maxRetry bound the wait between attempts of a delivery.
+	firstRetry = 30 * time.Second
+	maxRetry   = 12 * time.Hour
+	// claimLease is how long other workers leave a claimed delivery alone.
+	claimLease = 5 * time.Minute
+	// maxLoggedBody caps the response body kept in the delivery log.
+	maxLoggedBody = 1 << 10
+)
+
+type (
+	Repository interface {
+		Create(ctx context.Context, sub *Subscription) error
+		FindByID(ctx context.Context, id int64) (*Subscription, error)
+		FindAll(ctx context.Context, limit, offset int) ([]Subscription, error)
+		Count(ctx context.Context) (int, error)
+		Update(ctx context.Context, id int64, data UpdateSubscriptionRequest) error
+		Delete(ctx context.Context, id int64) error
+		Enqueue(ctx context.Context, e events.Event, body []byte) (int64, error)
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+		FindDeliveries(ctx context.Context, subscriptionID int64, status string, limit, offset int) ([]Delivery, error)
+		CountDeliveries(ctx context.Context, subscriptionID int64, status string) (int, error)
+		FindDelivery(ctx context.Context, subscriptionID, id int64) (*Delivery, error)
+		RecordAttempt(ctx context.Context, a *Attempt, status string, retryAt *time.Time) error
+		Reset(ctx context.Context, subscriptionID, id int64, lease time.Duration) error
+	}
+
+	WebhookService struct {
+		webhookRepo Repository
+		client      *http.Client
+		config      *config.Config
+	}
+)
+
+func NewWebhookService(webhookRepo Repository, client *http.Client, c *config.Config) *WebhookService {
+	return &WebhookService{webhookRepo: webhookRepo, client: client, config: c}
+}
+
+// Create adds the subscription and returns it with its secret, which is not shown again.
+func (s *WebhookService) Create(ctx context.Context, data SubscriptionRequest) (*Subscription, error) {
+	sub := &Subscription{URL: data.URL, EventTypes: data.EventTypes, Secret: data.Secret}
+	if sub.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+
+	if err := s.webhookRepo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) FindByID(ctx context.Context, id int64) (*Subscription, error) {
+	sub, err := s.webhookRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+func (s *WebhookService) FindAll(ctx context.Context, page, limit int) ([]Subscription, error) {
+	offset := (page - 1) * limit
+	subs, err := s.webhookRepo.FindAll(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) Count(ctx context.Context) (int, error) {
+	return s.webhookRepo.Count(ctx)
+}
+
+func (s *WebhookService) Update(ctx context.Context, id int64, data UpdateSubscriptionRequest) (*Subscription, error) {
+	if err := s.webhookRepo.Update(ctx, id, data); err != nil {
+		return nil, err
+	}
+	return s.FindByID(ctx, id)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int64) error {
+	return s.webhookRepo.Delete(ctx, id)
+}
+
+// Enqueue adds a delivery of the event for every subscription to it. It is subscribed to the event
+// bus, so a failure makes the outbox publish the event again.
+func (s *WebhookService) Enqueue(ctx context.Context, e events.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.webhookRepo.Enqueue(ctx, e, body)
+	return err
+}
+
+// FindDeliveries lists the deliveries of the subscription, newest first, with the status unless it is empty.
+func (s *WebhookService) FindDeliveries(ctx context.Context, subscriptionID int64, status string, page, limit int) ([]Delivery, error) {
+	if _, err := s.webhookRepo.FindByID(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	offset := (page - 1) * limit
+	return s.webhookRepo.FindDeliveries(ctx, subscriptionID, status, limit, offset)
+}
+
+func (s *WebhookService) CountDeliveries(ctx context.Context, subscriptionID int64, status string) (int, error) {
+	return s.webhookRepo.CountDeliveries(ctx, subscriptionID, status)
+}
+
+func (s *WebhookService) FindDelivery(ctx context.Context, subscriptionID, id int64) (*Delivery, error) {
+	return s.webhookRepo.FindDelivery(ctx, subscriptionID, id)
+}
+
+// Redeliver sends the delivery again right away, whatever its status, with a fresh count of attempts.
+// It returns the delivery with its log. Deliveries of paused subscriptions are not sent.
+func (s *WebhookService) Redeliver(ctx context.Context, subscriptionID, id int64) (*Delivery, error) {
+	sub, err := s.webhookRepo.FindByID(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Active {
+		return nil, ErrSubscriptionInactive
+	}
+	if err := s.webhookRepo.Reset(ctx, subscriptionID, id, claimLease); err != nil {
+		return nil, err
+	}
+
+	d, err := s.webhookRepo.FindDelivery(ctx, subscriptionID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.deliver(ctx, sub, d); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.FindDelivery(ctx, subscriptionID, id)
+}
+
+// RunDeliveries sends the due deliveries every interval until ctx is done.
+func (s *WebhookService) RunDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.DeliverDue(ctx); err != nil {
+			log.Printf("error delivering webhooks: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends a batch of due deliveries and returns how many were attempted. Deliveries of
+// subscriptions deleted in the meantime are skipped.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.webhookRepo.Claim(ctx, batchSize, claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	attempted := 0
+	subs := map[int64]*Subscription{}
+	for i, d := range deliveries {
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			sub, err = s.webhookRepo.FindByID(ctx, d.SubscriptionID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return attempted, err
+			}
+			subs[d.SubscriptionID] = sub
+		}
+		if sub == nil {
+			continue
+		}
+		if err := s.deliver(ctx, sub, &deliveries[i]); err != nil {
+			return attempted, err
+		}
+		attempted++
+	}
+	return attempted, nil
+}
+
+// deliver posts the delivery to the subscription and logs the attempt. A 2xx response delivers it;
+// anything else schedules a retry with exponential backoff, or dead-letters it on the last attempt.
+// The error is only about recording the outcome.
+func (s *WebhookService) deliver(ctx context.Context, sub *Subscription, d *Delivery) error {
+	attempt := &Attempt{DeliveryID: d.ID}
+	start := time.Now()
+	code, body, err := s.post(ctx, sub, d)
+	attempt.DurationMs = int(time.Since(start).Milliseconds())
+	if code != 0 {
+		attempt.ResponseCode = &code
+		attempt.ResponseBody = body
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	if err == nil && code >= 200 && code < 300 {
+		return s.webhookRepo.RecordAttempt(ctx, attempt, StatusDelivered, nil)
+	}
+
+	if d.Attempts+1 >= max(s.config.WebhookMaxAttempts, 1) {
+		log.Printf("webhook delivery %d to %s is dead after %d attempts\n", d.ID, sub.URL, d.Attempts+1)
+		return s.webhookRepo.RecordAttempt(ctx, attempt, StatusDead, nil)
+	}
+	retryAt := time.Now().Add(retryBackoff(d.Attempts))
+	return s.webhookRepo.RecordAttempt(ctx, attempt, StatusPending, &retryAt)
+}
+
+// post sends the signed payload and returns the response code and the start of the response body.
+func (s *WebhookService) post(ctx context.Context, sub *Subscription, d *Delivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), d.Payload))
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxLoggedBody))
+	if err != nil {
+		return res.StatusCode, "", fmt.Errorf("reading the response: %w", err)
+	}
+	return res.StatusCode, strings.ToValidUTF8(strings.ReplaceAll(string(body), "\x00", ""), ""), nil
+}
+
+// retryBackoff returns the wait after the given number of failed attempts: 30s, 1m, 2m... up to maxRetry.
+func retryBackoff(attempts int) time.Duration {
+	if attempts >= 16 {
+		return maxRetry
+	}
+	return min(firstRetry<<attempts, maxRetry)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
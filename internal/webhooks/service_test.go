@@ -0,0 +1,210 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-service/internal/config"
+)
+
+// fakeRepository keeps the subscriptions in memory and records the outcome of every attempt.
+type fakeRepository struct {
+	Repository
+
+	subscriptions map[int64]*Subscription
+	due           []Delivery
+	attempts      []recordedAttempt
+}
+
+type recordedAttempt struct {
+	attempt Attempt
+	status  string
+	retryAt *time.Time
+}
+
+func (f *fakeRepository) FindByID(ctx context.Context, id int64) (*Subscription, error) {
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *sub
+	return &c, nil
+}
+
+func (f *fakeRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	return f.due, nil
+}
+
+func (f *fakeRepository) RecordAttempt(ctx context.Context, a *Attempt, status string, retryAt *time.Time) error {
+	f.attempts = append(f.attempts, recordedAttempt{attempt: *a, status: status, retryAt: retryAt})
+	return nil
+}
+
+func newTestService(t *testing.T, handler http.HandlerFunc, maxAttempts int) (*WebhookService, *fakeRepository) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	repo := &fakeRepository{subscriptions: map[int64]*Subscription{
+		1: {ID: 1, URL: server.URL, Secret: "whsec_test", Active: true},
+	}}
+	return NewWebhookService(repo, server.Client(), &config.Config{WebhookMaxAttempts: maxAttempts}), repo
+}
+
+func testDelivery(attempts int) *Delivery {
+	return &Delivery{ID: 7, SubscriptionID: 1, EventID: 42, EventType: "order.created", Payload: []byte(`{"id":42}`), Status: StatusPending, Attempts: attempts}
+}
+
+func TestDeliverSignsTheRequest(t *testing.T) {
+	service, repo := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"id":42}` {
+			t.Errorf("body = %s, want the payload", body)
+		}
+		if got := r.Header.Get(EventHeader); got != "order.created" {
+			t.Errorf("%s = %q, want order.created", EventHeader, got)
+		}
+		if got := r.Header.Get(DeliveryHeader); got != "7" {
+			t.Errorf("%s = %q, want 7", DeliveryHeader, got)
+		}
+
+		// The receiver side of the signature: t=<unix>,v1=<hex HMAC of "<unix>.<body>">
+		parts := strings.Split(r.Header.Get(SignatureHeader), ",")
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") || !strings.HasPrefix(parts[1], "v1=") {
+			t.Fatalf("malformed signature header %q", r.Header.Get(SignatureHeader))
+		}
+		ts := strings.TrimPrefix(parts[0], "t=")
+		if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)) > time.Minute {
+			t.Errorf("signature timestamp %q is not current", ts)
+		}
+		mac := hmac.New(sha256.New, []byte("whsec_test"))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		if want := hex.EncodeToString(mac.Sum(nil)); strings.TrimPrefix(parts[1], "v1=") != want {
+			t.Errorf("signature = %s, want %s", parts[1], want)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}, 3)
+
+	sub, _ := repo.FindByID(context.Background(), 1)
+	if err := service.deliver(context.Background(), sub, testDelivery(0)); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if len(repo.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(repo.attempts))
+	}
+	got := repo.attempts[0]
+	if got.status != StatusDelivered || got.retryAt != nil {
+		t.Errorf("status = %s, retry at %v, want delivered without retry", got.status, got.retryAt)
+	}
+	if got.attempt.ResponseCode == nil || *got.attempt.ResponseCode != http.StatusNoContent {
+		t.Errorf("response code = %v, want 204", got.attempt.ResponseCode)
+	}
+}
+
+func TestDeliverRetriesFailuresWithBackoff(t *testing.T) {
+	service, repo := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("try later"))
+	}, 8)
+
+	sub, _ := repo.FindByID(context.Background(), 1)
+	before := time.Now()
+	if err := service.deliver(context.Background(), sub, testDelivery(2)); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	got := repo.attempts[0]
+	if got.status != StatusPending || got.retryAt == nil {
+		t.Fatalf("status = %s, retry at %v, want pending with a retry", got.status, got.retryAt)
+	}
+	if wait := got.retryAt.Sub(before); wait < 2*time.Minute || wait > 2*time.Minute+5*time.Second {
+		t.Errorf("retry in %v after the third attempt, want 2m", wait)
+	}
+	if got.attempt.ResponseCode == nil || *got.attempt.ResponseCode != http.StatusServiceUnavailable || got.attempt.ResponseBody != "try later" {
+		t.Errorf("logged %v %q, want the 503 response", got.attempt.ResponseCode, got.attempt.ResponseBody)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{10, 8*time.Hour + 32*time.Minute},
+		{11, maxRetry},
+		{64, maxRetry},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverDeadLettersAtMaxAttempts(t *testing.T) {
+	service, repo := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, 3)
+
+	sub, _ := repo.FindByID(context.Background(), 1)
+	for attempts := range 3 {
+		if err := service.deliver(context.Background(), sub, testDelivery(attempts)); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+	}
+
+	for i, want := range []string{StatusPending, StatusPending, StatusDead} {
+		if got := repo.attempts[i].status; got != want {
+			t.Errorf("attempt %d: status = %s, want %s", i+1, got, want)
+		}
+	}
+	if repo.attempts[2].retryAt != nil {
+		t.Errorf("dead delivery scheduled for %v, want no retry", repo.attempts[2].retryAt)
+	}
+}
+
+func TestDeliverDueSkipsDeletedSubscriptions(t *testing.T) {
+	service, repo := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, 3)
+	orphan := testDelivery(0)
+	orphan.ID, orphan.SubscriptionID = 8, 99
+	repo.due = []Delivery{*orphan, *testDelivery(0)}
+
+	attempted, err := service.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if attempted != 1 || len(repo.attempts) != 1 || repo.attempts[0].attempt.DeliveryID != 7 {
+		t.Errorf("attempted %d deliveries (%+v), want only delivery 7", attempted, repo.attempts)
+	}
+}
+
+func TestRedeliverRefusesPausedSubscriptions(t *testing.T) {
+	service, repo := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a paused subscription was posted to")
+	}, 3)
+	repo.subscriptions[1].Active = false
+
+	if _, err := service.Redeliver(context.Background(), 1, 7); !errors.Is(err, ErrSubscriptionInactive) {
+		t.Errorf("Redeliver = %v, want ErrSubscriptionInactive", err)
+	}
+}
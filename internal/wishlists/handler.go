@@ -0,0 +1,344 @@
+package wishlists
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"ecommerce-service/internal/auth"
+	"ecommerce-service/internal/carts"
+	"ecommerce-service/pkg/httpx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	Service interface {
+		Create(ctx context.Context, userID int64, name string) (*Wishlist, error)
+		FindByUserID(ctx context.Context, userID int64) ([]Wishlist, error)
+		FindByID(ctx context.Context, id, userID int64) (*Wishlist, error)
+		FindShared(ctx context.Context, token string) (*Wishlist, error)
+		Rename(ctx context.Context, id, userID int64, name string) (*Wishlist, error)
+		Delete(ctx context.Context, id, userID int64) error
+		Share(ctx context.Context, id, userID int64) (*Wishlist, error)
+		Unshare(ctx context.Context, id, userID int64) (*Wishlist, error)
+		AddItem(ctx context.Context, id, userID int64, data AddItemRequest) (*WishlistItem, error)
+		RemoveItem(ctx context.Context, id, itemID, userID int64) error
+		MoveToCart(ctx context.Context, id, itemID, userID int64, quantity int) (*carts.Cart, error)
+	}
+
+	WishlistHandler struct {
+		wishlistService Service
+		validate        *validator.Validate
+	}
+)
+
+func NewWishlistHandler(wishlistService Service, validate *validator.Validate) *WishlistHandler {
+	return &WishlistHandler{wishlistService: wishlistService, validate: validate}
+}
+
+func (h *WishlistHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	req := WishlistRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	wishlist, err := h.wishlistService.Create(ctx, userID, req.Name)
+	if err != nil {
+		if errors.Is(err, ErrDuplicateName) {
+			httpx.HTTPError(w, http.StatusConflict, err.Error())
+			return
+		}
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusCreated, wishlist)
+}
+
+// FindAll lists the wishlists of the authenticated user, without their items.
+func (h *WishlistHandler) FindAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+
+	wishlists, err := h.wishlistService.FindByUserID(ctx, userID)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlists)
+}
+
+func (h *WishlistHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	wishlist, err := h.wishlistService.FindByID(ctx, id, userID)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlist)
+}
+
+// FindShared returns the wishlist shared through the token of the public link.
+func (h *WishlistHandler) FindShared(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	wishlist, err := h.wishlistService.FindShared(ctx, chi.URLParam(r, "token"))
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := WishlistRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	wishlist, err := h.wishlistService.Rename(ctx, id, userID, req.Name)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.wishlistService.Delete(ctx, id, userID); err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+// Share creates the public link of the wishlist, returned as its share_token.
+func (h *WishlistHandler) Share(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	wishlist, err := h.wishlistService.Share(ctx, id, userID)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) Unshare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	wishlist, err := h.wishlistService.Unshare(ctx, id, userID)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := AddItemRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+
+	item, err := h.wishlistService.AddItem(ctx, id, userID, req)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, item)
+}
+
+func (h *WishlistHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	if err := h.wishlistService.RemoveItem(ctx, id, itemID, userID); err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, map[string]string{"message": httpx.DeletedResponse})
+}
+
+// MoveToCart moves an item to the cart of the user, with an optional quantity (1 by default), and
+// returns the cart.
+func (h *WishlistHandler) MoveToCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		httpx.HTTPError(w, http.StatusUnauthorized, httpx.UnauthorizedError)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.InvalidIDError)
+		return
+	}
+
+	req := MoveToCartRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		httpx.HTTPError(w, http.StatusBadRequest, httpx.BadRequestError)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		httpx.HTTPErrors(w, http.StatusBadRequest, httpx.FormatValidatorErrors(err))
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	cart, err := h.wishlistService.MoveToCart(ctx, id, itemID, userID, req.Quantity)
+	if err != nil {
+		writeWishlistError(w, err)
+		return
+	}
+
+	httpx.HTTPResponse(w, http.StatusOK, cart)
+}
+
+func writeWishlistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		httpx.HTTPError(w, http.StatusNotFound, httpx.NotFoundError)
+	case errors.Is(err, ErrDuplicateName):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrVariantNotFound), errors.Is(err, carts.ErrVariantRequired), errors.Is(err, carts.ErrVariantNotFound):
+		httpx.HTTPError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, carts.ErrOutOfStock):
+		httpx.HTTPError(w, http.StatusConflict, err.Error())
+	default:
+		httpx.HTTPError(w, http.StatusInternalServerError, httpx.InternalServerError)
+	}
+}
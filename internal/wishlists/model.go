@@ -0,0 +1,54 @@
+// Package wishlists lets users keep named lists of products to buy later, share them and be told
+// when they are back in stock.
+package wishlists
+
+import (
+	"time"
+
+	"ecommerce-service/internal/products"
+)
+
+type Wishlist struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id,omitempty"` // Unset on shared lists
+	Name       string         `json:"name"`
+	ShareToken *string        `json:"share_token,omitempty"` // Set while the list is shared
+	Items      []WishlistItem `json:"items,omitempty"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time     `json:"updated_at,omitempty"`
+}
+
+type WishlistItem struct {
+	ID            int64             `json:"id"`
+	WishlistID    int64             `json:"wishlist_id"`
+	ProductID     int64             `json:"product_id"`
+	VariantID     *int64            `json:"variant_id,omitempty"`
+	NotifyInStock bool              `json:"notify_in_stock"`
+	Available     bool              `json:"available"`         // The product is on sale and in stock
+	Product       *products.Product `json:"product,omitempty"` // Unset once the product is no longer on sale
+	CreatedAt     *time.Time        `json:"created_at,omitempty"`
+}
+
+// WishlistRequest creates or renames a list.
+type WishlistRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// AddItemRequest adds a product to a list, or updates the notification of a product already in it.
+type AddItemRequest struct {
+	ProductID     int64  `json:"product_id" validate:"required"`
+	VariantID     *int64 `json:"variant_id"`
+	NotifyInStock bool   `json:"notify_in_stock"`
+}
+
+type MoveToCartRequest struct {
+	Quantity int `json:"quantity" validate:"omitempty,gte=1"` // 1 by default
+}
+
+// StockNotice is a wishlisted product that came back in stock for a user who asked to be told.
+type StockNotice struct {
+	Email       string
+	ProductID   int64
+	ProductName string
+	SKU         *string
+}
@@ -0,0 +1,243 @@
+package wishlists
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+type WishlistRepository struct {
+	db *sql.DB
+}
+
+func NewWishlistRepository(db *sql.DB) *WishlistRepository {
+	return &WishlistRepository{db: db}
+}
+
+const wishlistColumns = "id, user_id, name, share_token, created_at, updated_at"
+
+// available tells whether the product p, or its variant v when set, is on sale and in stock. A
+// product sold in variants is in stock while any of them is.
+const available = `(p.status = 'active' AND p.deleted_at IS NULL AND CASE
+		WHEN v.id IS NOT NULL THEN v.stock > 0
+		WHEN EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = p.id) THEN EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = p.id AND pv.stock > 0)
+		ELSE p.stock > 0
+	END)`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWishlist(s scanner) (*Wishlist, error) {
+	var w Wishlist
+	if err := s.Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func scanItem(s scanner) (*WishlistItem, error) {
+	var i WishlistItem
+	if err := s.Scan(&i.ID, &i.WishlistID, &i.ProductID, &i.VariantID, &i.NotifyInStock, &i.Available, &i.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (wr *WishlistRepository) Create(ctx context.Context, userID int64, name string) (*Wishlist, error) {
+	query := "INSERT INTO wishlists (user_id, name) VALUES ($1, $2) RETURNING " + wishlistColumns
+	wishlist, err := scanWishlist(wr.db.QueryRowContext(ctx, query, userID, name))
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateName
+	}
+	return wishlist, err
+}
+
+// FindByUserID returns the lists of the user by name, without their items.
+func (wr *WishlistRepository) FindByUserID(ctx context.Context, userID int64) ([]Wishlist, error) {
+	query := "SELECT " + wishlistColumns + " FROM wishlists WHERE user_id = $1 ORDER BY name"
+	rows, err := wr.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	wishlists := []Wishlist{}
+	for rows.Next() {
+		wishlist, err := scanWishlist(rows)
+		if err != nil {
+			return nil, err
+		}
+		wishlists = append(wishlists, *wishlist)
+	}
+
+	return wishlists, rows.Err()
+}
+
+// FindByID returns the list when it belongs to the user.
+func (wr *WishlistRepository) FindByID(ctx context.Context, id, userID int64) (*Wishlist, error) {
+	query := "SELECT " + wishlistColumns + " FROM wishlists WHERE id = $1 AND user_id = $2"
+	return scanWishlist(wr.db.QueryRowContext(ctx, query, id, userID))
+}
+
+func (wr *WishlistRepository) FindByShareToken(ctx context.Context, token string) (*Wishlist, error) {
+	query := "SELECT " + wishlistColumns + " FROM wishlists WHERE share_token = $1"
+	return scanWishlist(wr.db.QueryRowContext(ctx, query, token))
+}
+
+func (wr *WishlistRepository) Rename(ctx context.Context, id, userID int64, name string) (*Wishlist, error) {
+	query := "UPDATE wishlists SET name = $3, updated_at = NOW() WHERE id = $1 AND user_id = $2 RETURNING " + wishlistColumns
+	wishlist, err := scanWishlist(wr.db.QueryRowContext(ctx, query, id, userID, name))
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateName
+	}
+	return wishlist, err
+}
+
+// SetShareToken shares the list through the token, or stops sharing it when the token is nil.
+func (wr *WishlistRepository) SetShareToken(ctx context.Context, id, userID int64, token *string) (*Wishlist, error) {
+	query := "UPDATE wishlists SET share_token = $3, updated_at = NOW() WHERE id = $1 AND user_id = $2 RETURNING " + wishlistColumns
+	return scanWishlist(wr.db.QueryRowContext(ctx, query, id, userID, token))
+}
+
+func (wr *WishlistRepository) Delete(ctx context.Context, id, userID int64) error {
+	res, err := wr.db.ExecContext(ctx, "DELETE FROM wishlists WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// FindItems returns the items of the list, latest first.
+func (wr *WishlistRepository) FindItems(ctx context.Context, wishlistID int64) ([]WishlistItem, error) {
+	query := `SELECT wi.id, wi.wishlist_id, wi.product_id, wi.variant_id, wi.notify_in_stock, ` + available + `, wi.created_at
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		LEFT JOIN product_variants v ON v.id = wi.variant_id
+		WHERE wi.wishlist_id = $1
+		ORDER BY wi.created_at DESC, wi.id DESC`
+	rows, err := wr.db.QueryContext(ctx, query, wishlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	items := []WishlistItem{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+func (wr *WishlistRepository) FindItem(ctx context.Context, wishlistID, id int64) (*WishlistItem, error) {
+	query := `SELECT wi.id, wi.wishlist_id, wi.product_id, wi.variant_id, wi.notify_in_stock, ` + available + `, wi.created_at
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		LEFT JOIN product_variants v ON v.id = wi.variant_id
+		WHERE wi.wishlist_id = $1 AND wi.id = $2`
+	return scanItem(wr.db.QueryRowContext(ctx, query, wishlistID, id))
+}
+
+// UpsertItem adds the product to the list or, when it is already there, updates its notification.
+// The availability is recorded so that only a later restock is notified.
+func (wr *WishlistRepository) UpsertItem(ctx context.Context, wishlistID int64, data AddItemRequest) (*WishlistItem, error) {
+	query := `INSERT INTO wishlist_items (wishlist_id, product_id, variant_id, notify_in_stock, in_stock)
+		SELECT $1, p.id, v.id, $4, ` + available + `
+		FROM products p
+		LEFT JOIN product_variants v ON v.id = $3 AND v.product_id = p.id
+		WHERE p.id = $2
+		ON CONFLICT (wishlist_id, product_id, COALESCE(variant_id, 0)) DO UPDATE
+			SET notify_in_stock = EXCLUDED.notify_in_stock, in_stock = EXCLUDED.in_stock
+		RETURNING id, wishlist_id, product_id, variant_id, notify_in_stock, in_stock, created_at`
+	item, err := scanItem(wr.db.QueryRowContext(ctx, query, wishlistID, data.ProductID, data.VariantID, data.NotifyInStock))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = wr.db.ExecContext(ctx, "UPDATE wishlists SET updated_at = NOW() WHERE id = $1", wishlistID)
+	return item, err
+}
+
+func (wr *WishlistRepository) DeleteItem(ctx context.Context, wishlistID, id int64) error {
+	res, err := wr.db.ExecContext(ctx, "DELETE FROM wishlist_items WHERE wishlist_id = $1 AND id = $2", wishlistID, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Restocked records the current availability of the items with notifications and returns those
+// that came back in stock since the last check, so each restock is notified once.
+func (wr *WishlistRepository) Restocked(ctx context.Context) ([]StockNotice, error) {
+	query := `WITH current AS (
+			SELECT wi.id, ` + available + ` AS in_stock
+			FROM wishlist_items wi
+			JOIN products p ON p.id = wi.product_id
+			LEFT JOIN product_variants v ON v.id = wi.variant_id
+			WHERE wi.notify_in_stock
+		), changed AS (
+			UPDATE wishlist_items wi SET in_stock = c.in_stock
+			FROM current c
+			WHERE wi.id = c.id AND wi.in_stock <> c.in_stock
+			RETURNING wi.wishlist_id, wi.product_id, wi.variant_id, wi.in_stock
+		)
+		SELECT u.email, p.id, p.name, v.sku
+		FROM changed ch
+		JOIN wishlists w ON w.id = ch.wishlist_id
+		JOIN users u ON u.id = w.user_id
+		JOIN products p ON p.id = ch.product_id
+		LEFT JOIN product_variants v ON v.id = ch.variant_id
+		WHERE ch.in_stock`
+	rows, err := wr.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("error closing rows: %v\n", err)
+		}
+	}()
+
+	var notices []StockNotice
+	for rows.Next() {
+		var n StockNotice
+		if err := rows.Scan(&n.Email, &n.ProductID, &n.ProductName, &n.SKU); err != nil {
+			return nil, err
+		}
+		notices = append(notices, n)
+	}
+
+	return notices, rows.Err()
+}
+
+func requireAffected(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,33 @@
+package wishlists
+
+import (
+	"ecommerce-service/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterRoutes(r chi.Router, h *WishlistHandler, am *auth.AuthMiddleware) {
+	r.Route("/wishlists", func(r chi.Router) {
+		r.Get("/shared/{token}", h.FindShared)
+
+		r.Group(func(r chi.Router) {
+			r.Use(am.VerifyToken)
+
+			r.Get("/", h.FindAll)
+			r.Post("/", h.Create)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", h.FindByID)
+				r.Patch("/", h.Rename)
+				r.Delete("/", h.Delete)
+
+				r.Post("/share", h.Share)
+				r.Delete("/share", h.Unshare)
+
+				r.Post("/items", h.AddItem)
+				r.Delete("/items/{itemID}", h.RemoveItem)
+				r.Post("/items/{itemID}/move-to-cart", h.MoveToCart)
+			})
+		})
+	})
+}
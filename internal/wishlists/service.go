@@ -0,0 +1,255 @@
+package wishlists
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ecommerce-service/internal/carts"
+	"ecommerce-service/internal/products"
+	"ecommerce-service/pkg/mailx"
+)
+
+var (
+	ErrDuplicateName   = errors.New("a wishlist with the same name already exists")
+	ErrVariantNotFound = errors.New("the variant does not belong to the product")
+)
+
+type (
+	Repository interface {
+		Create(ctx context.Context, userID int64, name string) (*Wishlist, error)
+		FindByUserID(ctx context.Context, userID int64) ([]Wishlist, error)
+		FindByID(ctx context.Context, id, userID int64) (*Wishlist, error)
+		FindByShareToken(ctx context.Context, token string) (*Wishlist, error)
+		Rename(ctx context.Context, id, userID int64, name string) (*Wishlist, error)
+		SetShareToken(ctx context.Context, id, userID int64, token *string) (*Wishlist, error)
+		Delete(ctx context.Context, id, userID int64) error
+		FindItems(ctx context.Context, wishlistID int64) ([]WishlistItem, error)
+		FindItem(ctx context.Context, wishlistID, id int64) (*WishlistItem, error)
+		UpsertItem(ctx context.Context, wishlistID int64, data AddItemRequest) (*WishlistItem, error)
+		DeleteItem(ctx context.Context, wishlistID, id int64) error
+		Restocked(ctx context.Context) ([]StockNotice, error)
+	}
+
+	// ProductFinder loads the wishlisted products.
+	ProductFinder interface {
+		FindByID(ctx context.Context, id int) (*products.Product, error)
+	}
+
+	// CartAdder puts the products moved out of a list into the cart of the user.
+	CartAdder interface {
+		AddItemToCart(ctx context.Context, userID int64, productID int64, variantID *int64, quantity int) (*carts.Cart, error)
+	}
+
+	WishlistService struct {
+		wishlistRepo  Repository
+		productFinder ProductFinder
+		cartAdder     CartAdder
+		mailer        mailx.Sender
+	}
+)
+
+func NewWishlistService(wishlistRepo Repository, productFinder ProductFinder, cartAdder CartAdder, mailer mailx.Sender) *WishlistService {
+	return &WishlistService{wishlistRepo: wishlistRepo, productFinder: productFinder, cartAdder: cartAdder, mailer: mailer}
+}
+
+func (s *WishlistService) Create(ctx context.Context, userID int64, name string) (*Wishlist, error) {
+	return s.wishlistRepo.Create(ctx, userID, name)
+}
+
+func (s *WishlistService) FindByUserID(ctx context.Context, userID int64) ([]Wishlist, error) {
+	return s.wishlistRepo.FindByUserID(ctx, userID)
+}
+
+// FindByID returns a list of the user with its items.
+func (s *WishlistService) FindByID(ctx context.Context, id, userID int64) (*Wishlist, error) {
+	wishlist, err := s.wishlistRepo.FindByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadItems(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// FindShared returns the list shared through the token with its items, without its owner.
+func (s *WishlistService) FindShared(ctx context.Context, token string) (*Wishlist, error) {
+	wishlist, err := s.wishlistRepo.FindByShareToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	wishlist.UserID = 0
+	if err := s.loadItems(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *WishlistService) Rename(ctx context.Context, id, userID int64, name string) (*Wishlist, error) {
+	return s.wishlistRepo.Rename(ctx, id, userID, name)
+}
+
+func (s *WishlistService) Delete(ctx context.Context, id, userID int64) error {
+	return s.wishlistRepo.Delete(ctx, id, userID)
+}
+
+// Share makes the list public through a random token, keeping the token it already has.
+func (s *WishlistService) Share(ctx context.Context, id, userID int64) (*Wishlist, error) {
+	wishlist, err := s.wishlistRepo.FindByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if wishlist.ShareToken != nil {
+		return wishlist, nil
+	}
+
+	token, err := shareToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.wishlistRepo.SetShareToken(ctx, id, userID, &token)
+}
+
+// Unshare revokes the public link of the list.
+func (s *WishlistService) Unshare(ctx context.Context, id, userID int64) (*Wishlist, error) {
+	return s.wishlistRepo.SetShareToken(ctx, id, userID, nil)
+}
+
+// AddItem adds an active product, or one of its variants, to a list of the user.
+func (s *WishlistService) AddItem(ctx context.Context, id, userID int64, data AddItemRequest) (*WishlistItem, error) {
+	if _, err := s.wishlistRepo.FindByID(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	product, err := s.productFinder.FindByID(ctx, int(data.ProductID))
+	if err != nil {
+		return nil, err
+	}
+	if product.Status != products.StatusActive {
+		return nil, sql.ErrNoRows
+	}
+	if data.VariantID != nil && !hasVariant(product, *data.VariantID) {
+		return nil, ErrVariantNotFound
+	}
+
+	item, err := s.wishlistRepo.UpsertItem(ctx, id, data)
+	if err != nil {
+		return nil, err
+	}
+	item.Product = product
+	return item, nil
+}
+
+func (s *WishlistService) RemoveItem(ctx context.Context, id, itemID, userID int64) error {
+	if _, err := s.wishlistRepo.FindByID(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.wishlistRepo.DeleteItem(ctx, id, itemID)
+}
+
+// MoveToCart adds the item to the cart of the user and takes it out of the list.
+func (s *WishlistService) MoveToCart(ctx context.Context, id, itemID, userID int64, quantity int) (*carts.Cart, error) {
+	if _, err := s.wishlistRepo.FindByID(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	item, err := s.wishlistRepo.FindItem(ctx, id, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartAdder.AddItemToCart(ctx, userID, item.ProductID, item.VariantID, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.wishlistRepo.DeleteItem(ctx, id, itemID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// RunBackInStock emails the users who asked to be told when their wishlisted products come back in
+// stock, checking every interval until ctx is done. A restock is notified once, even if the email
+// cannot be sent.
+func (s *WishlistService) RunBackInStock(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		notices, err := s.wishlistRepo.Restocked(ctx)
+		if err != nil {
+			log.Printf("error checking the wishlisted products back in stock: %v\n", err)
+		}
+		for _, n := range notices {
+			if err := s.mailer.Send(ctx, backInStockMessage(n)); err != nil {
+				log.Printf("error notifying %s that product %d is back in stock: %v\n", n.Email, n.ProductID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func backInStockMessage(n StockNotice) mailx.Message {
+	name := n.ProductName
+	if n.SKU != nil {
+		name = fmt.Sprintf("%s (%s)", n.ProductName, *n.SKU)
+	}
+	return mailx.Message{
+		To:      n.Email,
+		Subject: fmt.Sprintf("%s vuelve a estar disponible", n.ProductName),
+		Body:    fmt.Sprintf("Hola,\n\n%s, de tu lista de deseos, vuelve a estar disponible.\n", name),
+	}
+}
+
+// loadItems sets the items of the list with their products. The items of products no longer on sale
+// are kept without them.
+func (s *WishlistService) loadItems(ctx context.Context, wishlist *Wishlist) error {
+	items, err := s.wishlistRepo.FindItems(ctx, wishlist.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		product, err := s.productFinder.FindByID(ctx, int(items[i].ProductID))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if product.Status == products.StatusActive {
+			items[i].Product = product
+		}
+	}
+
+	wishlist.Items = items
+	return nil
+}
+
+func hasVariant(product *products.Product, variantID int64) bool {
+	for _, v := range product.Variants {
+		if v.ID == variantID {
+			return true
+		}
+	}
+	return false
+}
+
+func shareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
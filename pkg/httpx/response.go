@@ -24,14 +24,24 @@ func HTTPResponse(w http.ResponseWriter, status int, data any) {
 }
 
 func HTTPPaginatedResponse(w http.ResponseWriter, status int, data any, page, limit, total int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(map[string]any{
+	HTTPPaginatedResponseWith(w, status, data, page, limit, total, nil)
+}
+
+// HTTPPaginatedResponseWith writes a paginated response with extra top-level fields, such as search facets.
+func HTTPPaginatedResponseWith(w http.ResponseWriter, status int, data any, page, limit, total int, extra map[string]any) {
+	body := map[string]any{
 		"data":        data,
 		"total":       total,
 		"page":        page,
 		"total_pages": (total + limit - 1) / limit,
-	}); err != nil {
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -44,3 +54,27 @@ func ParseJSON(r *http.Request, dst any) error {
 	}()
 	return json.NewDecoder(r.Body).Decode(&dst)
 }
+
+// HTTPCursorResponse writes a page of a keyset-paginated list with the cursors of the next and
+// previous pages, which are null at either end of the list.
+func HTTPCursorResponse(w http.ResponseWriter, status int, data any, next, prev *string) {
+	HTTPCursorResponseWith(w, status, data, next, prev, nil)
+}
+
+// HTTPCursorResponseWith writes a keyset-paginated response with extra top-level fields.
+func HTTPCursorResponseWith(w http.ResponseWriter, status int, data any, next, prev *string, extra map[string]any) {
+	body := map[string]any{
+		"data":        data,
+		"next_cursor": next,
+		"prev_cursor": prev,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}